@@ -0,0 +1,325 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// blindSchnorrLabel domain separates blind Schnorr challenges, so the
+// signer of blind tokens can't be used to sign other Schnorr messages
+var blindSchnorrLabel = []byte("orbital-blind-schnorr")
+
+// A BlindSignerSession is the secret state of the signer of a blind
+// signature between the commitment and the signature, see BlindToken
+type BlindSignerSession struct {
+	Public CurvePoint
+	Nonce  Scalar
+
+	used bool
+}
+
+// A BlindCommitment is sent by the signer to open a session
+type BlindCommitment struct {
+	Public CurvePoint `json:"public"`
+	R      CurvePoint `json:"r"`
+}
+
+// A BlindChallenge is the blinded challenge the requester asks the signer
+// to sign
+type BlindChallenge struct {
+	C *big.Int `json:"c"`
+}
+
+// A BlindResponse is the signer's response to a blinded challenge
+type BlindResponse struct {
+	S *big.Int `json:"s"`
+}
+
+// A BlindRequest is the secret state of the requester between blinding
+// the challenge and unblinding the response
+type BlindRequest struct {
+	Commitment BlindCommitment
+	Message    []byte
+	Alpha      Scalar
+	Beta       Scalar
+	C          Scalar
+}
+
+// A BlindToken is a message with a blind Schnorr signature, which the
+// signer makes without seeing the message or the signature, so it can't
+// link the token to the session it was issued in:
+//
+//   signer:    k ← random, R ← g^k                      (request)
+//   requester: α, β ← random
+//              R' ← R · g^α · y^β
+//              c' ← H(R', y, m), c ← c' - β             (blind)
+//   signer:    s ← k - c·x                              (sign)
+//   requester: s' ← s + α                               (unblind)
+//
+// (c', s') is a Schnorr signature of m by y, under its own label, which
+// the signer has never seen. Each nonce k must only be used once, and the
+// signer should finish a session before opening another: many concurrent
+// sessions let a requester forge more signatures than were issued, the ROS
+// attack of Benhamouda et al. (IACR 2020/945).
+//
+type BlindToken struct {
+	Public    CurvePoint        `json:"public"`
+	Message   []byte            `json:"message"`
+	Signature *SchnorrSignature `json:"signature"`
+}
+
+// MarshalJSON converts a BlindSignerSession to a JSON representation
+func (s *BlindSignerSession) MarshalJSON() ([]byte, error) {
+	if s.used {
+		return nil, errors.New("Blind signing session has already been used")
+	}
+
+	return json.Marshal(&struct {
+		Public *CurvePoint `json:"public"`
+		Nonce  *Scalar     `json:"nonce"`
+	}{
+		Public: &s.Public,
+		Nonce:  &s.Nonce,
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a BlindSignerSession
+func (s *BlindSignerSession) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Public CurvePoint `json:"public"`
+		Nonce  *hexBig    `json:"nonce"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	nonce, err := ParseScalar(aux.Public.Group(), (*big.Int)(aux.Nonce))
+	if err != nil || nonce.IsZero() {
+		return errors.New("Invalid blind signing session, bad nonce")
+	}
+
+	s.Public = aux.Public
+	s.Nonce = nonce
+	s.used = false
+	return nil
+}
+
+// MarshalJSON converts a BlindChallenge to a JSON representation
+func (c *BlindChallenge) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		C *hexBig `json:"c"`
+	}{
+		C: (*hexBig)(c.C),
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a BlindChallenge
+func (c *BlindChallenge) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		C *hexBig `json:"c"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.C == nil {
+		return errors.New("Invalid blind challenge, no c specified")
+	}
+	c.C = (*big.Int)(aux.C)
+	return nil
+}
+
+// MarshalJSON converts a BlindResponse to a JSON representation
+func (r *BlindResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		S *hexBig `json:"s"`
+	}{
+		S: (*hexBig)(r.S),
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a BlindResponse
+func (r *BlindResponse) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		S *hexBig `json:"s"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.S == nil {
+		return errors.New("Invalid blind response, no s specified")
+	}
+	r.S = (*big.Int)(aux.S)
+	return nil
+}
+
+// MarshalJSON converts a BlindRequest to a JSON representation
+func (r *BlindRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Commitment *BlindCommitment `json:"commitment"`
+		Message    []byte           `json:"message"`
+		Alpha      *Scalar          `json:"alpha"`
+		Beta       *Scalar          `json:"beta"`
+		C          *Scalar          `json:"c"`
+	}{
+		Commitment: &r.Commitment,
+		Message:    r.Message,
+		Alpha:      &r.Alpha,
+		Beta:       &r.Beta,
+		C:          &r.C,
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a BlindRequest
+func (r *BlindRequest) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Commitment BlindCommitment `json:"commitment"`
+		Message    []byte          `json:"message"`
+		Alpha      *hexBig         `json:"alpha"`
+		Beta       *hexBig         `json:"beta"`
+		C          *hexBig         `json:"c"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	scalars, err := scalarsFromJSON(aux.Commitment.Public.Group(), []*hexBig{aux.Alpha, aux.Beta, aux.C})
+	if err != nil {
+		return fmt.Errorf("Invalid blind request: %v", err)
+	}
+
+	r.Commitment = aux.Commitment
+	r.Message = aux.Message
+	r.Alpha, r.Beta, r.C = scalars[0], scalars[1], scalars[2]
+	return nil
+}
+
+// NewBlindSignerSession opens a session for a blind signature by the
+// secret key x, the commitment is sent to the requester
+func NewBlindSignerSession(random io.Reader, x Scalar) (*BlindSignerSession, *BlindCommitment, error) {
+	if x.IsZero() {
+		return nil, nil, errors.New("Invalid secret key")
+	}
+	g := x.Group()
+
+	k, err := RandomScalar(g, random)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	public := g.ScalarBaseMult(x.Int())
+	session := &BlindSignerSession{Public: public, Nonce: k}
+	return session, &BlindCommitment{public, g.ScalarBaseMult(k.Int())}, nil
+}
+
+// NewBlindRequest blinds the challenge of a signature of the message with
+// the signer's commitment, the challenge is sent to the signer
+func NewBlindRequest(random io.Reader, commitment *BlindCommitment, message []byte) (*BlindRequest, *BlindChallenge, error) {
+	public := &commitment.Public
+	g := public.Group()
+	if false == isValidPublicKey(public) {
+		return nil, nil, errors.New("Invalid signer public key")
+	}
+	if commitment.R.Group() != g || false == isValidPublicKey(&commitment.R) {
+		return nil, nil, errors.New("Invalid signer commitment")
+	}
+
+	alpha, err := RandomScalar(g, random)
+	if err != nil {
+		return nil, nil, err
+	}
+	beta, err := RandomScalar(g, random)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// R' ← R · g^α · y^β
+	R := commitment.R.Add(MultiScalarMult(g, []CurvePoint{g.ScalarBaseMult(bigOne), *public}, []*big.Int{alpha.Int(), beta.Int()}))
+	c := NewScalar(g, schnorrChallenge(blindSchnorrLabel, R, public, message))
+
+	request := &BlindRequest{
+		Commitment: *commitment,
+		Message:    message,
+		Alpha:      alpha,
+		Beta:       beta,
+		C:          c,
+	}
+	return request, &BlindChallenge{c.Sub(beta).Int()}, nil
+}
+
+// Sign responds to a blinded challenge with the secret key x, a session can
+// only be used once, answering two challenges with it reveals the key
+func (s *BlindSignerSession) Sign(x Scalar, challenge *BlindChallenge) (*BlindResponse, error) {
+	if s.used {
+		return nil, errors.New("Blind signing session has already been used")
+	}
+	g := s.Public.Group()
+	if x.Group() != g {
+		return nil, errors.New("Secret key is not in the group of the session")
+	}
+	public := g.ScalarBaseMult(x.Int())
+	if !public.Equals(&s.Public) {
+		return nil, errors.New("Secret key does not match the session")
+	}
+
+	c, err := ParseScalar(g, challenge.C)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid blind challenge: %v", err)
+	}
+
+	// The nonce is forgotten before responding, so it can't be used twice
+	k := s.Nonce
+	s.Nonce = Scalar{}
+	s.used = true
+
+	return &BlindResponse{k.Sub(c.Mul(x)).Int()}, nil
+}
+
+// Unblind checks the signer's response and unblinds it into a token,
+// s' ← s + α
+func (r *BlindRequest) Unblind(response *BlindResponse) (*BlindToken, error) {
+	public := &r.Commitment.Public
+	g := public.Group()
+
+	s, err := ParseScalar(g, response.S)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid blind response: %v", err)
+	}
+
+	// R = g^s · y^c, with the blinded challenge c = c' - β
+	blinded := r.C.Sub(r.Beta)
+	R := public.ParameterPointAdd(s.Int(), blinded.Int())
+	if !R.Equals(&r.Commitment.R) {
+		return nil, errors.New("Invalid blind response, it is not for the challenge")
+	}
+
+	token := &BlindToken{
+		Public:    *public,
+		Message:   r.Message,
+		Signature: &SchnorrSignature{r.C.Int(), s.Add(r.Alpha).Int()},
+	}
+	if !token.Verify() {
+		return nil, errors.New("Unblinded signature is invalid")
+	}
+	return token, nil
+}
+
+// Verify checks the token was signed by its public key:
+//
+//   c' = H(g^s' · y^c', y, m)
+//
+func (t *BlindToken) Verify() bool {
+	return schnorrVerify(&t.Public, blindSchnorrLabel, t.Message, t.Signature)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+// blindSign runs a blind signing session of the message with the key x,
+// passing every message through JSON as the command line does
+func blindSign(t *testing.T, x Scalar, message []byte) (*BlindToken, *BlindChallenge) {
+	session, commitment, err := NewBlindSignerSession(rand.Reader, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, session, &BlindSignerSession{})
+	roundTrip(t, commitment, &BlindCommitment{})
+
+	request, challenge, err := NewBlindRequest(rand.Reader, commitment, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, request, &BlindRequest{})
+	roundTrip(t, challenge, &BlindChallenge{})
+
+	response, err := session.Sign(x, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, response, &BlindResponse{})
+
+	token, err := request.Unblind(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, token, &BlindToken{})
+	return token, challenge
+}
+
+// roundTrip replaces v with its value after a JSON round trip through out
+func roundTrip(t *testing.T, v interface{}, out interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+	data, err = json.Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlindSchnorr(t *testing.T) {
+	for _, g := range Curves {
+		x, err := RandomScalar(g, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		message := []byte("ring slot token")
+
+		token, challenge := blindSign(t, x, message)
+		if !token.Verify() {
+			t.Fatalf("%v: blind signed token not verified", g.Name())
+		}
+		public := g.ScalarBaseMult(x.Int())
+		if !token.Public.Equals(&public) {
+			t.Fatalf("%v: token is not signed by the signer", g.Name())
+		}
+
+		// The signer never sees the challenge of the token
+		if token.Signature.C.Cmp(challenge.C) == 0 {
+			t.Fatalf("%v: the challenge was not blinded", g.Name())
+		}
+
+		// Blind signatures can't be passed off as ordinary Schnorr signatures
+		if schnorrVerify(&token.Public, schnorrLabel, token.Message, token.Signature) {
+			t.Fatalf("%v: blind signature verified as a Schnorr signature", g.Name())
+		}
+
+		tampered := *token
+		tampered.Message = []byte("another token")
+		if tampered.Verify() {
+			t.Fatalf("%v: token verified with another message", g.Name())
+		}
+	}
+}
+
+func TestBlindSchnorrSessionReuse(t *testing.T) {
+	x, err := RandomScalar(BN256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, commitment, err := NewBlindSignerSession(rand.Reader, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, challenge, err := NewBlindRequest(rand.Reader, commitment, []byte("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := RandomScalar(BN256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Sign(other, challenge); err == nil {
+		t.Fatal("Signed with a key other than the session's")
+	}
+
+	if _, err := session.Sign(x, challenge); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Sign(x, challenge); err == nil {
+		t.Fatal("Blind signing session used twice")
+	}
+	if _, err := json.Marshal(session); err == nil {
+		t.Fatal("Saved a used blind signing session")
+	}
+}
+
+func TestBlindSchnorrInvalidResponse(t *testing.T) {
+	x, err := RandomScalar(BN256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, commitment, err := NewBlindSignerSession(rand.Reader, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, challenge, err := NewBlindRequest(rand.Reader, commitment, []byte("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := session.Sign(x, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response.S = new(big.Int).Add(response.S, bigOne)
+	if _, err := request.Unblind(response); err == nil {
+		t.Fatal("Unblinded an invalid response")
+	}
+	response.S = BN256.Order()
+	if _, err := request.Unblind(response); err == nil {
+		t.Fatal("Unblinded a response out of range")
+	}
+
+	// A commitment which isn't a valid point
+	invalid := &BlindCommitment{Public: commitment.Public, R: BN256.Infinity()}
+	if _, _, err := NewBlindRequest(rand.Reader, invalid, []byte("token")); err == nil {
+		t.Fatal("Blinded a token for the point at infinity")
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/clearmatics/bn256"
+)
+
+// blsLabel domain separates the hashes of BLS messages onto G1
+var blsLabel = []byte("orbital-bls")
+
+// A BLSKey is a BLS secret key and its public key in G2
+type BLSKey struct {
+	Secret *big.Int `json:"secret"`
+	Public G2Point  `json:"public"`
+}
+
+// A BLSSigner is the public key and message of one of the signatures
+// combined into a BLSSignature
+type BLSSigner struct {
+	Public  G2Point `json:"public"`
+	Message []byte  `json:"message"`
+}
+
+// A BLSSignature is a signature in G1 of a message by one signer, or the
+// aggregate of the signatures of many signers which is the same size
+type BLSSignature struct {
+	Signers   []BLSSigner `json:"signers"`
+	Signature CurvePoint  `json:"signature"`
+}
+
+// MarshalJSON converts a BLSKey to a JSON representation
+func (k *BLSKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Secret *hexBig  `json:"secret"`
+		Public *G2Point `json:"public"`
+	}{
+		Secret: (*hexBig)(k.Secret),
+		Public: &k.Public,
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a BLSKey struct, the
+// public key must be the one of the secret key
+func (k *BLSKey) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Secret *hexBig `json:"secret"`
+		Public G2Point `json:"public"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	secret := (*big.Int)(aux.Secret)
+	if false == isValidSecretKey(secret) {
+		return errors.New("Invalid BLS secret key")
+	}
+
+	public := BLSPublicKey(secret)
+	if !public.Equals(&aux.Public) {
+		return errors.New("BLS public key does not match the secret key")
+	}
+
+	k.Secret = secret
+	k.Public = public
+	return nil
+}
+
+// NewBLSKey generates a random BLS key
+func NewBLSKey(random io.Reader) (*BLSKey, error) {
+	secret, err := randomScalar(BN256, random)
+	if err != nil {
+		return nil, err
+	}
+	return &BLSKey{secret, BLSPublicKey(secret)}, nil
+}
+
+// BLSPublicKey returns the public key of the secret key x, g2^x
+func BLSPublicKey(secret *big.Int) G2Point {
+	return g2ScalarBaseMult(secret)
+}
+
+// blsMessagePoint hashes a message onto G1 together with the public key of
+// the signer, so an aggregate can't be forged by choosing a public key
+// which cancels out those of the other signers:
+//
+//   H(pk, m)
+//
+func blsMessagePoint(public *G2Point, message []byte) CurvePoint {
+	data := appendLengthPrefixed(nil, blsLabel)
+	data = appendLengthPrefixed(data, public.Marshal())
+	data = appendLengthPrefixed(data, message)
+	return *NewCurvePointFromString(data)
+}
+
+// BLSSign signs a message with the secret key x:
+//
+//   σ ← H(pk, m)^x
+//
+func BLSSign(secret *big.Int, message []byte) (*BLSSignature, error) {
+	if false == isValidSecretKey(secret) {
+		return nil, errors.New("Invalid BLS secret key")
+	}
+
+	public := BLSPublicKey(secret)
+	h := blsMessagePoint(&public, message)
+	return &BLSSignature{
+		Signers:   []BLSSigner{{public, message}},
+		Signature: h.ScalarMult(secret),
+	}, nil
+}
+
+// BLSAggregate combines signatures into one, of all of their signers:
+//
+//   σ ← σ1 + ... + σn
+//
+// Each signer may only sign a message once.
+//
+func BLSAggregate(sigs []BLSSignature) (*BLSSignature, error) {
+	if len(sigs) == 0 {
+		return nil, errors.New("No signatures to aggregate")
+	}
+
+	var out BLSSignature
+	out.Signature = BN256.Infinity()
+	for i, sig := range sigs {
+		if sig.Signature.Group() != BN256 {
+			return nil, fmt.Errorf("Signature %v is not on %v", i, DefaultCurve)
+		}
+		out.Signers = append(out.Signers, sig.Signers...)
+		out.Signature = out.Signature.Add(sig.Signature)
+	}
+
+	if err := checkBLSSigners(out.Signers); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// checkBLSSigners returns an error if there are no signers, or one of them
+// signs the same message twice
+func checkBLSSigners(signers []BLSSigner) error {
+	if len(signers) == 0 {
+		return errors.New("No signers")
+	}
+
+	seen := make(map[string]bool, len(signers))
+	for i, s := range signers {
+		if s.Public.IsInfinity() {
+			return fmt.Errorf("Invalid public key of signer %v", i)
+		}
+		key := string(s.Public.Marshal()) + string(s.Message)
+		if seen[key] {
+			return fmt.Errorf("Signer %v signed the same message twice", i)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Verify checks the signature against the messages of all of its signers,
+// with a single pairing check:
+//
+//   e(σ, g2) = e(H(pk1, m1), pk1) · ... · e(H(pkn, mn), pkn)
+//
+func (sig *BLSSignature) Verify() bool {
+	if sig == nil || checkBLSSigners(sig.Signers) != nil {
+		return false
+	}
+	if sig.Signature.Group() != BN256 || sig.Signature.IsInfinity() {
+		return false
+	}
+
+	// e(-σ, g2) · e(H(pk1, m1), pk1) · ... = 1
+	a := []*bn256.G1{sig.Signature.Neg().g1()}
+	b := []*bn256.G2{g2ScalarBaseMult(bigOne).g}
+	for i := range sig.Signers {
+		s := &sig.Signers[i]
+		h := blsMessagePoint(&s.Public, s.Message)
+		a = append(a, h.g1())
+		b = append(b, s.Public.point())
+	}
+	return bn256.PairingCheck(a, b)
+}
+
+// VerifyTrusted checks the signature, and that every signer is one of the
+// trusted public keys and at least threshold distinct trusted keys signed.
+// Anyone can make a valid signature with a key of their own, so it is only
+// an attestation by the signers it is checked against.
+//
+func (sig *BLSSignature) VerifyTrusted(trusted []G2Point, threshold int) error {
+	if threshold < 1 || threshold > len(trusted) {
+		return fmt.Errorf("Threshold must be between 1 and the %v trusted keys, got %v", len(trusted), threshold)
+	}
+	if sig == nil {
+		return errors.New("No signature")
+	}
+
+	isTrusted := make(map[string]bool, len(trusted))
+	for _, public := range trusted {
+		isTrusted[string(public.Marshal())] = true
+	}
+
+	signed := make(map[string]bool, len(sig.Signers))
+	for i := range sig.Signers {
+		key := string(sig.Signers[i].Public.Marshal())
+		if !isTrusted[key] {
+			return fmt.Errorf("Signer %v is not a trusted key", i)
+		}
+		signed[key] = true
+	}
+	if len(signed) < threshold {
+		return fmt.Errorf("Signed by %v trusted keys, %v are required", len(signed), threshold)
+	}
+
+	if !sig.Verify() {
+		return errors.New("Signature not verified")
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+)
+
+func generateBLSKeys(t *testing.T, n int) []*BLSKey {
+	keys := make([]*BLSKey, n)
+	for i := range keys {
+		key, err := NewBLSKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func TestBLSSignature(t *testing.T) {
+	key := generateBLSKeys(t, 1)[0]
+	message := []byte("foobarbaz")
+
+	sig, err := BLSSign(key.Secret, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sig.Verify() {
+		t.Fatal("Signature not verified")
+	}
+
+	bad := *sig
+	bad.Signers = []BLSSigner{{key.Public, []byte("badmessage")}}
+	if bad.Verify() {
+		t.Fatal("Signature verified for a different message")
+	}
+
+	other := generateBLSKeys(t, 1)[0]
+	bad.Signers = []BLSSigner{{other.Public, message}}
+	if bad.Verify() {
+		t.Fatal("Signature verified for a different key")
+	}
+
+	bad = *sig
+	bad.Signature = BN256.Infinity()
+	if bad.Verify() {
+		t.Fatal("Verified the point at infinity")
+	}
+
+	if _, err := BLSSign(bigZero, message); err == nil {
+		t.Fatal("Signed with an invalid secret key")
+	}
+}
+
+func TestBLSAggregate(t *testing.T) {
+	keys := generateBLSKeys(t, 4)
+	ring := []byte("ring")
+
+	var sigs []BLSSignature
+	for i, key := range keys {
+		// The last signer signs a different message
+		message := ring
+		if i == len(keys)-1 {
+			message = []byte("other")
+		}
+		sig, err := BLSSign(key.Secret, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs = append(sigs, *sig)
+	}
+
+	aggregate, err := BLSAggregate(sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aggregate.Signers) != len(keys) || !aggregate.Verify() {
+		t.Fatal("Aggregate signature not verified")
+	}
+
+	// Aggregates can be aggregated further
+	partial, err := BLSAggregate(sigs[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	combined, err := BLSAggregate([]BLSSignature{*partial, sigs[2], sigs[3]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !combined.Verify() || !combined.Signature.Equals(&aggregate.Signature) {
+		t.Fatal("Aggregate of aggregates not verified")
+	}
+
+	// Dropping a signer, or claiming another one signed, fails
+	bad := *aggregate
+	bad.Signers = aggregate.Signers[1:]
+	if bad.Verify() {
+		t.Fatal("Verified with a signer missing")
+	}
+	bad.Signers = append([]BLSSigner{{keys[0].Public, []byte("other")}}, aggregate.Signers[1:]...)
+	if bad.Verify() {
+		t.Fatal("Verified with a signer's message changed")
+	}
+
+	if _, err := BLSAggregate([]BLSSignature{sigs[0], sigs[0]}); err == nil {
+		t.Fatal("Aggregated the same signature twice")
+	}
+	if _, err := BLSAggregate(nil); err == nil {
+		t.Fatal("Aggregated no signatures")
+	}
+}
+
+func TestBLSJSON(t *testing.T) {
+	key := generateBLSKeys(t, 1)[0]
+	sig, err := BLSSign(key.Secret, []byte("foobarbaz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loadedKey BLSKey
+	if err := json.Unmarshal(data, &loadedKey); err != nil {
+		t.Fatal(err)
+	}
+	if loadedKey.Secret.Cmp(key.Secret) != 0 || !loadedKey.Public.Equals(&key.Public) {
+		t.Fatal("Key differs after a JSON round trip")
+	}
+
+	// The public key must match the secret key
+	other := generateBLSKeys(t, 1)[0]
+	key.Public = other.Public
+	data, err = json.Marshal(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &loadedKey); err == nil {
+		t.Fatal("Loaded a key with the wrong public key")
+	}
+
+	data, err = json.Marshal(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded BLSSignature
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Verify() {
+		t.Fatal("Signature not verified after a JSON round trip")
+	}
+}
+
+func TestG2PointEncoding(t *testing.T) {
+	p := g2ScalarBaseMult(bigTwo)
+
+	var decoded G2Point
+	if !decoded.Unmarshal(p.Marshal()) || !decoded.Equals(&p) {
+		t.Fatal("Unmarshal does not invert Marshal")
+	}
+
+	var identity G2Point
+	if !identity.IsInfinity() || !decoded.Unmarshal(identity.Marshal()) || !decoded.IsInfinity() {
+		t.Fatal("The point at infinity does not round trip")
+	}
+
+	bad := p.Marshal()
+	bad[127] ^= 1
+	if decoded.Unmarshal(bad) {
+		t.Fatal("Unmarshal accepted a point off the curve")
+	}
+
+	if err := json.Unmarshal([]byte(`{"x": ["0x1"], "y": ["0x1", "0x2"]}`), &decoded); err == nil {
+		t.Fatal("Parsed a G2 point with a missing coordinate")
+	}
+}
+
+func TestBLSVerifyTrusted(t *testing.T) {
+	coordinators := generateBLSKeys(t, 3)
+	trusted := make([]G2Point, len(coordinators))
+	for i, key := range coordinators {
+		trusted[i] = key.Public
+	}
+	ring := []byte("ring")
+
+	aggregate := func(keys []*BLSKey) *BLSSignature {
+		var sigs []BLSSignature
+		for _, key := range keys {
+			sig, err := BLSSign(key.Secret, ring)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sigs = append(sigs, *sig)
+		}
+		sig, err := BLSAggregate(sigs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+
+	sig := aggregate(coordinators[:2])
+	if err := sig.VerifyTrusted(trusted, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := sig.VerifyTrusted(trusted, 3); err == nil {
+		t.Fatal("Two signers met a threshold of three")
+	}
+
+	// A valid aggregate of keys anyone could generate
+	untrusted := aggregate(generateBLSKeys(t, 3))
+	if !untrusted.Verify() {
+		t.Fatal("Aggregate of untrusted keys not verified")
+	}
+	if err := untrusted.VerifyTrusted(trusted, 1); err == nil {
+		t.Fatal("Accepted an aggregate of untrusted keys")
+	}
+
+	// Trusted signers can't make up for an untrusted one
+	mixed := aggregate(append(generateBLSKeys(t, 1), coordinators[:2]...))
+	if err := mixed.VerifyTrusted(trusted, 2); err == nil {
+		t.Fatal("Accepted an aggregate with an untrusted signer")
+	}
+
+	// A trusted key which signed two messages counts once
+	other, err := BLSSign(coordinators[0].Secret, []byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := BLSSign(coordinators[0].Secret, ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	twice, err := BLSAggregate([]BLSSignature{*first, *other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := twice.VerifyTrusted(trusted, 2); err == nil {
+		t.Fatal("One trusted key counted twice")
+	}
+
+	if err := sig.VerifyTrusted(trusted, 0); err == nil {
+		t.Fatal("Accepted a threshold of zero")
+	}
+}
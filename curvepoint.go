@@ -11,32 +11,53 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/clearmatics/bn256"
+	"io"
 	"math/big"
 )
 
-// CurvePoint represents a point on an elliptic curve
+// CurvePoint represents a point on an elliptic curve, of any of the
+// supported groups. The zero value is the point at infinity of BN256, so the
+// static methods such as CurvePoint{}.Order() give its parameters.
 type CurvePoint struct {
-	z *bn256.G1
+	p Point
 }
 
-// MarshalJSON converts a CurvePoint to a JSON representation
+// point returns the implementation of c, the zero value is the point at
+// infinity of BN256
+func (c CurvePoint) point() Point {
+	if c.p == nil {
+		return BN256.Infinity().p
+	}
+	return c.p
+}
+
+// MarshalJSON converts a CurvePoint to a JSON representation, the curve is
+// only recorded for points which are not on BN256. The point at infinity
+// has the coordinates (0, 0), as in the binary encoding.
 func (c *CurvePoint) MarshalJSON() ([]byte, error) {
 	x, y := c.GetXY()
+	curve := ""
+	if c.Group() != BN256 {
+		curve = c.Group().Name()
+	}
 	return json.Marshal(&struct {
-		X *hexBig `json:"x"`
-		Y *hexBig `json:"y"`
+		X     *hexBig `json:"x"`
+		Y     *hexBig `json:"y"`
+		Curve string  `json:"curve,omitempty"`
 	}{
-		X: (*hexBig)(x),
-		Y: (*hexBig)(y),
+		X:     (*hexBig)(x),
+		Y:     (*hexBig)(y),
+		Curve: curve,
 	})
 }
 
-// UnmarshalJSON converts a JSON representation to a CurvePoint struct
+// UnmarshalJSON converts a JSON representation to a CurvePoint struct, the
+// coordinates (0, 0) are the point at infinity
 func (c *CurvePoint) UnmarshalJSON(data []byte) error {
 	var aux struct {
-		X *hexBig `json:"x"`
-		Y *hexBig `json:"y"`
+		X     *hexBig `json:"x"`
+		Y     *hexBig `json:"y"`
+		Curve string  `json:"curve"`
 	}
 
 	err := json.Unmarshal(data, &aux)
@@ -48,26 +69,42 @@ func (c *CurvePoint) UnmarshalJSON(data []byte) error {
 		return errors.New("Invalid Point, no X or Y specified")
 	}
 
-	if c.SetFromXY((*big.Int)(aux.X), (*big.Int)(aux.Y)) == nil {
+	g, err := CurveByName(aux.Curve)
+	if err != nil {
+		return err
+	}
+
+	p := pointFromXY(g, (*big.Int)(aux.X), (*big.Int)(aux.Y))
+	if p == nil {
 		return errors.New("Failed to deserialize CurvePoint")
 	}
+	*c = *p
 
 	return nil
 }
 
-// Equals returns true if X and Y of both curve points are equal
+// Group returns the group the point belongs to
+func (c CurvePoint) Group() Group {
+	if c.p == nil {
+		return BN256
+	}
+	return c.p.Group()
+}
+
+// Equals returns true if both curve points are in the same group and their
+// X and Y are equal
 func (c CurvePoint) Equals(d *CurvePoint) bool {
-	return bytes.Compare(c.Marshal(), d.Marshal()) == 0
+	return c.Group() == d.Group() && bytes.Compare(c.Marshal(), d.Marshal()) == 0
 }
 
-// Prime returns the prime component of the BN256 curve
+// Prime returns the prime component of the curve
 func (c CurvePoint) Prime() *big.Int {
-	return bn256.P
+	return c.Group().Prime()
 }
 
-// Order returns the order component of the BN256 curve
+// Order returns the order component of the curve
 func (c CurvePoint) Order() *big.Int {
-	return bn256.Order
+	return c.Group().Order()
 }
 
 // isBetween checks number is within range of (lower,upper)
@@ -77,81 +114,100 @@ func isBetween(number *big.Int, lower *big.Int, upper *big.Int) bool {
 }
 
 // randomPositiveBelow generates a uniformly random number between 1 and `below`
-func randomPositiveBelow(below *big.Int) *big.Int {
+// using the random source
+func randomPositiveBelow(random io.Reader, below *big.Int) (*big.Int, error) {
 	for {
-		number, err := rand.Int(rand.Reader, bn256.Order)
+		number, err := rand.Int(random, below)
 		if err != nil {
-			return nil
+			return nil, err
 		}
 
 		// x > 0 && x < below
 		if isBetween(number, bigZero, below) {
-			return number
+			return number, nil
 		}
 	}
 }
 
-// RandomN returns a uniformly random integer between 1 and P-1
-func (c CurvePoint) RandomN() *big.Int {
-	return randomPositiveBelow(c.Order())
+// RandomN returns a uniformly random integer between 1 and N-1
+func (c CurvePoint) RandomN(random io.Reader) (*big.Int, error) {
+	return randomPositiveBelow(random, c.Order())
 }
 
 // RandomP returns a uniformly random integer between 1 and P-1
-func (c CurvePoint) RandomP() *big.Int {
-	return randomPositiveBelow(c.Prime())
+func (c CurvePoint) RandomP(random io.Reader) (*big.Int, error) {
+	return randomPositiveBelow(random, c.Prime())
 }
 
-// GetXY returns the X and Y coordinates for a given CurvePoint
+// GetXY returns the X and Y coordinates for a given CurvePoint, both are
+// zero for the point at infinity
 func (c CurvePoint) GetXY() (*big.Int, *big.Int) {
 	// Each value is a 256-bit number.
 	const numBytes = 256 / 8
-	if c.z != nil {
-		m := c.z.Marshal()
-		x := new(big.Int).SetBytes(m[0*numBytes : 1*numBytes])
-		y := new(big.Int).SetBytes(m[1*numBytes : 2*numBytes])
-		return x, y
-	}
-	return nil, nil
+	m := c.Marshal()
+	x := new(big.Int).SetBytes(m[0*numBytes : 1*numBytes])
+	y := new(big.Int).SetBytes(m[1*numBytes : 2*numBytes])
+	return x, y
 }
 
 // SetFromXY returns a CurvePoint based on the provided x and Y coordinates
+// in the group of c
 func (c *CurvePoint) SetFromXY(x *big.Int, y *big.Int) *CurvePoint {
-	const numBytes = 256 / 8
-
-	// XXX: there's no equivalent to SetCurvePoints, other than Unmarshal
-	xBytes := new(big.Int).Mod(x, bn256.P).Bytes()
-	yBytes := new(big.Int).Mod(y, bn256.P).Bytes()
+	p := pointFromXY(c.Group(), x, y)
+	if p == nil {
+		return nil
+	}
+	*c = *p
+	return c
+}
 
-	m := make([]byte, numBytes*2)
-	copy(m[1*numBytes-len(xBytes):], xBytes)
-	copy(m[2*numBytes-len(yBytes):], yBytes)
+// Marshal converts a CurvePoint to its 64 byte binary representation, the
+// affine coordinates as big endian integers, all zeros for the point at
+// infinity
+func (c CurvePoint) Marshal() []byte {
+	return c.point().Marshal()
+}
 
-	z, isOk := new(bn256.G1).Unmarshal(m)
-	if isOk {
-		c.z = z
-		return c
+// Unmarshal sets c to the point of its group with the binary representation
+// m, it returns false and leaves c unchanged if m isn't a point of the group
+func (c *CurvePoint) Unmarshal(m []byte) bool {
+	p, ok := c.Group().Unmarshal(m)
+	if ok {
+		*c = p
 	}
-	return nil
+	return ok
 }
 
-// Marshal converts a CurvePoint to a JSON representation
-func (c CurvePoint) Marshal() []byte {
-	return c.z.Marshal()
+// Identity returns the point at infinity of the group of c, the identity
+// element of the group
+func (c CurvePoint) Identity() CurvePoint {
+	return c.Group().Infinity()
 }
 
-// Unmarshal converts a JSON representation to a CurvePoint struct
-func (c CurvePoint) Unmarshal(m []byte) bool {
-	_, ret := c.z.Unmarshal(m)
-	return ret
+// IsInfinity returns true if the point is the identity element
+func (c CurvePoint) IsInfinity() bool {
+	return c.point().IsInfinity()
 }
 
-// IsOnCurve returns true if point is on curve
+// IsOnCurve returns true if point is on curve, including the point at infinity
 func (c CurvePoint) IsOnCurve() bool {
-	return c.z.IsOnCurve()
+	return c.point().IsOnCurve()
+}
+
+// IsInSubgroup returns true if the point is on the curve and in the prime
+// order group, where N·c = ∞. Multiplying by N would be reduced to zero, so
+// it is computed as (N-1)·c + c.
+//
+func (c CurvePoint) IsInSubgroup() bool {
+	if false == c.IsOnCurve() {
+		return false
+	}
+	nMinusOne := new(big.Int).Sub(c.Order(), bigOne)
+	return c.ScalarMult(nMinusOne).Add(c).IsInfinity()
 }
 
 func (c CurvePoint) String() string {
-	return fmt.Sprintf("CurvePoint(%v)", c.z)
+	return fmt.Sprintf("CurvePoint(%v)", c.point())
 }
 
 // NewCurvePointFromString create a CurvePoint from a string representation
@@ -163,90 +219,69 @@ func NewCurvePointFromString(s []byte) *CurvePoint {
 // hashing into a curve which preserves random oracle proofs of security
 //
 func NewCurvePointFromHash(h [sha256.Size]byte) *CurvePoint {
-	P := CurvePoint{}.Prime()
-	N := CurvePoint{}.Order()
-
-	// (p+1) / 1
-	A, _ := new(big.Int).SetString("c19139cb84c680a6e14116da060561765e05aa45a1c72a34f082305b61f3f52", 16)
-
-	x := new(big.Int).SetBytes(h[:])
-	x.Mod(x, N)
-
-	// TODO: limit number of iterations?
-	// y² = x³ + B
-	for {
-		xx := new(big.Int).Mul(x, x) // x²
-		xx.Mod(xx, P)
-
-		xxx := xx.Mul(xx, x) // x³
-		xxx.Mod(xxx, P)
-
-		beta := new(big.Int).Add(xxx, curveB) // x³ + B
-		beta.Mod(beta, P)
-
-		//y := new(big.Int).ModSqrt(t, P)		// y = √(x³+B)
-		y := new(big.Int).Exp(beta, A, P)
-
-		if y != nil {
-			// Then verify (√(x³+B)%P)² == (x³+B)%P
-			z := new(big.Int).Mul(y, y)
-			z.Mod(z, P)
-			if z.Cmp(beta) == 0 {
-				curveout := new(CurvePoint).SetFromXY(x, y)
-				if curveout != nil {
-					return curveout
-				}
-			}
-
-		}
-		x.Add(x, bigOne)
-	}
+	// Not BN256.HashToPoint, package variables such as the generators of
+	// the one-of-many proofs are hashed before the ones the interface
+	// method depends on would be initialized
+	p := hashToCurve(BN256, curveB, h)
+	return &p
 }
 
 // ScalarBaseMult returns the product x where the result and base are the x coordinates of group points, base is the standard generator
 func (c CurvePoint) ScalarBaseMult(x *big.Int) CurvePoint {
-	return CurvePoint{new(bn256.G1).ScalarBaseMult(x)}
+	return c.Group().ScalarBaseMult(x)
 }
 
-// ScalarMult returns the product c*x where the result and base are the x coordinates of group points
+// ScalarMult returns the product c*x where the result and base are the x coordinates of group points,
+// x may be negative or larger than the order
 func (c CurvePoint) ScalarMult(x *big.Int) CurvePoint {
-	return CurvePoint{new(bn256.G1).ScalarMult(c.z, x)}
+	if N := c.Order(); x.Sign() < 0 || x.Cmp(N) >= 0 {
+		x = new(big.Int).Mod(x, N)
+	}
+	return CurvePoint{c.point().ScalarMult(x)}
 }
 
 // Add performs an addition of two elliptic curve points
 func (c CurvePoint) Add(y CurvePoint) CurvePoint {
-	return CurvePoint{new(bn256.G1).Add(c.z, y.z)}
+	return CurvePoint{c.point().Add(y.point())}
+}
+
+// Neg returns the negation of the point, -c
+func (c CurvePoint) Neg() CurvePoint {
+	return CurvePoint{c.point().Neg()}
+}
+
+// Sub returns the difference of two elliptic curve points, c - y
+func (c CurvePoint) Sub(y CurvePoint) CurvePoint {
+	return c.Add(y.Neg())
 }
 
 // ParameterPointAdd returns the addition of c scaled by cj and tj as a curve point
 func (c CurvePoint) ParameterPointAdd(tj *big.Int, cj *big.Int) CurvePoint {
-	a := CurvePoint{}.ScalarBaseMult(tj)
-	pk := c.ScalarMult(cj)
+	a := c.ScalarBaseMult(tj)
+	pk := MultiScalarMult(c.Group(), []CurvePoint{c}, []*big.Int{cj})
 
 	return a.Add(pk)
 }
 
 // HashPointAdd returns the addition of hashSP scaled by cj and c scaled by tj
 func (c CurvePoint) HashPointAdd(hashSP CurvePoint, tj *big.Int, cj *big.Int) CurvePoint {
-	b := c.ScalarMult(tj)
-	bj := hashSP.ScalarMult(cj)
-
-	return b.Add(bj)
+	return MultiScalarMult(c.Group(), []CurvePoint{c, hashSP}, []*big.Int{tj, cj})
 }
 
 // ParseCurvePoint parses string representations of X and Y points
 // these can be hex or base10 encoded
 func ParseCurvePoint(pointX string, pointY string) *CurvePoint {
+	return ParseGroupPoint(BN256, pointX, pointY)
+}
+
+// ParseGroupPoint parses string representations of X and Y points of a
+// point in the group g
+func ParseGroupPoint(g Group, pointX string, pointY string) *CurvePoint {
 	x, errX := ParseBigInt(pointX)
 	y, errY := ParseBigInt(pointY)
 	if nil != errX || nil != errY {
 		return nil
 	}
 
-	c := CurvePoint{}
-	if c.SetFromXY(x, y) != nil {
-		return &c
-	}
-
-	return nil
+	return pointFromXY(g, x, y)
 }
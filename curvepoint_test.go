@@ -1,13 +1,16 @@
 package main
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"math/big"
 	"testing"
 )
 
 func TestCurvepointGenerate(t *testing.T) {
-	Ap, As, err := generateKeyPair()
+	Ap, As, err := generateKeyPair(rand.Reader)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -71,3 +74,143 @@ func TestCurvepointBaseMult(t *testing.T) {
 		t.Fatal("Points not equal after serialize > unserialize", b, c)
 	}
 }
+
+// failingReader is a random source which always fails
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("no entropy")
+}
+
+func TestRandomSourceError(t *testing.T) {
+	if _, err := (CurvePoint{}).RandomN(failingReader{}); err == nil {
+		t.Fatal("RandomN should fail when the random source fails")
+	}
+	if _, _, err := generateKeyPair(failingReader{}); err == nil {
+		t.Fatal("generateKeyPair should fail when the random source fails")
+	}
+
+	var r Ring
+	if err := r.Generate(failingReader{}, 2); err == nil {
+		t.Fatal("Generate should fail when the random source fails")
+	}
+
+	r = generateRing(2)
+	if _, err := r.Signature(failingReader{}, r.PrivKeys[0], []byte("foobarbaz"), 0); err == nil {
+		t.Fatal("Signature should fail when the random source fails")
+	}
+}
+
+func TestRandomPBelowPrime(t *testing.T) {
+	// RandomP used to draw below the group order rather than the prime
+	random := newHmacDRBG([]byte("seed"))
+	for i := 0; i < 64; i++ {
+		x, err := CurvePoint{}.RandomP(random)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x.Sign() <= 0 || x.Cmp(CurvePoint{}.Prime()) >= 0 {
+			t.Fatalf("RandomP out of range: %v", x)
+		}
+	}
+}
+
+func TestCurvePointArithmetic(t *testing.T) {
+	for _, g := range Curves {
+		a, _ := randomScalar(g, rand.Reader)
+		b, _ := randomScalar(g, rand.Reader)
+		A := g.ScalarBaseMult(a)
+		B := g.ScalarBaseMult(b)
+		identity := A.Identity()
+
+		if !identity.IsInfinity() || identity.Group() != g {
+			t.Fatalf("%v: Identity is not the point at infinity", g.Name())
+		}
+		if !A.Add(identity).Equals(&A) {
+			t.Fatalf("%v: A + ∞ != A", g.Name())
+		}
+		if !A.Add(A.Neg()).IsInfinity() || !A.Sub(A).IsInfinity() {
+			t.Fatalf("%v: A - A != ∞", g.Name())
+		}
+		if !identity.Neg().IsInfinity() {
+			t.Fatalf("%v: -∞ != ∞", g.Name())
+		}
+
+		difference := g.ScalarBaseMult(new(big.Int).Sub(a, b))
+		if !A.Sub(B).Equals(&difference) {
+			t.Fatalf("%v: aG - bG != (a-b)G", g.Name())
+		}
+
+		// Negative scalars and scalars larger than the order
+		negA := A.Neg()
+		if product := g.ScalarBaseMult(bigOne).ScalarMult(new(big.Int).Neg(a)); !product.Equals(&negA) {
+			t.Fatalf("%v: G·-a != -A", g.Name())
+		}
+		if product := g.ScalarBaseMult(new(big.Int).Neg(a)); !product.Equals(&negA) {
+			t.Fatalf("%v: ScalarBaseMult(-a) != -A", g.Name())
+		}
+		aB := B.ScalarMult(a)
+		if product := B.ScalarMult(new(big.Int).Add(a, g.Order())); !product.Equals(&aB) {
+			t.Fatalf("%v: B·(a+N) != B·a", g.Name())
+		}
+
+		if !A.IsInSubgroup() || !identity.IsInSubgroup() {
+			t.Fatalf("%v: point not in the subgroup", g.Name())
+		}
+	}
+
+	// The zero value is the identity of BN256
+	var zero CurvePoint
+	if !zero.IsInfinity() || zero.Group() != BN256 || !zero.IsOnCurve() {
+		t.Fatal("The zero value is not the point at infinity")
+	}
+}
+
+func TestCurvePointInfinityEncoding(t *testing.T) {
+	for _, g := range Curves {
+		identity := g.Infinity()
+
+		m := identity.Marshal()
+		for _, b := range m {
+			if b != 0 {
+				t.Fatalf("%v: infinity is not encoded as zeros: %x", g.Name(), m)
+			}
+		}
+
+		p := g.ScalarBaseMult(bigOne)
+		if !p.Unmarshal(m) || !p.IsInfinity() {
+			t.Fatalf("%v: zeros not decoded as infinity", g.Name())
+		}
+
+		// Unmarshal updates the point, and leaves it unchanged on failure
+		G := g.ScalarBaseMult(bigOne)
+		if !p.Unmarshal(G.Marshal()) || !p.Equals(&G) {
+			t.Fatalf("%v: Unmarshal did not set the point", g.Name())
+		}
+		if p.Unmarshal(m[1:]) || !p.Equals(&G) {
+			t.Fatalf("%v: Unmarshal modified the point on failure", g.Name())
+		}
+
+		data, err := json.Marshal(&identity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded CurvePoint
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.IsInfinity() || decoded.Group() != g {
+			t.Fatalf("%v: infinity differs after a JSON round trip: %s", g.Name(), data)
+		}
+
+		if isValidPublicKey(&identity) {
+			t.Fatalf("%v: infinity accepted as a public key", g.Name())
+		}
+	}
+
+	// A ring can't contain the point at infinity
+	var r Ring
+	if err := json.Unmarshal([]byte(`{"pubkeys": [{"x": "0x0", "y": "0x0"}], "privkeys": []}`), &r); err == nil {
+		t.Fatal("Loaded a ring with the point at infinity")
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// dleqLabel domain separates the challenges of DLEQ proofs
+var dleqLabel = []byte("orbital-dleq")
+
+// tauProofLabel domain separates the context of proofs of a tau
+var tauProofLabel = []byte("orbital-tau-proof")
+
+// A transcript accumulates the public values of a non-interactive proof,
+// which are hashed into its Fiat-Shamir challenge. Every value is length
+// prefixed after its own label, so values can't be moved between fields
+// and two different transcripts never hash the same data.
+//
+type transcript struct {
+	g    Group
+	data []byte
+}
+
+// newTranscript starts a transcript for a proof in the group g, the label
+// domain separates the challenges of different proofs
+func newTranscript(g Group, label []byte) *transcript {
+	t := &transcript{g: g, data: appendLengthPrefixed(nil, label)}
+	t.append("curve", []byte(g.Name()))
+	return t
+}
+
+// append adds a labeled value to the transcript
+func (t *transcript) append(label string, value []byte) {
+	t.data = appendLengthPrefixed(t.data, []byte(label))
+	t.data = appendLengthPrefixed(t.data, value)
+}
+
+// appendPoint adds a labeled point to the transcript
+func (t *transcript) appendPoint(label string, p *CurvePoint) {
+	t.append(label, p.Marshal())
+}
+
+// challenge hashes the transcript into an integer modulo the group order.
+// The challenge is appended to the transcript, so a proof with several
+// rounds gets a different challenge for each even if nothing else is added
+// in between.
+//
+func (t *transcript) challenge() *big.Int {
+	h := sha256.Sum256(t.data)
+	x := new(big.Int).SetBytes(h[:])
+	x.Mod(x, t.g.Order())
+	t.append("challenge", x.Bytes())
+	return x
+}
+
+// challengeScalar returns the next challenge as a scalar
+func (t *transcript) challengeScalar() Scalar {
+	return NewScalar(t.g, t.challenge())
+}
+
+// A DLEQProof is a Chaum-Pedersen proof that two points have the same
+// discrete log to two bases, A = G·x and B = H·x, without revealing x. It is
+// represented by the challenge and the response.
+//
+type DLEQProof struct {
+	C *big.Int `json:"c"`
+	S *big.Int `json:"s"`
+}
+
+// MarshalJSON converts a DLEQProof to a JSON representation
+func (p *DLEQProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		C *hexBig `json:"c"`
+		S *hexBig `json:"s"`
+	}{
+		C: (*hexBig)(p.C),
+		S: (*hexBig)(p.S),
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a DLEQProof struct
+func (p *DLEQProof) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		C *hexBig `json:"c"`
+		S *hexBig `json:"s"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.C == nil || aux.S == nil {
+		return errors.New("Invalid proof, no c or s specified")
+	}
+
+	p.C = (*big.Int)(aux.C)
+	p.S = (*big.Int)(aux.S)
+	return nil
+}
+
+// dleqChallenge computes c ← H(G, H, A, B, R1, R2, context)
+func dleqChallenge(G, H, A, B *CurvePoint, R1, R2 CurvePoint, context []byte) *big.Int {
+	t := newTranscript(G.Group(), dleqLabel)
+	t.appendPoint("G", G)
+	t.appendPoint("H", H)
+	t.appendPoint("A", A)
+	t.appendPoint("B", B)
+	t.appendPoint("R1", &R1)
+	t.appendPoint("R2", &R2)
+	t.append("context", context)
+	return t.challenge()
+}
+
+// sameGroup returns true if all of the points are in the group g
+func sameGroup(g Group, points ...*CurvePoint) bool {
+	for _, p := range points {
+		if p.Group() != g {
+			return false
+		}
+	}
+	return true
+}
+
+// NewDLEQProof proves that A = G·x and B = H·x have the same discrete log,
+// the context is included in the challenge:
+//
+//   k ← random
+//   c ← H(G, H, A, B, G·k, H·k, context)
+//   s ← k - c·x
+//
+func NewDLEQProof(random io.Reader, x Scalar, G, H *CurvePoint, context []byte) (*DLEQProof, error) {
+	if G == nil || H == nil {
+		return nil, errors.New("No base points provided")
+	}
+	g := x.Group()
+	if false == sameGroup(g, G, H) {
+		return nil, errors.New("Secret key and base points are in different groups")
+	}
+	if false == isValidPublicKey(G) || false == isValidPublicKey(H) {
+		return nil, errors.New("Invalid base point")
+	}
+	if x.IsZero() {
+		return nil, errors.New("Invalid secret key")
+	}
+
+	k, err := RandomScalar(g, random)
+	if err != nil {
+		return nil, err
+	}
+
+	A := G.ScalarMult(x.Int())
+	B := H.ScalarMult(x.Int())
+	c := dleqChallenge(G, H, &A, &B, G.ScalarMult(k.Int()), H.ScalarMult(k.Int()), context)
+	s := k.Sub(NewScalar(g, c).Mul(x))
+
+	return &DLEQProof{c, s.Int()}, nil
+}
+
+// Verify checks that A and B have the same discrete log to the bases G and
+// H, with the context the proof was made with:
+//
+//   c = H(G, H, A, B, G·s + A·c, H·s + B·c, context)
+//
+func (p *DLEQProof) Verify(G, H, A, B *CurvePoint, context []byte) bool {
+	if p == nil || p.C == nil || p.S == nil || G == nil || H == nil || A == nil || B == nil {
+		return false
+	}
+	if false == sameGroup(G.Group(), H, A, B) {
+		return false
+	}
+	N := G.Order()
+	if p.C.Sign() < 0 || p.C.Cmp(N) >= 0 || p.S.Sign() < 0 || p.S.Cmp(N) >= 0 {
+		return false
+	}
+	for _, point := range []*CurvePoint{G, H, A, B} {
+		if false == isValidPublicKey(point) {
+			return false
+		}
+	}
+
+	R1 := MultiScalarMult(G.Group(), []CurvePoint{*G, *A}, []*big.Int{p.S, p.C})
+	R2 := MultiScalarMult(G.Group(), []CurvePoint{*H, *B}, []*big.Int{p.S, p.C})
+	return dleqChallenge(G, H, A, B, R1, R2, context).Cmp(p.C) == 0
+}
+
+// A TauProof shows which member of a ring produced a tau, the key image of
+// the ctlist and lsag signatures, by proving the public key and tau have
+// the same discrete log:
+//
+//   pk = g·x, tau = H(m)·x
+//
+// Signatures are anonymous, so only the signer can make the proof, and
+// doing so is voluntary. An optional context, such as an audit reference,
+// is bound to the proof.
+//
+type TauProof struct {
+	Public  CurvePoint `json:"public"`
+	Tau     CurvePoint `json:"tau"`
+	Message []byte     `json:"message"`
+	Context []byte     `json:"context"`
+	Proof   *DLEQProof `json:"proof"`
+}
+
+// tauProofContext binds the proof to the message and the context
+func tauProofContext(message []byte, context []byte) []byte {
+	data := appendLengthPrefixed(nil, tauProofLabel)
+	data = appendLengthPrefixed(data, message)
+	return appendLengthPrefixed(data, context)
+}
+
+// NewTauProof proves that the tau of signatures of the message by the
+// secret key x was produced by its public key
+func NewTauProof(random io.Reader, x Scalar, message []byte, context []byte) (*TauProof, error) {
+	g := x.Group()
+	G := g.ScalarBaseMult(bigOne)
+	H := groupMessagePoint(g, message)
+
+	proof, err := NewDLEQProof(random, x, &G, H, tauProofContext(message, context))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TauProof{
+		Public:  G.ScalarMult(x.Int()),
+		Tau:     H.ScalarMult(x.Int()),
+		Message: message,
+		Context: context,
+		Proof:   proof,
+	}, nil
+}
+
+// Verify checks the tau was produced by the public key
+func (p *TauProof) Verify() bool {
+	if p.Public.p == nil || p.Tau.p == nil {
+		return false
+	}
+
+	g := p.Public.Group()
+	G := g.ScalarBaseMult(bigOne)
+	H := groupMessagePoint(g, p.Message)
+	return p.Proof.Verify(&G, H, &p.Public, &p.Tau, tauProofContext(p.Message, p.Context))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestDLEQProof(t *testing.T) {
+	for _, g := range Curves {
+		x, err := RandomScalar(g, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		G := g.ScalarBaseMult(bigOne)
+		H := groupMessagePoint(g, []byte("foobarbaz"))
+		A := G.ScalarMult(x.Int())
+		B := H.ScalarMult(x.Int())
+		context := []byte("context")
+
+		proof, err := NewDLEQProof(rand.Reader, x, &G, H, context)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proof.Verify(&G, H, &A, &B, context) {
+			t.Fatalf("%v: valid proof not verified", g.Name())
+		}
+
+		if proof.Verify(&G, H, &A, &B, []byte("other")) {
+			t.Fatalf("%v: proof verified with a different context", g.Name())
+		}
+		if proof.Verify(H, &G, &B, &A, context) {
+			t.Fatalf("%v: proof verified with the bases swapped", g.Name())
+		}
+
+		// A point with a different discrete log
+		other := H.ScalarMult(new(big.Int).Add(x.Int(), bigOne))
+		if proof.Verify(&G, H, &A, &other, context) {
+			t.Fatalf("%v: proof verified for points with different discrete logs", g.Name())
+		}
+
+		identity := g.Infinity()
+		if proof.Verify(&G, H, &A, &identity, context) {
+			t.Fatalf("%v: proof verified with the point at infinity", g.Name())
+		}
+
+		tampered := &DLEQProof{proof.C, new(big.Int).Add(proof.S, g.Order())}
+		if tampered.Verify(&G, H, &A, &B, context) {
+			t.Fatalf("%v: proof verified with an out of range response", g.Name())
+		}
+	}
+}
+
+func TestDLEQProofMixedGroups(t *testing.T) {
+	x, err := RandomScalar(BN256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	G := BN256.ScalarBaseMult(bigOne)
+	H := Secp256k1.ScalarBaseMult(bigOne)
+	if _, err := NewDLEQProof(rand.Reader, x, &G, &H, nil); err == nil {
+		t.Fatal("Proved a DLEQ across groups")
+	}
+}
+
+func TestTauProof(t *testing.T) {
+	for _, g := range Curves {
+		var r Ring
+		r.Curve = g
+		if err := r.Generate(rand.Reader, 4); err != nil {
+			t.Fatal(err)
+		}
+		message := []byte("foobarbaz")
+
+		sig, err := r.Signature(rand.Reader, r.PrivKeys[2], message, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := NewTauProof(rand.Reader, r.PrivKeys[2], message, []byte("audit"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proof.Tau.Equals(&sig.Tau) || !proof.Public.Equals(&r.PubKeys[2]) {
+			t.Fatalf("%v: proof is not of the tau and key of the signer", g.Name())
+		}
+
+		data, err := json.Marshal(proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var loaded TauProof
+		if err := json.Unmarshal(data, &loaded); err != nil {
+			t.Fatal(err)
+		}
+		if !loaded.Verify() {
+			t.Fatalf("%v: proof not verified after a JSON round trip: %s", g.Name(), data)
+		}
+
+		// Claiming the tau was produced by another member
+		loaded.Public = r.PubKeys[1]
+		if loaded.Verify() {
+			t.Fatalf("%v: proof verified for another ring member", g.Name())
+		}
+
+		loaded = *proof
+		loaded.Message = []byte("other")
+		if loaded.Verify() {
+			t.Fatalf("%v: proof verified for another message", g.Name())
+		}
+
+		loaded = *proof
+		loaded.Context = nil
+		if loaded.Verify() {
+			t.Fatalf("%v: proof verified without its context", g.Name())
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"math/big"
+)
+
+// An hmacDRBG is the HMAC_DRBG from NIST SP 800-90A using SHA256, without
+// reseeding. Its output is determined entirely by the seed material.
+type hmacDRBG struct {
+	k []byte
+	v []byte
+}
+
+// newHmacDRBG instantiates the generator from the seed material
+func newHmacDRBG(seed []byte) *hmacDRBG {
+	d := &hmacDRBG{
+		k: make([]byte, sha256.Size),
+		v: make([]byte, sha256.Size),
+	}
+	for i := range d.v {
+		d.v[i] = 0x01
+	}
+	d.update(seed)
+	return d
+}
+
+// update mixes data into the state of the generator:
+//
+//   K ← HMAC(K, V | 0x00 | data)
+//   V ← HMAC(K, V)
+//   K ← HMAC(K, V | 0x01 | data)
+//   V ← HMAC(K, V)
+//
+func (d *hmacDRBG) update(data []byte) {
+	for _, b := range []byte{0x00, 0x01} {
+		mac := hmac.New(sha256.New, d.k)
+		mac.Write(d.v)
+		mac.Write([]byte{b})
+		mac.Write(data)
+		d.k = mac.Sum(nil)
+
+		mac = hmac.New(sha256.New, d.k)
+		mac.Write(d.v)
+		d.v = mac.Sum(nil)
+
+		// The second round is skipped when there is no data
+		if len(data) == 0 {
+			break
+		}
+	}
+}
+
+// Read fills out with the output of the generator, it never fails
+func (d *hmacDRBG) Read(out []byte) (int, error) {
+	for n := 0; n < len(out); {
+		mac := hmac.New(sha256.New, d.k)
+		mac.Write(d.v)
+		d.v = mac.Sum(nil)
+		n += copy(out[n:], d.v)
+	}
+	d.update(nil)
+	return len(out), nil
+}
+
+// scalarBelow returns an integer in the range [1,N) in the manner of
+// RFC6979 (Section 3.2), candidates are drawn until one is in range
+func (d *hmacDRBG) scalarBelow(N *big.Int) *big.Int {
+	buf := make([]byte, (N.BitLen()+7)/8)
+	for {
+		d.Read(buf)
+		k := new(big.Int).SetBytes(buf)
+		k.Rsh(k, uint(len(buf)*8-N.BitLen()))
+		if k.Sign() > 0 && k.Cmp(N) < 0 {
+			return k
+		}
+	}
+}
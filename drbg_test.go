@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestHmacDRBGRFC6979(t *testing.T) {
+	// RFC6979 A.2.5, ECDSA with P-256 and SHA-256, message "sample"
+	q := elliptic.P256().Params().N
+	x, _ := new(big.Int).SetString("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721", 16)
+	expected := "a6e3c57dd01abe90086538398355dd4c3b17aa873382b0f24d6129493d8aad60"
+
+	h := sha256.Sum256([]byte("sample"))
+	z := new(big.Int).SetBytes(h[:])
+	z.Mod(z, q)
+
+	seed := append(paddedBigBytes(x, 32), paddedBigBytes(z, 32)...)
+	k := newHmacDRBG(seed).scalarBelow(q)
+
+	actual := fmt.Sprintf("%064x", k)
+	if actual != expected {
+		t.Fatalf("Expected %v but got %v", expected, actual)
+	}
+}
+
+func TestHmacDRBGScalarBelow(t *testing.T) {
+	N := CurvePoint{}.Order()
+
+	a := newHmacDRBG([]byte("seed"))
+	b := newHmacDRBG([]byte("seed"))
+	for i := 0; i < 10; i++ {
+		x := a.scalarBelow(N)
+		if x.Cmp(b.scalarBelow(N)) != 0 {
+			t.Fatal("Same seed gave different scalars")
+		}
+		if x.Sign() <= 0 || x.Cmp(N) >= 0 {
+			t.Fatalf("Scalar out of range: %v", x)
+		}
+	}
+
+	if a.scalarBelow(N).Cmp(newHmacDRBG([]byte("other")).scalarBelow(N)) == 0 {
+		t.Fatal("Different seeds gave the same scalar")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// eciesLabel is the HKDF salt and domain separator for ECIES keys
+var eciesLabel = []byte("orbital-ecies-v1")
+
+// An EncryptedMessage can only be decrypted by the owner of the secret
+// key for the public key it was encrypted to
+type EncryptedMessage struct {
+	Ephemeral  CurvePoint `json:"ephemeral"`
+	Ciphertext []byte     `json:"ciphertext"`
+}
+
+// eciesCipher derives the AES-256-GCM cipher and nonce from the ECDH shared
+// secret, the ephemeral and the recipient public keys:
+//
+//   key ‖ nonce ← HKDF(salt=label, IKM=secret, info=label ‖ E ‖ Y)
+//
+// Each ephemeral key is used for exactly one message, so the nonce can be
+// derived rather than randomly generated.
+//
+func eciesCipher(sharedSecret []byte, ephemeral *CurvePoint, recipient *CurvePoint) (cipher.AEAD, []byte, error) {
+	info := append(append([]byte{}, eciesLabel...), ephemeral.Marshal()...)
+	info = append(info, recipient.Marshal()...)
+
+	okm, err := hkdf(eciesLabel, sharedSecret, info, 32+12)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, err := aes.NewCipher(okm[:32])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return aead, okm[32:], nil
+}
+
+// ECIESEncrypt encrypts a message to the holder of the secret key for pub
+// using an ephemeral key pair:
+//
+//   E ← g^e
+//   secret ← (Y · e).x
+//   ciphertext ← AES-GCM(KDF(secret), plaintext)
+//
+func ECIESEncrypt(random io.Reader, pub *CurvePoint, plaintext []byte) (*EncryptedMessage, error) {
+	if false == isValidPublicKey(pub) {
+		return nil, errors.New("Invalid public key provided")
+	}
+
+	ephemeral, e, err := newKeyPair(pub.Group(), random)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, nonce, err := eciesCipher(deriveSharedSecret(e, pub), ephemeral, pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EncryptedMessage{
+		Ephemeral:  *ephemeral,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, nil),
+	}, nil
+}
+
+// ECIESDecrypt decrypts a message which was encrypted to the public key
+// of priv, any modification of the message is detected
+func ECIESDecrypt(priv *big.Int, msg *EncryptedMessage) ([]byte, error) {
+	if msg == nil || false == isValidPublicKey(&msg.Ephemeral) {
+		return nil, errors.New("Invalid ephemeral public key")
+	}
+
+	if false == isValidScalar(msg.Ephemeral.Group(), priv) {
+		return nil, errors.New("Invalid secret key")
+	}
+
+	pub := msg.Ephemeral.ScalarBaseMult(priv)
+	aead, nonce, err := eciesCipher(deriveSharedSecret(priv, &msg.Ephemeral), &msg.Ephemeral, &pub)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, nonce, msg.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("Unable to decrypt message")
+	}
+
+	return plaintext, nil
+}
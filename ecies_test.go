@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+)
+
+func TestECIES(t *testing.T) {
+	pub, priv, err := generateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := ECIESEncrypt(rand.Reader, pub, testBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := ECIESDecrypt(priv, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, testBytes) {
+		t.Fatalf("Expected %v but got %v", testBytes, plaintext)
+	}
+
+	_, other, _ := generateKeyPair(rand.Reader)
+	if _, err := ECIESDecrypt(other, msg); err == nil {
+		t.Fatal("Message decrypted with the wrong secret key")
+	}
+}
+
+func TestECIESTampered(t *testing.T) {
+	pub, priv, _ := generateKeyPair(rand.Reader)
+	msg, err := ECIESEncrypt(rand.Reader, pub, testBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg.Ciphertext[0] ^= 1
+	if _, err := ECIESDecrypt(priv, msg); err == nil {
+		t.Fatal("Tampered ciphertext decrypted")
+	}
+	msg.Ciphertext[0] ^= 1
+
+	other, _, _ := generateKeyPair(rand.Reader)
+	msg.Ephemeral = *other
+	if _, err := ECIESDecrypt(priv, msg); err == nil {
+		t.Fatal("Message with substituted ephemeral key decrypted")
+	}
+}
+
+func TestECIESJSON(t *testing.T) {
+	pub, priv, _ := generateKeyPair(rand.Reader)
+	msg, err := ECIESEncrypt(rand.Reader, pub, testBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded EncryptedMessage
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := ECIESDecrypt(priv, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, testBytes) {
+		t.Fatalf("Expected %v but got %v", testBytes, plaintext)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/clearmatics/bn256"
+)
+
+// G2Point is a point of the G2 group of BN256, on the twist of the curve
+// over the quadratic extension field. It is only used for BLS public keys,
+// the pairing maps a point of G1 and one of G2 into GT. The zero value is
+// the point at infinity.
+type G2Point struct {
+	g *bn256.G2
+}
+
+// g2ScalarBaseMult returns the generator of G2 multiplied by k
+func g2ScalarBaseMult(k *big.Int) G2Point {
+	k = new(big.Int).Mod(k, bn256.Order)
+	return G2Point{new(bn256.G2).ScalarBaseMult(k)}
+}
+
+// point returns the bn256 point of p, the zero value is the point at
+// infinity
+func (p G2Point) point() *bn256.G2 {
+	if p.g == nil {
+		return new(bn256.G2).ScalarBaseMult(bigZero)
+	}
+	return p.g
+}
+
+// IsInfinity returns true if the point is the identity element
+func (p G2Point) IsInfinity() bool {
+	_, _, z, _ := p.point().CurvePoints()
+	return z.IsZero()
+}
+
+// Marshal converts a G2Point to its 128 byte binary representation, the
+// imaginary and real parts of x then of y as big endian integers, the order
+// the Ethereum pairing precompile expects. It is all zeros for the point at
+// infinity.
+//
+func (p G2Point) Marshal() []byte {
+	if p.IsInfinity() {
+		return make([]byte, 128)
+	}
+	return p.point().Marshal()
+}
+
+// Unmarshal sets p to the point with the binary representation m, it
+// returns false and leaves p unchanged if m isn't a point of G2. Unlike G1
+// the twist has points outside of the prime order group, so the order of
+// the point is checked too.
+//
+func (p *G2Point) Unmarshal(m []byte) bool {
+	g, ok := new(bn256.G2).Unmarshal(m)
+	if !ok {
+		return false
+	}
+
+	// N·g = ∞
+	_, _, z, _ := new(bn256.G2).ScalarMult(g, bn256.Order).CurvePoints()
+	if !z.IsZero() {
+		return false
+	}
+
+	p.g = g
+	return true
+}
+
+// Equals returns true if both points are the same
+func (p G2Point) Equals(q *G2Point) bool {
+	return bytes.Equal(p.Marshal(), q.Marshal())
+}
+
+func (p G2Point) String() string {
+	if p.IsInfinity() {
+		return "G2Point(∞)"
+	}
+	return fmt.Sprintf("G2Point(%v)", p.g)
+}
+
+// MarshalJSON converts a G2Point to a JSON representation, each coordinate
+// is a pair of the imaginary and real parts as in the binary encoding
+func (p *G2Point) MarshalJSON() ([]byte, error) {
+	const numBytes = 256 / 8
+	m := p.Marshal()
+
+	var parts [4]*hexBig
+	for i := range parts {
+		parts[i] = (*hexBig)(new(big.Int).SetBytes(m[i*numBytes : (i+1)*numBytes]))
+	}
+	return json.Marshal(&struct {
+		X [2]*hexBig `json:"x"`
+		Y [2]*hexBig `json:"y"`
+	}{
+		X: [2]*hexBig{parts[0], parts[1]},
+		Y: [2]*hexBig{parts[2], parts[3]},
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a G2Point struct
+func (p *G2Point) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		X []*hexBig `json:"x"`
+		Y []*hexBig `json:"y"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if len(aux.X) != 2 || len(aux.Y) != 2 {
+		return errors.New("Invalid G2 point, x and y must each have two parts")
+	}
+
+	var m []byte
+	for _, part := range append(aux.X, aux.Y...) {
+		v := (*big.Int)(part)
+		if v == nil || v.Sign() < 0 || v.Cmp(bn256.P) >= 0 {
+			return errors.New("Invalid G2 point, coordinate out of range")
+		}
+		m = append(m, paddedBigBytes(v, 32)...)
+	}
+
+	if !p.Unmarshal(m) {
+		return errors.New("Failed to deserialize G2Point")
+	}
+	return nil
+}
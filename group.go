@@ -0,0 +1,190 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// A Group is a prime order elliptic curve group. Ring signatures and stealth
+// addresses are written against it, so they can be computed on any of the
+// supported curves. Scalars are integers modulo the order of the group.
+type Group interface {
+	// Name identifies the group in JSON files and the -curve flag
+	Name() string
+
+	// Order returns the number of points in the group
+	Order() *big.Int
+
+	// Prime returns the modulus of the field the coordinates are in
+	Prime() *big.Int
+
+	// ScalarBaseMult returns the generator multiplied by k
+	ScalarBaseMult(k *big.Int) CurvePoint
+
+	// Infinity returns the point at infinity, the identity of the group
+	Infinity() CurvePoint
+
+	// HashToPoint maps a 256 bit hash onto the curve
+	HashToPoint(h [sha256.Size]byte) CurvePoint
+
+	// Unmarshal parses the 64 byte encoding of an affine point, returning
+	// false if it is not a point of the group
+	Unmarshal(m []byte) (CurvePoint, bool)
+}
+
+// A Point is the implementation of a CurvePoint in the group it belongs to,
+// operations on points of different groups panic
+type Point interface {
+	Group() Group
+	Add(q Point) Point
+	Neg() Point
+	ScalarMult(k *big.Int) Point
+	Marshal() []byte
+	IsInfinity() bool
+	IsOnCurve() bool
+	String() string
+
+	// jacobian returns the coordinates (x, y, z) of the affine point
+	// (x/z², y/z³), without copying them
+	jacobian() (*big.Int, *big.Int, *big.Int)
+}
+
+// DefaultCurve is the name of the group used when none is specified, and by
+// the schemes which are only implemented for BN256
+const DefaultCurve = "bn256"
+
+// Curves are the supported groups
+var Curves = []Group{BN256, Secp256k1}
+
+// CurveNames returns the names of the supported groups, for usage messages
+func CurveNames() string {
+	var names []string
+	for _, g := range Curves {
+		names = append(names, g.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// CurveByName returns the group with the given name, the default when the
+// name is empty
+func CurveByName(name string) (Group, error) {
+	if name == "" {
+		name = DefaultCurve
+	}
+	for _, g := range Curves {
+		if g.Name() == name {
+			return g, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown curve: %v, expected one of %v", name, CurveNames())
+}
+
+// onBN256 returns true if every key of the rings is on BN256, which the
+// schemes other than ctlist are only implemented for
+func onBN256(rings ...Ring) bool {
+	for _, r := range rings {
+		if r.group() != BN256 {
+			return false
+		}
+		for _, pub := range r.PubKeys {
+			if pub.Group() != BN256 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// errNotBN256 is returned when signing with a scheme which is only
+// implemented for BN256 with keys of another group
+func errNotBN256(scheme string) error {
+	return fmt.Errorf("The %v signature scheme is only supported on %v", scheme, DefaultCurve)
+}
+
+// randomScalar returns a uniformly random scalar between 1 and the order
+// of the group
+func randomScalar(g Group, random io.Reader) (*big.Int, error) {
+	return randomPositiveBelow(random, g.Order())
+}
+
+// isValidScalar checks the secret is a valid private key in the group,
+// where 0 < S < N
+func isValidScalar(g Group, secret *big.Int) bool {
+	return secret != nil && secret.Sign() > 0 && secret.Cmp(g.Order()) < 0
+}
+
+// isValidPublicKey checks the point can be used as a public key, it must be
+// in the group and not the point at infinity
+func isValidPublicKey(p *CurvePoint) bool {
+	return p != nil && false == p.IsInfinity() && p.IsInSubgroup()
+}
+
+// pointFromXY returns the point of the group with the given affine
+// coordinates, or nil if it isn't on the curve
+func pointFromXY(g Group, x *big.Int, y *big.Int) *CurvePoint {
+	const numBytes = 256 / 8
+
+	xBytes := new(big.Int).Mod(x, g.Prime()).Bytes()
+	yBytes := new(big.Int).Mod(y, g.Prime()).Bytes()
+
+	m := make([]byte, numBytes*2)
+	copy(m[1*numBytes-len(xBytes):], xBytes)
+	copy(m[2*numBytes-len(yBytes):], yBytes)
+
+	p, ok := g.Unmarshal(m)
+	if !ok {
+		return nil
+	}
+	return &p
+}
+
+// hashToCurve implements the 'try-and-increment' method of hashing onto
+// a curve y² = x³ + b with p ≡ 3 mod 4, where the square root is a single
+// exponentiation:
+//
+//   x ← h mod N, x+1, ... until x³ + b is a square
+//   y ← (x³ + b)^((p+1)/4)
+//
+func hashToCurve(g Group, b *big.Int, h [sha256.Size]byte) CurvePoint {
+	P := g.Prime()
+
+	// (p+1) / 4
+	A := new(big.Int).Add(P, bigOne)
+	A.Rsh(A, 2)
+
+	x := new(big.Int).SetBytes(h[:])
+	x.Mod(x, g.Order())
+
+	// TODO: limit number of iterations?
+	// y² = x³ + B
+	for {
+		xx := new(big.Int).Mul(x, x) // x²
+		xx.Mod(xx, P)
+
+		xxx := xx.Mul(xx, x) // x³
+		xxx.Mod(xxx, P)
+
+		beta := new(big.Int).Add(xxx, b) // x³ + B
+		beta.Mod(beta, P)
+
+		y := new(big.Int).Exp(beta, A, P) // y = √(x³+B)
+
+		// Then verify (√(x³+B)%P)² == (x³+B)%P
+		z := new(big.Int).Mul(y, y)
+		z.Mod(z, P)
+		if z.Cmp(beta) == 0 {
+			if p := pointFromXY(g, x, y); p != nil {
+				return *p
+			}
+		}
+
+		x.Add(x, bigOne)
+	}
+}
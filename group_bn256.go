@@ -0,0 +1,122 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"sync"
+
+	"github.com/clearmatics/bn256"
+)
+
+// BN256 is the G1 group of the Barreto-Naehrig curve y² = x³ + 3 supported
+// by the Ethereum precompiled contracts, it is the default group
+var BN256 Group = &bn256Group{}
+
+type bn256Group struct {
+	tableOnce sync.Once
+	table     *fixedBaseTable
+}
+
+// bn256Point is a point of BN256, in Jacobian coordinates
+type bn256Point struct {
+	g *bn256.G1
+}
+
+// newBN256Point wraps a bn256 G1 point as a CurvePoint
+func newBN256Point(g *bn256.G1) CurvePoint {
+	return CurvePoint{&bn256Point{g}}
+}
+
+func (G *bn256Group) Name() string {
+	return "bn256"
+}
+
+func (G *bn256Group) Order() *big.Int {
+	return bn256.Order
+}
+
+func (G *bn256Group) Prime() *big.Int {
+	return bn256.P
+}
+
+func (G *bn256Group) ScalarBaseMult(k *big.Int) CurvePoint {
+	G.tableOnce.Do(func() {
+		G.table = newFixedBaseTable(newBN256Point(new(bn256.G1).ScalarBaseMult(bigOne)))
+	})
+	return G.table.mul(k)
+}
+
+func (G *bn256Group) Infinity() CurvePoint {
+	return newBN256Point(new(bn256.G1).ScalarBaseMult(bigZero))
+}
+
+func (G *bn256Group) HashToPoint(h [sha256.Size]byte) CurvePoint {
+	return hashToCurve(G, curveB, h)
+}
+
+func (G *bn256Group) Unmarshal(m []byte) (CurvePoint, bool) {
+	g, ok := new(bn256.G1).Unmarshal(m)
+	if !ok {
+		return CurvePoint{}, false
+	}
+	return newBN256Point(g), true
+}
+
+func (p *bn256Point) Group() Group {
+	return BN256
+}
+
+func (p *bn256Point) Add(q Point) Point {
+	return &bn256Point{new(bn256.G1).Add(p.g, q.(*bn256Point).g)}
+}
+
+func (p *bn256Point) Neg() Point {
+	return &bn256Point{new(bn256.G1).Neg(p.g)}
+}
+
+func (p *bn256Point) ScalarMult(k *big.Int) Point {
+	return &bn256Point{new(bn256.G1).ScalarMult(p.g, k)}
+}
+
+// Marshal returns x and y as two 32 byte big endian integers, zeros for
+// the point at infinity which bn256 can't convert to affine coordinates
+func (p *bn256Point) Marshal() []byte {
+	if p.IsInfinity() {
+		return make([]byte, 64)
+	}
+	return p.g.Marshal()
+}
+
+func (p *bn256Point) IsInfinity() bool {
+	_, _, z, _ := p.g.CurvePoints()
+	return z.Sign() == 0
+}
+
+// IsOnCurve returns true if y² = x³ + 3, the point at infinity is in the group
+func (p *bn256Point) IsOnCurve() bool {
+	if p.IsInfinity() {
+		return true
+	}
+	return p.g.IsOnCurve()
+}
+
+func (p *bn256Point) String() string {
+	if p.IsInfinity() {
+		return "bn256.G1(∞)"
+	}
+	return p.g.String()
+}
+
+func (p *bn256Point) jacobian() (*big.Int, *big.Int, *big.Int) {
+	x, y, z, _ := p.g.CurvePoints()
+	return x, y, z
+}
+
+// g1 returns the bn256 point of c, which must be in BN256
+func (c CurvePoint) g1() *bn256.G1 {
+	return c.p.(*bn256Point).g
+}
@@ -0,0 +1,285 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// Secp256k1 is the group of the curve y² = x³ + 7 used for Bitcoin and
+// Ethereum accounts, see SEC 2: Recommended Elliptic Curve Domain Parameters
+var Secp256k1 Group = &secp256k1Group{
+	p:  fromHex("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f"),
+	n:  fromHex("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141"),
+	b:  big.NewInt(7),
+	gx: fromHex("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"),
+	gy: fromHex("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"),
+}
+
+type secp256k1Group struct {
+	p, n, b, gx, gy *big.Int
+
+	tableOnce sync.Once
+	table     *fixedBaseTable
+}
+
+// secp256k1Point is a point of Secp256k1 in Jacobian coordinates, where
+// (x, y, z) is the affine point (x/z², y/z³) and z = 0 at infinity. Points
+// are never modified once created.
+type secp256k1Point struct {
+	x, y, z *big.Int
+}
+
+func fromHex(s string) *big.Int {
+	x, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("Invalid hex constant: " + s)
+	}
+	return x
+}
+
+func (G *secp256k1Group) Name() string {
+	return "secp256k1"
+}
+
+func (G *secp256k1Group) Order() *big.Int {
+	return G.n
+}
+
+func (G *secp256k1Group) Prime() *big.Int {
+	return G.p
+}
+
+func (G *secp256k1Group) ScalarBaseMult(k *big.Int) CurvePoint {
+	G.tableOnce.Do(func() {
+		g := &secp256k1Point{G.gx, G.gy, big.NewInt(1)}
+		G.table = newFixedBaseTable(CurvePoint{g})
+	})
+	return G.table.mul(k)
+}
+
+func (G *secp256k1Group) Infinity() CurvePoint {
+	return CurvePoint{&secp256k1Point{big.NewInt(0), big.NewInt(1), big.NewInt(0)}}
+}
+
+func (G *secp256k1Group) HashToPoint(h [sha256.Size]byte) CurvePoint {
+	return hashToCurve(G, G.b, h)
+}
+
+// Unmarshal parses x and y as two 32 byte big endian integers, zeros being
+// the point at infinity. The curve has a cofactor of one, so every point on
+// it is in the group.
+func (G *secp256k1Group) Unmarshal(m []byte) (CurvePoint, bool) {
+	const numBytes = 256 / 8
+	if len(m) != 2*numBytes {
+		return CurvePoint{}, false
+	}
+
+	x := new(big.Int).SetBytes(m[0*numBytes : 1*numBytes])
+	y := new(big.Int).SetBytes(m[1*numBytes : 2*numBytes])
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return G.Infinity(), true
+	}
+	if x.Cmp(G.p) >= 0 || y.Cmp(G.p) >= 0 {
+		return CurvePoint{}, false
+	}
+
+	p := &secp256k1Point{x, y, big.NewInt(1)}
+	if !p.IsOnCurve() {
+		return CurvePoint{}, false
+	}
+	return CurvePoint{p}, true
+}
+
+func (p *secp256k1Point) Group() Group {
+	return Secp256k1
+}
+
+// mod reduces x modulo the prime of the field
+func (p *secp256k1Point) mod(x *big.Int) *big.Int {
+	return x.Mod(x, Secp256k1.Prime())
+}
+
+// Add returns p + q using the addition formulas for Jacobian coordinates
+// with a = 0, from the Explicit-Formulas Database (add-2007-bl):
+//
+//   U1 ← X1·Z2², U2 ← X2·Z1², S1 ← Y1·Z2³, S2 ← Y2·Z1³
+//   H ← U2 - U1, I ← (2H)², J ← H·I, r ← 2(S2 - S1), V ← U1·I
+//   X3 ← r² - J - 2V
+//   Y3 ← r(V - X3) - 2·S1·J
+//   Z3 ← ((Z1 + Z2)² - Z1² - Z2²)·H
+//
+func (p *secp256k1Point) Add(qp Point) Point {
+	q := qp.(*secp256k1Point)
+	if p.IsInfinity() {
+		return q
+	}
+	if q.IsInfinity() {
+		return p
+	}
+
+	z1z1 := p.mod(new(big.Int).Mul(p.z, p.z))
+	z2z2 := p.mod(new(big.Int).Mul(q.z, q.z))
+	u1 := p.mod(new(big.Int).Mul(p.x, z2z2))
+	u2 := p.mod(new(big.Int).Mul(q.x, z1z1))
+	s1 := p.mod(new(big.Int).Mul(p.y, p.mod(new(big.Int).Mul(q.z, z2z2))))
+	s2 := p.mod(new(big.Int).Mul(q.y, p.mod(new(big.Int).Mul(p.z, z1z1))))
+
+	h := p.mod(new(big.Int).Sub(u2, u1))
+	r := p.mod(new(big.Int).Sub(s2, s1))
+	if h.Sign() == 0 {
+		if r.Sign() == 0 {
+			return p.double()
+		}
+		return Secp256k1.Infinity().p
+	}
+	r.Lsh(r, 1)
+
+	i := new(big.Int).Lsh(h, 1)
+	i = p.mod(i.Mul(i, i))
+	j := p.mod(new(big.Int).Mul(h, i))
+	v := p.mod(new(big.Int).Mul(u1, i))
+
+	x3 := new(big.Int).Mul(r, r)
+	x3.Sub(x3, j)
+	x3.Sub(x3, new(big.Int).Lsh(v, 1))
+	p.mod(x3)
+
+	y3 := new(big.Int).Sub(v, x3)
+	y3.Mul(y3, r)
+	y3.Sub(y3, new(big.Int).Lsh(p.mod(new(big.Int).Mul(s1, j)), 1))
+	p.mod(y3)
+
+	z3 := new(big.Int).Add(p.z, q.z)
+	z3.Mul(z3, z3)
+	z3.Sub(z3, z1z1)
+	z3.Sub(z3, z2z2)
+	p.mod(z3.Mul(p.mod(z3), h))
+
+	return &secp256k1Point{x3, y3, z3}
+}
+
+// Neg returns -p = (x, -y, z)
+func (p *secp256k1Point) Neg() Point {
+	if p.IsInfinity() {
+		return p
+	}
+	return &secp256k1Point{p.x, p.mod(new(big.Int).Neg(p.y)), p.z}
+}
+
+// double returns 2p using the doubling formulas for Jacobian coordinates
+// with a = 0 (dbl-2009-l):
+//
+//   A ← X1², B ← Y1², C ← B², D ← 2((X1 + B)² - A - C), E ← 3A
+//   X3 ← E² - 2D
+//   Y3 ← E(D - X3) - 8C
+//   Z3 ← 2·Y1·Z1
+//
+func (p *secp256k1Point) double() *secp256k1Point {
+	if p.IsInfinity() || p.y.Sign() == 0 {
+		return Secp256k1.Infinity().p.(*secp256k1Point)
+	}
+
+	a := p.mod(new(big.Int).Mul(p.x, p.x))
+	b := p.mod(new(big.Int).Mul(p.y, p.y))
+	c := p.mod(new(big.Int).Mul(b, b))
+
+	d := new(big.Int).Add(p.x, b)
+	d.Mul(d, d)
+	d.Sub(d, a)
+	d.Sub(d, c)
+	p.mod(d.Lsh(d, 1))
+
+	e := new(big.Int).Mul(a, big.NewInt(3))
+
+	x3 := new(big.Int).Mul(e, e)
+	x3.Sub(x3, new(big.Int).Lsh(d, 1))
+	p.mod(x3)
+
+	y3 := new(big.Int).Sub(d, x3)
+	y3.Mul(y3, e)
+	y3.Sub(y3, new(big.Int).Lsh(c, 3))
+	p.mod(y3)
+
+	z3 := new(big.Int).Mul(p.y, p.z)
+	p.mod(z3.Lsh(z3, 1))
+
+	return &secp256k1Point{x3, y3, z3}
+}
+
+// ScalarMult returns p multiplied by k modulo the order, by double and add
+// from the most significant bit
+func (p *secp256k1Point) ScalarMult(k *big.Int) Point {
+	k = new(big.Int).Mod(k, Secp256k1.Order())
+
+	acc := Secp256k1.Infinity().p.(*secp256k1Point)
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		acc = acc.double()
+		if k.Bit(i) == 1 {
+			acc = acc.Add(p).(*secp256k1Point)
+		}
+	}
+	return acc
+}
+
+// affine returns the affine coordinates of p, which must not be infinity
+func (p *secp256k1Point) affine() (*big.Int, *big.Int) {
+	if p.z.Cmp(bigOne) == 0 {
+		return p.x, p.y
+	}
+
+	P := Secp256k1.Prime()
+	zInv := new(big.Int).ModInverse(p.z, P)
+	zInv2 := p.mod(new(big.Int).Mul(zInv, zInv))
+	x := p.mod(new(big.Int).Mul(p.x, zInv2))
+	y := p.mod(new(big.Int).Mul(p.y, p.mod(zInv2.Mul(zInv2, zInv))))
+	return x, y
+}
+
+// Marshal returns x and y as two 32 byte big endian integers, zeros for
+// the point at infinity
+func (p *secp256k1Point) Marshal() []byte {
+	m := make([]byte, 64)
+	if p.IsInfinity() {
+		return m
+	}
+	x, y := p.affine()
+	copy(m[:32], paddedBigBytes(x, 32))
+	copy(m[32:], paddedBigBytes(y, 32))
+	return m
+}
+
+func (p *secp256k1Point) IsInfinity() bool {
+	return p.z.Sign() == 0
+}
+
+// IsOnCurve returns true if y² = x³ + 7, the point at infinity is in the group
+func (p *secp256k1Point) IsOnCurve() bool {
+	if p.IsInfinity() {
+		return true
+	}
+
+	x, y := p.affine()
+	yy := p.mod(new(big.Int).Mul(y, y))
+	xxx := p.mod(new(big.Int).Mul(x, x))
+	xxx.Mul(xxx, x)
+	xxx.Add(xxx, big.NewInt(7))
+	return yy.Cmp(p.mod(xxx)) == 0
+}
+
+func (p *secp256k1Point) String() string {
+	if p.IsInfinity() {
+		return "secp256k1(∞)"
+	}
+	x, y := p.affine()
+	return fmt.Sprintf("secp256k1(%v, %v)", x, y)
+}
+
+func (p *secp256k1Point) jacobian() (*big.Int, *big.Int, *big.Int) {
+	return p.x, p.y, p.z
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestCurveByName(t *testing.T) {
+	for _, g := range Curves {
+		found, err := CurveByName(g.Name())
+		if err != nil || found != g {
+			t.Fatalf("Curve %v not found", g.Name())
+		}
+	}
+
+	if g, err := CurveByName(""); err != nil || g != BN256 {
+		t.Fatal("The default curve should be bn256")
+	}
+	if _, err := CurveByName("p256"); err == nil {
+		t.Fatal("Should not find an unsupported curve")
+	}
+}
+
+// Test vectors from SEC 2 and https://crypto.stackexchange.com/a/21206
+func TestSecp256k1Generator(t *testing.T) {
+	g := Secp256k1.ScalarBaseMult(bigOne)
+	if !g.IsOnCurve() {
+		t.Fatal("Generator not on curve")
+	}
+
+	twoX, _ := new(big.Int).SetString("c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5", 16)
+	twoY, _ := new(big.Int).SetString("1ae168fea63dc339a3c58419466ceaeef7f632653266d0e1236431a950cfe52a", 16)
+	for _, two := range []CurvePoint{g.Add(g), g.ScalarMult(bigTwo), Secp256k1.ScalarBaseMult(bigTwo)} {
+		x, y := two.GetXY()
+		if x.Cmp(twoX) != 0 || y.Cmp(twoY) != 0 {
+			t.Fatal("2G, got", x, y, "expected", twoX, twoY)
+		}
+	}
+
+	N := Secp256k1.Order()
+	if !g.ScalarMult(N).IsInfinity() {
+		t.Fatal("Generator does not have order N")
+	}
+
+	// (N-1)·G = -G
+	x, y := g.ScalarMult(new(big.Int).Sub(N, bigOne)).GetXY()
+	gx, gy := g.GetXY()
+	if x.Cmp(gx) != 0 || y.Cmp(new(big.Int).Sub(Secp256k1.Prime(), gy)) != 0 {
+		t.Fatal("(N-1)G is not the negation of G")
+	}
+	if !g.Add(g.ScalarMult(new(big.Int).Sub(N, bigOne))).IsInfinity() {
+		t.Fatal("G + -G is not infinity")
+	}
+}
+
+func TestSecp256k1Arithmetic(t *testing.T) {
+	a, _ := randomScalar(Secp256k1, rand.Reader)
+	b, _ := randomScalar(Secp256k1, rand.Reader)
+
+	// Sums of points in Jacobian coordinates
+	A := Secp256k1.ScalarBaseMult(a)
+	B := Secp256k1.ScalarBaseMult(b)
+	sum := Secp256k1.ScalarBaseMult(new(big.Int).Add(a, b))
+	if !A.Add(B).Equals(&sum) {
+		t.Fatal("aG + bG != (a+b)G")
+	}
+
+	product := Secp256k1.ScalarBaseMult(new(big.Int).Mul(a, b))
+	AB := A.ScalarMult(b)
+	if !AB.Equals(&product) {
+		t.Fatal("b(aG) != (ab)G")
+	}
+
+	inf := Secp256k1.Infinity()
+	if !A.Add(inf).Equals(&A) || !inf.Add(A).Equals(&A) {
+		t.Fatal("Infinity is not the identity")
+	}
+
+	// Encoding round trips, and rejects points off the curve
+	decoded, ok := Secp256k1.Unmarshal(AB.Marshal())
+	if !ok || !decoded.Equals(&AB) {
+		t.Fatal("Unmarshal does not invert Marshal")
+	}
+	bad := AB.Marshal()
+	bad[63] ^= 1
+	if _, ok := Secp256k1.Unmarshal(bad); ok {
+		t.Fatal("Unmarshal accepted a point off the curve")
+	}
+	if decoded, ok := Secp256k1.Unmarshal(inf.Marshal()); !ok || !decoded.IsInfinity() {
+		t.Fatal("Unmarshal of zeros is not infinity")
+	}
+
+	// Points of different groups are never equal
+	bn := BN256.ScalarBaseMult(a)
+	if bn.Equals(&A) {
+		t.Fatal("Points of different groups are equal")
+	}
+}
+
+func TestSecp256k1HashToPoint(t *testing.T) {
+	h := sha256.Sum256([]byte("hello world"))
+	p := Secp256k1.HashToPoint(h)
+	if !p.IsOnCurve() || p.Group() != Secp256k1 {
+		t.Fatal("Hashed point not on secp256k1")
+	}
+	q := Secp256k1.HashToPoint(h)
+	if !p.Equals(&q) {
+		t.Fatal("Hashing is not deterministic")
+	}
+}
+
+func TestCurvePointJSONCurve(t *testing.T) {
+	p := Secp256k1.ScalarBaseMult(big.NewInt(42))
+	data, err := json.Marshal(&p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if false == strings.Contains(string(data), `"curve":"secp256k1"`) {
+		t.Fatalf("Curve not recorded: %s", data)
+	}
+
+	var decoded CurvePoint
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equals(&p) {
+		t.Fatal("Point differs after a JSON round trip")
+	}
+
+	// BN256 points are encoded as before
+	bn := BN256.ScalarBaseMult(big.NewInt(42))
+	data, err = json.Marshal(&bn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "curve") {
+		t.Fatalf("Curve recorded for a bn256 point: %s", data)
+	}
+
+	if err := json.Unmarshal([]byte(`{"x": "0x1", "y": "0x2", "curve": "p256"}`), &decoded); err == nil {
+		t.Fatal("Should not parse a point of an unknown curve")
+	}
+}
+
+func TestRingSecp256k1(t *testing.T) {
+	r := Ring{Curve: Secp256k1}
+	if err := r.Generate(rand.Reader, 3); err != nil {
+		t.Fatal(err)
+	}
+	message := []byte("foobarbaz")
+
+	sig, err := r.Signature(rand.Reader, r.PrivKeys[1], message, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.Tau.Group() != Secp256k1 {
+		t.Fatal("Signature is not on secp256k1")
+	}
+	if !r.VerifySignature(message, *sig) {
+		t.Fatal("Signature not verified")
+	}
+	if r.VerifySignature([]byte("badmessage"), *sig) {
+		t.Fatal("Signature verified for a different message")
+	}
+
+	// The curve is recorded with the ring
+	data, err := json.Marshal(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Ring
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Curve != Secp256k1 || !NewSigningContext(&loaded, message).Verify(*sig) {
+		t.Fatal("Signature not verified by the loaded ring")
+	}
+
+	// A signature on another curve is rejected rather than mixed in
+	bnRing := generateRing(3)
+	bnSig, err := bnRing.Signature(rand.Reader, bnRing.PrivKeys[0], message, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bnSig.Ctlist = bnSig.Ctlist[:6]
+	if r.VerifySignature(message, *bnSig) {
+		t.Fatal("Verified a signature with tau on another curve")
+	}
+
+	if _, err := r.CompactSignature(rand.Reader, r.PrivKeys[0], message, 0); err == nil {
+		t.Fatal("LSAG should only be supported on bn256")
+	}
+}
+
+func TestStealthSessionSecp256k1(t *testing.T) {
+	Ap, As, err := newKeyPair(Secp256k1, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Bp, Bs, err := newKeyPair(Secp256k1, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aToB, err := NewStealthSessionV2(As, Bp, nil, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bToA, err := NewStealthSessionV2(Bs, Ap, nil, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range aToB.TheirAddresses {
+		theirs := aToB.TheirAddresses[i].Public
+		mine := bToA.MyAddresses[i]
+		if theirs.Group() != Secp256k1 || !theirs.Equals(&mine.Public) {
+			t.Fatalf("Stealth address %v differs", i)
+		}
+		public := Secp256k1.ScalarBaseMult(mine.Private.Int())
+		if !public.Equals(&mine.Public) {
+			t.Fatalf("Stealth secret key %v does not match its address", i)
+		}
+	}
+}
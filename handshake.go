@@ -0,0 +1,121 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// handshakeLabel domain separates handshake fingerprints
+var handshakeLabel = []byte("orbital-handshake-v1")
+
+// StealthHandshake is exchanged by two parties after swapping master
+// public keys, both sides derive the same fingerprint and short
+// authentication string only if neither key was substituted in transit.
+//
+type StealthHandshake struct {
+	MyPublic    CurvePoint        `json:"myPublic"`
+	TheirPublic CurvePoint        `json:"theirPublic"`
+	Fingerprint string            `json:"fingerprint"`
+	SAS         string            `json:"sas"`
+	Signature   *SchnorrSignature `json:"signature,omitempty"`
+}
+
+// handshakeFingerprint hashes both public keys in a canonical order, so
+// that both parties compute the same value:
+//
+//   F ← H(label ‖ min(A, B) ‖ max(A, B))
+//
+func handshakeFingerprint(a *CurvePoint, b *CurvePoint) [sha256.Size]byte {
+	am := a.Marshal()
+	bm := b.Marshal()
+	if bytes.Compare(am, bm) > 0 {
+		am, bm = bm, am
+	}
+
+	data := append(append([]byte{}, handshakeLabel...), am...)
+	return sha256.Sum256(append(data, bm...))
+}
+
+// shortAuthString formats the first 128 bits of a fingerprint as groups
+// of hex digits, these are read aloud or compared visually.
+//
+// An attacker substituting both keys needs ~2^64 work to find a collision.
+//
+func shortAuthString(fingerprint [sha256.Size]byte) string {
+	encoded := hex.EncodeToString(fingerprint[:16])
+
+	var groups []string
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+	return strings.Join(groups, " ")
+}
+
+// NewStealthHandshake derives the fingerprint and short authentication
+// string between our secret key and their public key, optionally signing
+// the fingerprint to prove possession of our key.
+//
+func NewStealthHandshake(random io.Reader, mySecret *big.Int, theirPublic *CurvePoint, sign bool) (*StealthHandshake, error) {
+	if false == isValidSecretKey(mySecret) {
+		return nil, fmt.Errorf("Invalid secret key: %v", mySecret)
+	}
+
+	if false == isValidPublicKey(theirPublic) {
+		return nil, errors.New("Invalid public key provided")
+	}
+
+	myPublic := derivePublicKey(mySecret)
+	fingerprint := handshakeFingerprint(&myPublic, theirPublic)
+
+	handshake := StealthHandshake{
+		MyPublic:    myPublic,
+		TheirPublic: *theirPublic,
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+		SAS:         shortAuthString(fingerprint),
+	}
+
+	if sign {
+		sig, err := SchnorrSign(random, mySecret, fingerprint[:])
+		if err != nil {
+			return nil, err
+		}
+		handshake.Signature = sig
+	}
+
+	return &handshake, nil
+}
+
+// Verify checks the handshake the other party sent us against our own,
+// both must refer to the same pair of keys and any signature must be
+// valid for the sender's public key.
+//
+func (h *StealthHandshake) Verify(mine *StealthHandshake) error {
+	if !h.TheirPublic.Equals(&mine.MyPublic) {
+		return errors.New("Handshake is not addressed to our public key")
+	}
+
+	if !h.MyPublic.Equals(&mine.TheirPublic) {
+		return errors.New("Handshake is not from the expected public key")
+	}
+
+	fingerprint := handshakeFingerprint(&h.MyPublic, &h.TheirPublic)
+	if h.Fingerprint != hex.EncodeToString(fingerprint[:]) || h.Fingerprint != mine.Fingerprint {
+		return errors.New("Fingerprint mismatch")
+	}
+
+	if h.Signature != nil && !SchnorrVerify(&h.MyPublic, fingerprint[:], h.Signature) {
+		return errors.New("Invalid handshake signature")
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func TestStealthHandshake(t *testing.T) {
+	Ap, As, Bp, Bs := generatePairOfTestKeys(t)
+
+	hA, err := NewStealthHandshake(rand.Reader, As, Bp, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hB, err := NewStealthHandshake(rand.Reader, Bs, Ap, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hA.SAS != hB.SAS || hA.Fingerprint != hB.Fingerprint {
+		t.Fatal("Both sides derived different fingerprints", hA.SAS, hB.SAS)
+	}
+
+	if err := hB.Verify(hA); err != nil {
+		t.Fatal("A failed to verify B's handshake:", err)
+	}
+	if err := hA.Verify(hB); err != nil {
+		t.Fatal("B failed to verify A's handshake:", err)
+	}
+}
+
+func TestStealthHandshakeSubstitutedKey(t *testing.T) {
+	Ap, As, Bp, Bs := generatePairOfTestKeys(t)
+	Mp, Ms, err := generateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mallory substitutes her key for B's when talking to A, and vice versa
+	hA, _ := NewStealthHandshake(rand.Reader, As, Mp, false)
+	hB, _ := NewStealthHandshake(rand.Reader, Bs, Mp, false)
+	if hA.SAS == hB.SAS {
+		t.Fatal("Substituted keys produced the same SAS")
+	}
+
+	// Mallory's handshake towards A can't match the one A computed with B
+	hMA, _ := NewStealthHandshake(rand.Reader, Ms, Ap, true)
+	hAB, _ := NewStealthHandshake(rand.Reader, As, Bp, false)
+	if err := hMA.Verify(hAB); err == nil {
+		t.Fatal("Handshake from substituted key verified")
+	}
+}
+
+func TestStealthHandshakeBadSignature(t *testing.T) {
+	Ap, As, Bp, Bs := generatePairOfTestKeys(t)
+
+	hA, _ := NewStealthHandshake(rand.Reader, As, Bp, false)
+	hB, _ := NewStealthHandshake(rand.Reader, Bs, Ap, true)
+
+	// Signature by someone other than B
+	_, Ms, _ := generateKeyPair(rand.Reader)
+	hM, _ := NewStealthHandshake(rand.Reader, Ms, Ap, true)
+	hB.Signature = hM.Signature
+	if err := hB.Verify(hA); err == nil {
+		t.Fatal("Handshake with invalid signature verified")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"math/big"
@@ -20,6 +21,17 @@ func ParseBigInt(s string) (*big.Int, error) {
 	return i, err
 }
 
+// paddedBigBytes encodes a non-negative integer which fits in n bytes as
+// exactly n big-endian bytes, nil is encoded as zero
+func paddedBigBytes(i *big.Int, n int) []byte {
+	out := make([]byte, n)
+	if i != nil {
+		b := i.Bytes()
+		copy(out[n-len(b):], b)
+	}
+	return out
+}
+
 // hexBig is like big.Int, except when serialized to JSON it is encoded as hexadecimal
 type hexBig big.Int
 
@@ -42,10 +54,20 @@ func UnmarshalBigInt(raw json.RawMessage) (*big.Int, error) {
 
 func (i *hexBig) UnmarshalJSON(data []byte) error {
 	result, err := UnmarshalBigInt(data)
+	if err != nil {
+		return err
+	}
 	*i = hexBig(*result)
-	return err
+	return nil
 }
 
 func (i *hexBig) MarshalJSON() ([]byte, error) {
 	return json.Marshal(fmt.Sprintf("0x%x", (*big.Int)(i)))
 }
+
+// indexBytes encodes an index as a 32bit big-endian integer
+func indexBytes(i int) []byte {
+	var out [4]byte
+	binary.BigEndian.PutUint32(out[:], uint32(i))
+	return out[:]
+}
@@ -1,9 +1,196 @@
 package main
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type inputData struct {
-	AliceToBob *StealthSession `json:"alice2bob"`
-	BobToAlice *StealthSession `json:"bob2alice"`
-	Message    []byte          `json:"message"`
-	PubKeys    []CurvePoint    `json:"ring"`
-	Signatures []RingSignature `json:"signatures"`
+	Curve             Group                  `json:"-"`
+	AliceToBob        *StealthSession        `json:"alice2bob"`
+	BobToAlice        *StealthSession        `json:"bob2alice"`
+	Message           []byte                 `json:"message"`
+	PubKeys           []CurvePoint           `json:"ring"`
+	Proofs            []*SchnorrSignature    `json:"proofs,omitempty"`
+	Layers            [][]CurvePoint         `json:"layers,omitempty"`
+	LayerProofs       [][]*SchnorrSignature  `json:"layerProofs,omitempty"`
+	Signatures        []RingSignature        `json:"-"`
+	CompactSignatures []CompactRingSignature `json:"-"`
+	LogSignatures     []LogRingSignature     `json:"-"`
+	MLSAGSignatures   []MLSAGSignature       `json:"-"`
+	CLSAGSignatures   []CLSAGSignature       `json:"-"`
+}
+
+// inputDataJSON is the JSON representation of inputData, signatures of
+// every scheme are stored together and told apart by their scheme field
+type inputDataJSON struct {
+	Curve       string                `json:"curve"`
+	AliceToBob  *StealthSession       `json:"alice2bob"`
+	BobToAlice  *StealthSession       `json:"bob2alice"`
+	Message     []byte                `json:"message"`
+	PubKeys     []CurvePoint          `json:"ring"`
+	Proofs      []*SchnorrSignature   `json:"proofs,omitempty"`
+	Layers      [][]CurvePoint        `json:"layers,omitempty"`
+	LayerProofs [][]*SchnorrSignature `json:"layerProofs,omitempty"`
+	Signatures  []json.RawMessage     `json:"signatures"`
+}
+
+// group returns the group of the keys, when Curve isn't set it is the
+// group of the first public key
+func (d *inputData) group() Group {
+	if d.Curve != nil {
+		return d.Curve
+	}
+	if len(d.PubKeys) > 0 {
+		return d.PubKeys[0].Group()
+	}
+	if len(d.Layers) > 0 && len(d.Layers[0]) > 0 {
+		return d.Layers[0][0].Group()
+	}
+	return BN256
+}
+
+// MarshalJSON converts inputData to a JSON representation
+func (d *inputData) MarshalJSON() ([]byte, error) {
+	aux := inputDataJSON{
+		Curve:       d.group().Name(),
+		AliceToBob:  d.AliceToBob,
+		BobToAlice:  d.BobToAlice,
+		Message:     d.Message,
+		PubKeys:     d.PubKeys,
+		Proofs:      d.Proofs,
+		Layers:      d.Layers,
+		LayerProofs: d.LayerProofs,
+		Signatures:  []json.RawMessage{},
+	}
+
+	for i := range d.Signatures {
+		raw, err := json.Marshal(&d.Signatures[i])
+		if err != nil {
+			return nil, err
+		}
+		aux.Signatures = append(aux.Signatures, raw)
+	}
+
+	for i := range d.CompactSignatures {
+		raw, err := json.Marshal(&d.CompactSignatures[i])
+		if err != nil {
+			return nil, err
+		}
+		aux.Signatures = append(aux.Signatures, raw)
+	}
+
+	for i := range d.LogSignatures {
+		raw, err := json.Marshal(&d.LogSignatures[i])
+		if err != nil {
+			return nil, err
+		}
+		aux.Signatures = append(aux.Signatures, raw)
+	}
+
+	for i := range d.MLSAGSignatures {
+		raw, err := json.Marshal(&d.MLSAGSignatures[i])
+		if err != nil {
+			return nil, err
+		}
+		aux.Signatures = append(aux.Signatures, raw)
+	}
+
+	for i := range d.CLSAGSignatures {
+		raw, err := json.Marshal(&d.CLSAGSignatures[i])
+		if err != nil {
+			return nil, err
+		}
+		aux.Signatures = append(aux.Signatures, raw)
+	}
+
+	return json.Marshal(&aux)
+}
+
+// UnmarshalJSON converts a JSON representation to inputData
+func (d *inputData) UnmarshalJSON(data []byte) error {
+	var aux inputDataJSON
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	g, err := CurveByName(aux.Curve)
+	if err != nil {
+		return err
+	}
+	points := aux.PubKeys
+	for _, layer := range aux.Layers {
+		points = append(points[:len(points):len(points)], layer...)
+	}
+	for _, p := range points {
+		if p.Group() != g {
+			return fmt.Errorf("Public key is not on the curve of the ring: %v", g.Name())
+		}
+	}
+
+	d.Curve = g
+	d.AliceToBob = aux.AliceToBob
+	d.BobToAlice = aux.BobToAlice
+	d.Message = aux.Message
+	d.PubKeys = aux.PubKeys
+	d.Proofs = aux.Proofs
+	d.Layers = aux.Layers
+	d.LayerProofs = aux.LayerProofs
+	d.Signatures = nil
+	d.CompactSignatures = nil
+	d.LogSignatures = nil
+	d.MLSAGSignatures = nil
+	d.CLSAGSignatures = nil
+
+	for _, raw := range aux.Signatures {
+		var scheme struct {
+			Scheme string `json:"scheme"`
+		}
+		if err := json.Unmarshal(raw, &scheme); err != nil {
+			return err
+		}
+
+		switch scheme.Scheme {
+		case "", SchemeCtlist:
+			var sig RingSignature
+			if err := json.Unmarshal(raw, &sig); err != nil {
+				return err
+			}
+			d.Signatures = append(d.Signatures, sig)
+
+		case SchemeLSAG:
+			var sig CompactRingSignature
+			if err := json.Unmarshal(raw, &sig); err != nil {
+				return err
+			}
+			d.CompactSignatures = append(d.CompactSignatures, sig)
+
+		case SchemeGK:
+			var sig LogRingSignature
+			if err := json.Unmarshal(raw, &sig); err != nil {
+				return err
+			}
+			d.LogSignatures = append(d.LogSignatures, sig)
+
+		case SchemeMLSAG:
+			var sig MLSAGSignature
+			if err := json.Unmarshal(raw, &sig); err != nil {
+				return err
+			}
+			d.MLSAGSignatures = append(d.MLSAGSignatures, sig)
+
+		case SchemeCLSAG:
+			var sig CLSAGSignature
+			if err := json.Unmarshal(raw, &sig); err != nil {
+				return err
+			}
+			d.CLSAGSignatures = append(d.CLSAGSignatures, sig)
+
+		default:
+			return fmt.Errorf("Unknown signature scheme: %v", scheme.Scheme)
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,56 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+// hkdfExtract implements the HKDF-Extract step from RFC5869 (Section 2.2)
+// using HMAC-SHA256:
+//
+//   PRK ← HMAC-Hash(salt, IKM)
+//
+func hkdfExtract(salt []byte, ikm []byte) []byte {
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+	return mac.Sum(nil)
+}
+
+// hkdfExpand implements the HKDF-Expand step from RFC5869 (Section 2.3)
+// using HMAC-SHA256:
+//
+//   T(0) ← empty string
+//   T(i) ← HMAC-Hash(PRK, T(i-1) | info | i)
+//   OKM  ← first L octets of T(1) | T(2) | ...
+//
+func hkdfExpand(prk []byte, info []byte, length int) ([]byte, error) {
+	if length > 255*sha256.Size {
+		return nil, errors.New("HKDF output length too large")
+	}
+
+	var okm, t []byte
+	mac := hmac.New(sha256.New, prk)
+	for i := byte(1); len(okm) < length; i++ {
+		mac.Reset()
+		mac.Write(t)
+		mac.Write(info)
+		mac.Write([]byte{i})
+		t = mac.Sum(nil)
+		okm = append(okm, t...)
+	}
+
+	return okm[:length], nil
+}
+
+// hkdf derives length bytes of key material from ikm, see RFC5869
+func hkdf(salt []byte, ikm []byte, info []byte, length int) ([]byte, error) {
+	return hkdfExpand(hkdfExtract(salt, ikm), info, length)
+}
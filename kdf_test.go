@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// TestHKDF verifies HKDF-SHA256 against RFC5869 (Appendix A.1)
+func TestHKDF(t *testing.T) {
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	expected, _ := hex.DecodeString("3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865")
+
+	okm, err := hkdf(salt, ikm, info, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(okm, expected) {
+		t.Fatalf("Expected %x but got %x", expected, okm)
+	}
+}
+
+func TestHKDFTooLong(t *testing.T) {
+	_, err := hkdf(nil, testBytes, nil, 255*32+1)
+	if err == nil {
+		t.Fatal("Accepted output length larger than 255*HashLen")
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Signature scheme identifiers, included in the JSON representation
+const (
+	// SchemeCtlist is the original scheme with a c and t per ring member
+	SchemeCtlist = "ctlist"
+
+	// SchemeLSAG is the compact LSAG scheme with a single challenge and a
+	// response per ring member
+	SchemeLSAG = "lsag"
+)
+
+// lsagLabel domain separates the LSAG challenge hashes
+var lsagLabel = []byte("orbital-lsag-v1")
+
+// A CompactRingSignature is a linkable ring signature (LSAG) represented
+// as the key image, the initial challenge and one response per member,
+// which is n+1 scalars rather than the 2n of a RingSignature.
+type CompactRingSignature struct {
+	Tau CurvePoint `json:"tau"`
+	C0  *big.Int   `json:"c0"`
+	S   []*big.Int `json:"s"`
+}
+
+// MarshalJSON converts a CompactRingSignature to a JSON representation
+func (rs *CompactRingSignature) MarshalJSON() ([]byte, error) {
+	s := make([]*hexBig, len(rs.S))
+	for i, v := range rs.S {
+		s[i] = (*hexBig)(v)
+	}
+
+	return json.Marshal(&struct {
+		Scheme string     `json:"scheme"`
+		Tau    CurvePoint `json:"tau"`
+		C0     *hexBig    `json:"c0"`
+		S      []*hexBig  `json:"s"`
+	}{
+		Scheme: SchemeLSAG,
+		Tau:    rs.Tau,
+		C0:     (*hexBig)(rs.C0),
+		S:      s,
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a CompactRingSignature struct
+func (rs *CompactRingSignature) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Scheme string     `json:"scheme"`
+		Tau    CurvePoint `json:"tau"`
+		C0     *hexBig    `json:"c0"`
+		S      []*hexBig  `json:"s"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.Scheme != SchemeLSAG {
+		return fmt.Errorf("Unexpected signature scheme: %v", aux.Scheme)
+	}
+
+	if aux.C0 == nil {
+		return errors.New("Invalid signature, no c0 specified")
+	}
+
+	s := make([]*big.Int, len(aux.S))
+	for i, v := range aux.S {
+		if v == nil {
+			return errors.New("Invalid signature, null response")
+		}
+		s[i] = (*big.Int)(v)
+	}
+	rs.Tau = aux.Tau
+	rs.C0 = (*big.Int)(aux.C0)
+	rs.S = s
+	return nil
+}
+
+// lsagChallenge computes the next challenge in the ring from the previous
+// commitments, bound to the ring, the message point and the key image:
+//
+//   c_{i+1} ← H(R, H(m), tau, L_i, R_i)
+//
+func lsagChallenge(ringHash []byte, hashp *CurvePoint, tau *CurvePoint, L CurvePoint, R CurvePoint) *big.Int {
+	return hashToScalar(lsagLabel, ringHash, hashp.Marshal(), tau.Marshal(), L.Marshal(), R.Marshal())
+}
+
+// CompactSignature generates an LSAG signature, from Liu, Wei & Wong
+// (IACR 2004/027) with the signer at index π:
+//
+//   tau ← H(m)^x
+//   c_{π+1} ← H(..., g^u, H(m)^u)
+//   c_{i+1} ← H(..., g^s_i · y_i^c_i, H(m)^s_i · tau^c_i)   for i ≠ π
+//   s_π ← u - c_π·x
+//
+func (r *Ring) CompactSignature(random io.Reader, pk Scalar, message []byte, signer int) (*CompactRingSignature, error) {
+	N := CurvePoint{}.Order()
+	n := len(r.PubKeys)
+
+	if !onBN256(*r) {
+		return nil, errNotBN256(SchemeLSAG)
+	}
+
+	if signer < 0 || signer >= n {
+		return nil, fmt.Errorf("Signer index out of range: %v", signer)
+	}
+
+	x := pk.Int()
+	hashp := messagePoint(message)
+	tau := hashp.ScalarMult(x)
+	ringHash := r.PublicKeysHashed()
+
+	s := make([]*big.Int, n)
+	c := make([]*big.Int, n)
+
+	u, err := CurvePoint{}.RandomN(random)
+	if err != nil {
+		return nil, err
+	}
+	L := CurvePoint{}.ScalarBaseMult(u)
+	R := hashp.ScalarMult(u)
+	c[(signer+1)%n] = lsagChallenge(ringHash[:], hashp, &tau, L, R)
+
+	for k := 1; k < n; k++ {
+		i := (signer + k) % n
+		s[i], err = CurvePoint{}.RandomN(random)
+		if err != nil {
+			return nil, err
+		}
+
+		L = r.PubKeys[i].ParameterPointAdd(s[i], c[i])
+		R = hashp.HashPointAdd(tau, s[i], c[i])
+		c[(i+1)%n] = lsagChallenge(ringHash[:], hashp, &tau, L, R)
+	}
+
+	cx := new(big.Int).Mul(c[signer], x)
+	s[signer] = new(big.Int).Sub(u, cx)
+	s[signer].Mod(s[signer], N)
+
+	return &CompactRingSignature{tau, c[0], s}, nil
+}
+
+// CompactSignatures generates an LSAG signature for every private key in the ring
+func (r *Ring) CompactSignatures(random io.Reader, message []byte) ([]CompactRingSignature, error) {
+	var signaturesArr []CompactRingSignature
+
+	for i, privKey := range r.PrivKeys {
+		signature, err := r.CompactSignature(random, privKey, message, i)
+		if err != nil {
+			return nil, err
+		}
+		signaturesArr = append(signaturesArr, *signature)
+	}
+
+	return signaturesArr, nil
+}
+
+// VerifyCompactSignature verifies an LSAG signature given a message, by
+// recomputing every challenge from c0 and checking the ring closes
+func (r *Ring) VerifyCompactSignature(message []byte, sigma CompactRingSignature) bool {
+	N := CurvePoint{}.Order()
+	n := len(r.PubKeys)
+
+	if n == 0 || len(sigma.S) != n || sigma.C0 == nil || sigma.Tau.p == nil || !onBN256(*r) {
+		return false
+	}
+	if sigma.Tau.IsInfinity() || !sigma.Tau.IsOnCurve() {
+		return false
+	}
+	for _, v := range append([]*big.Int{sigma.C0}, sigma.S...) {
+		if v == nil || v.Sign() < 0 || v.Cmp(N) >= 0 {
+			return false
+		}
+	}
+
+	hashp := messagePoint(message)
+	ringHash := r.PublicKeysHashed()
+
+	c := sigma.C0
+	for i := 0; i < n; i++ {
+		L := r.PubKeys[i].ParameterPointAdd(sigma.S[i], c)
+		R := hashp.HashPointAdd(sigma.Tau, sigma.S[i], c)
+		c = lsagChallenge(ringHash[:], hashp, &sigma.Tau, L, R)
+	}
+
+	return c.Cmp(sigma.C0) == 0
+}
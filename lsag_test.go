@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestCompactSignature(t *testing.T) {
+	for _, i := range []int{1, 2, 5} {
+		r := generateRing(i)
+		message := []byte("foobarbaz")
+
+		sigs, err := r.CompactSignatures(rand.Reader, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, sig := range sigs {
+			expected := i
+			actual := len(sig.S)
+			if actual != expected {
+				t.Errorf("Expected %v but got %v", expected, actual)
+			}
+			if !r.VerifyCompactSignature(message, sig) {
+				t.Errorf("Signature not verified for ring of %v", i)
+			}
+		}
+	}
+}
+
+func TestCompactSignatureTau(t *testing.T) {
+	r := generateRing(3)
+	message := []byte("foobarbaz")
+
+	// The key image is the same as the original scheme, so double spends
+	// are detected across both schemes
+	sig, err := r.Signature(rand.Reader, r.PrivKeys[1], message, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := r.CompactSignature(rand.Reader, r.PrivKeys[1], message, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sig.Tau.Equals(&compact.Tau) {
+		t.Fatal("Key images differ between schemes")
+	}
+}
+
+func TestVerifyCompactSignatureBad(t *testing.T) {
+	r := generateRing(3)
+	message := []byte("foobarbaz")
+
+	sig, err := r.CompactSignature(rand.Reader, r.PrivKeys[0], message, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.VerifyCompactSignature([]byte("badmessage"), *sig) {
+		t.Fatal("Signature verified for wrong message")
+	}
+
+	other := generateRing(3)
+	if other.VerifyCompactSignature(message, *sig) {
+		t.Fatal("Signature verified for wrong ring")
+	}
+
+	bad := *sig
+	bad.S = append([]*big.Int{new(big.Int).Add(sig.S[0], bigOne)}, sig.S[1:]...)
+	if r.VerifyCompactSignature(message, bad) {
+		t.Fatal("Altered signature verified")
+	}
+
+	bad.S = sig.S[1:]
+	if r.VerifyCompactSignature(message, bad) {
+		t.Fatal("Truncated signature verified")
+	}
+
+	// Signing with a key which isn't in the ring at the signer index
+	_, priv, _ := generateKeyPair(rand.Reader)
+	forged, err := r.CompactSignature(rand.Reader, NewScalar(BN256, priv), message, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.VerifyCompactSignature(message, *forged) {
+		t.Fatal("Signature by non-member verified")
+	}
+
+	if _, err := r.CompactSignature(rand.Reader, r.PrivKeys[0], message, 3); err == nil {
+		t.Fatal("Accepted out of range signer index")
+	}
+}
+
+func TestCompactSignatureJSON(t *testing.T) {
+	r := generateRing(2)
+	message := []byte("foobarbaz")
+
+	input := inputData{PubKeys: r.PubKeys, Message: message}
+	var err error
+	input.Signatures, err = r.Signatures(rand.Reader, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input.CompactSignatures, err = r.CompactSignatures(rand.Reader, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(&input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded inputData
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Signatures) != 2 || len(decoded.CompactSignatures) != 2 {
+		t.Fatalf("Expected 2 signatures of each scheme but got %v and %v", len(decoded.Signatures), len(decoded.CompactSignatures))
+	}
+
+	ring := Ring{PubKeys: decoded.PubKeys}
+	for _, sig := range decoded.Signatures {
+		if !ring.VerifySignature(message, sig) {
+			t.Error("Signature not verified after JSON round trip")
+		}
+	}
+	for _, sig := range decoded.CompactSignatures {
+		if !ring.VerifyCompactSignature(message, sig) {
+			t.Error("Compact signature not verified after JSON round trip")
+		}
+	}
+
+	var sig RingSignature
+	if err := json.Unmarshal([]byte(`{"scheme":"lsag"}`), &sig); err == nil {
+		t.Fatal("Decoded LSAG signature as a RingSignature")
+	}
+}
+
+func BenchmarkVerifyCompactSignatures(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		r := generateRing(4)
+		message := []byte("foobarbaz")
+		sigs, err := r.CompactSignatures(rand.Reader, message)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.StartTimer()
+		for _, sig := range sigs {
+			if !r.VerifyCompactSignature(message, sig) {
+				b.Error("Signature not verified")
+			}
+		}
+	}
+}
@@ -5,12 +5,18 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 func flagUsage() {
@@ -21,8 +27,39 @@ func flagUsage() {
 	The commands are:
 	generate	Generate public/private key pairs for a contract
 	inputs		Generate data inputs for a contract
+	sign		Sign a message with one key of a ring, or one key per layer
+	presign		Precompute a signature before the message is known
+	prove-tau	Prove which key of a ring produced the tau of a signature
+	verify-tau	Verify a tau proof, optionally against a signature file
+	keys split	Split a secret key into Shamir shares
+	keys recover	Recover a secret key from Shamir shares
+	keys verify-share	Verify a Shamir share against its commitments
+	tsign deal	Split a key of a ring into shares for threshold signing
+	tsign round1	Commit to nonces for a threshold signature
+	tsign round2	Respond to the commitments of all participants
+	tsign finalize	Combine the responses into a ring signature
+	bls keygen	Generate a BLS key for attestations
+	bls sign	Sign a message or a ring with a BLS key
+	bls aggregate	Combine BLS signatures into one
+	bls verify	Verify a BLS signature by a threshold of trusted keys
+	blind request	Open a session to blind sign a token
+	blind blind	Blind a token for the signer's session
+	blind sign	Sign a blinded token
+	blind unblind	Unblind the signature of a token
+	blind verify	Verify a blind signed token
+	range prove	Commit to an amount and prove it is in range
+	range verify	Verify a range proof, optionally against an opening
+	vrf prove	Evaluate a verifiable random function with a key of a ring
+	vrf verify	Verify a VRF output, and order items by it
 	verify		Verify a set of public keys against signatures
 	stealth		Generate stealth addresses
+	stealth handshake	Authenticate an exchange of public keys
+	stealth batch	Generate stealth addresses for many recipients
+	stealth open	Derive the stealth address from a received notice
+	stealth prove	Prove a stealth address was derived from your master key
+	stealth verify-proof	Verify a stealth address derivation proof
+	encrypt		Encrypt a message to a public key
+	decrypt		Decrypt a message with a secret key
 	Use "orbital [command] --help" for more information about a command.`
 	fmt.Fprintf(os.Stderr, "%s\n\n", usageText)
 }
@@ -34,6 +71,8 @@ func main() {
 	stealthCmd := flag.NewFlagSet("stealth", flag.ExitOnError)
 	inputsCmd := flag.NewFlagSet("inputs", flag.ExitOnError)
 	verifyCmd := flag.NewFlagSet("verify", flag.ExitOnError)
+	encryptCmd := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	decryptCmd := flag.NewFlagSet("decrypt", flag.ExitOnError)
 
 	if len(os.Args) == 1 {
 		flag.Usage()
@@ -42,11 +81,36 @@ func main() {
 
 	switch os.Args[1] {
 	case "stealth":
+		if len(os.Args) > 2 {
+			switch os.Args[2] {
+			case "handshake":
+				stealthHandshakeCommand(os.Args[3:])
+				return
+			case "batch":
+				stealthBatchCommand(os.Args[3:])
+				return
+			case "open":
+				stealthOpenCommand(os.Args[3:])
+				return
+			case "prove":
+				stealthProveCommand(os.Args[3:])
+				return
+			case "verify-proof":
+				stealthVerifyProofCommand(os.Args[3:])
+				return
+			}
+		}
+
 		n := stealthCmd.Int("n", 1, "Number of addresses to generate")
 		nonceOffset := stealthCmd.Int("o", 0, "Nonce offset")
 		_mySecretKey := stealthCmd.String("s", "", "Your secret key")
 		theirPublicKeyX := stealthCmd.String("x", "", "Their public key X point")
 		theirPublicKeyY := stealthCmd.String("y", "", "Their public key Y point")
+		version := stealthCmd.Int("v", StealthV1, "Key derivation version, 1 (original) or 2 (HKDF, bound to a context), both parties must use the same")
+		contract := stealthCmd.String("contract", "", "Hex encoded contract address the addresses are bound to (v2 only)")
+		_denomination := stealthCmd.String("denomination", "0", "Ring denomination the addresses are bound to (v2 only)")
+		purpose := stealthCmd.String("purpose", "", "Purpose the addresses are bound to (v2 only)")
+		curve := stealthCmd.String("curve", DefaultCurve, curveUsage)
 
 		stealthCmd.Parse(os.Args[2:])
 		if *n <= 0 || *_mySecretKey == "" || *theirPublicKeyX == "" || *theirPublicKeyY == "" {
@@ -61,13 +125,14 @@ func main() {
 		}
 
 		// TODO: optionally parse their public key as a single string, then derive Y point
-		theirPublicKey := ParseCurvePoint(*theirPublicKeyX, *theirPublicKeyY)
+		theirPublicKey := ParseGroupPoint(parseCurve(*curve), *theirPublicKeyX, *theirPublicKeyY)
 		if theirPublicKey == nil {
 			fmt.Fprintf(os.Stderr, "Unable to parse public key pair -x %v -y %v\n", *theirPublicKeyX, *theirPublicKeyY)
 			os.Exit(1)
 		}
 
-		session, err := NewStealthSession(mySecretKey, theirPublicKey, *nonceOffset, *n)
+		ctx := parseStealthContext(*version, *contract, *_denomination, *purpose)
+		session, err := NewVersionedStealthSession(*version, ctx, mySecretKey, theirPublicKey, *nonceOffset, *n)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to generate stealth session: %v\n", err)
 			os.Exit(1)
@@ -82,6 +147,9 @@ func main() {
 	// Generates a set of key pairs to be used for use with later operations
 	case "generate":
 		i := generateCmd.Int("n", 0, "Number of key pairs to be generated, e.g. 4")
+		seed := generateCmd.String("seed", "", seedUsage)
+		curve := generateCmd.String("curve", DefaultCurve, curveUsage)
+		allowMissingPop := generateCmd.Bool("allow-missing-pop", false, "Leave out the proofs of possession of the keys, for legacy rings")
 		generateCmd.Parse(os.Args[2:])
 
 		if *i == 0 {
@@ -89,8 +157,18 @@ func main() {
 			return
 		}
 
-		ring := &Ring{}
-		ring.Generate(*i)
+		ring := &Ring{Curve: parseCurve(*curve)}
+		random := randomSource(*seed)
+		if err := ring.Generate(random, *i); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to generate keys: %v\n", err)
+			os.Exit(1)
+		}
+		if false == *allowMissingPop {
+			if err := ring.ProvePossession(random); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to prove possession of keys: %v\n", err)
+				os.Exit(1)
+			}
+		}
 
 		ringJSON, err := json.MarshalIndent(ring, "", "  ")
 		if err != nil {
@@ -103,6 +181,11 @@ func main() {
 		keysFile := inputsCmd.String("f", "", "Load signing keys from a JSON file")
 		n := inputsCmd.Int("n", 0, "The size of the ring to be generated e.g. 4")
 		m := inputsCmd.String("m", "", "A Hex encoded string to be used to generate the ring")
+		scheme := inputsCmd.String("scheme", SchemeCtlist, "Signature scheme, ctlist, lsag or gk")
+		seed := inputsCmd.String("seed", "", seedUsage)
+		workers := inputsCmd.Int("workers", 0, "Number of ctlist signatures made in parallel, 0 for one per CPU")
+		curve := inputsCmd.String("curve", DefaultCurve, curveUsage+", when generating the ring")
+		allowMissingPop := inputsCmd.Bool("allow-missing-pop", false, allowMissingPopUsage+", and none are made for a generated ring")
 		inputsCmd.Parse(os.Args[2:])
 
 		if *n == 0 {
@@ -114,7 +197,8 @@ func main() {
 			return
 		}
 
-		ring := &Ring{}
+		ring := &Ring{Curve: parseCurve(*curve)}
+		random := randomSource(*seed)
 
 		var stealthSessionAliceToBob *StealthSession
 		var stealthSessionBobToAlice *StealthSession
@@ -134,8 +218,16 @@ func main() {
 			}
 		} else {
 			// Otherwise, generate a stealth session, as an example
-			alicePub, alicePriv, _ := generateKeyPair()
-			bobPub, bobPriv, _ := generateKeyPair()
+			alicePub, alicePriv, err := newKeyPair(ring.Curve, random)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to generate keys: %v\n", err)
+				os.Exit(1)
+			}
+			bobPub, bobPriv, err := newKeyPair(ring.Curve, random)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to generate keys: %v\n", err)
+				os.Exit(1)
+			}
 			stealthSessionAliceToBob, err := NewStealthSession(alicePriv, bobPub, 0, 1)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Failed to derive stealth session, Alice->Bob: %v\n", err)
@@ -148,9 +240,24 @@ func main() {
 			}
 
 			// Then generate some random key pairs and integrate our stealth session into the ring
-			ring.Generate(*n)
+			if err := ring.Generate(random, *n); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to generate keys: %v\n", err)
+				os.Exit(1)
+			}
 			ring.PrivKeys[0] = stealthSessionBobToAlice.MyAddresses[0].Private
 			ring.PubKeys[0] = stealthSessionAliceToBob.TheirAddresses[0].Public
+
+			if false == *allowMissingPop {
+				if err := ring.ProvePossession(random); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to prove possession of keys: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		}
+
+		if err := verifyRingPossession(ring, *allowMissingPop); err != nil {
+			fmt.Fprintf(os.Stderr, "Ring rejected: %v\n", err)
+			os.Exit(1)
 		}
 
 		decoded, err := hex.DecodeString(*m)
@@ -158,20 +265,38 @@ func main() {
 			panic(err)
 		}
 
-		signatures, err := ring.Signatures(decoded)
-		if err != nil {
-			panic(err)
+		if *scheme != SchemeCtlist && !onBN256(*ring) {
+			fmt.Fprintf(os.Stderr, "%v\n", errNotBN256(*scheme))
+			os.Exit(1)
 		}
 
 		inputData := inputData{
+			Curve:      ring.group(),
 			PubKeys:    ring.PubKeys,
-			Signatures: signatures,
+			Proofs:     ring.Proofs,
 			Message:    decoded,
 			AliceToBob: stealthSessionAliceToBob,
 			BobToAlice: stealthSessionBobToAlice,
 		}
 
-		ringJSON, err := json.MarshalIndent(inputData, "", "  ")
+		switch *scheme {
+		case SchemeCtlist:
+			ctx := NewSigningContext(ring, decoded)
+			ctx.Workers = *workers
+			inputData.Signatures, err = ctx.SignAll(random)
+		case SchemeLSAG:
+			inputData.CompactSignatures, err = ring.CompactSignatures(random, decoded)
+		case SchemeGK:
+			inputData.LogSignatures, err = ring.LogSignatures(random, decoded)
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown signature scheme: -scheme %v\n", *scheme)
+			os.Exit(1)
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		ringJSON, err := json.MarshalIndent(&inputData, "", "  ")
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Unable to parse JSON: %v\n", err)
 			os.Exit(1)
@@ -185,6 +310,7 @@ func main() {
 
 		f := verifyCmd.String("f", "", "Path to a JSON file containing public keys and signatures")
 		m := verifyCmd.String("m", "", "The Hex encoded message used to generate the ring")
+		allowMissingPop := verifyCmd.Bool("allow-missing-pop", false, allowMissingPopUsage)
 		verifyCmd.Parse(os.Args[2:])
 
 		if *f == "" {
@@ -209,14 +335,70 @@ func main() {
 			os.Exit(1)
 		}
 
-		json.Unmarshal(data, &inputData)
+		err = json.Unmarshal(data, &inputData)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to parse file: %v\n", err)
+			os.Exit(1)
+		}
 
 		r := Ring{
 			PubKeys: inputData.PubKeys,
+			Proofs:  inputData.Proofs,
+			Curve:   inputData.group(),
+		}
+		if err := verifyRingPossession(&r, *allowMissingPop); err != nil {
+			fmt.Fprintf(os.Stderr, "Ring rejected: %v\n", err)
+			os.Exit(1)
 		}
 
+		ctx := NewSigningContext(&r, decoded)
 		for _, sig := range inputData.Signatures {
-			valid := r.VerifySignature(decoded, sig)
+			valid := ctx.Verify(sig)
+			if valid != true {
+				fmt.Fprintln(os.Stderr, "Signatures not verified")
+				os.Exit(1)
+			}
+		}
+		for _, sig := range inputData.CompactSignatures {
+			valid := r.VerifyCompactSignature(decoded, sig)
+			if valid != true {
+				fmt.Fprintln(os.Stderr, "Signatures not verified")
+				os.Exit(1)
+			}
+		}
+		for _, sig := range inputData.LogSignatures {
+			valid := r.VerifyLogSignature(decoded, sig)
+			if valid != true {
+				fmt.Fprintln(os.Stderr, "Signatures not verified")
+				os.Exit(1)
+			}
+		}
+
+		if len(inputData.LayerProofs) != 0 && len(inputData.LayerProofs) != len(inputData.Layers) {
+			fmt.Fprintf(os.Stderr, "Ring rejected: %v layers have %v lists of proofs of possession\n", len(inputData.Layers), len(inputData.LayerProofs))
+			os.Exit(1)
+		}
+		layers := make([]Ring, len(inputData.Layers))
+		for j, pubKeys := range inputData.Layers {
+			layers[j].PubKeys = pubKeys
+			layers[j].Curve = inputData.group()
+			if j < len(inputData.LayerProofs) {
+				layers[j].Proofs = inputData.LayerProofs[j]
+			}
+			if err := verifyRingPossession(&layers[j], *allowMissingPop); err != nil {
+				fmt.Fprintf(os.Stderr, "Ring rejected: layer %v: %v\n", j, err)
+				os.Exit(1)
+			}
+		}
+		for _, sig := range inputData.MLSAGSignatures {
+			valid := MLSAGVerify(layers, [][]byte{decoded}, sig)
+			if valid != true {
+				fmt.Fprintln(os.Stderr, "Signatures not verified")
+				os.Exit(1)
+			}
+		}
+		for _, sig := range inputData.CLSAGSignatures {
+			valid := CLSAGVerify(layers, decoded, sig)
 			if valid != true {
 				fmt.Fprintln(os.Stderr, "Signatures not verified")
 				os.Exit(1)
@@ -225,7 +407,1808 @@ func main() {
 		fmt.Println("Signatures verified")
 		os.Exit(0)
 
+	case "sign":
+		signCommand(os.Args[2:])
+
+	case "presign":
+		presignCommand(os.Args[2:])
+
+	case "prove-tau":
+		proveTauCommand(os.Args[2:])
+
+	case "verify-tau":
+		verifyTauCommand(os.Args[2:])
+
+	case "keys":
+		if len(os.Args) > 2 {
+			switch os.Args[2] {
+			case "split":
+				keysSplitCommand(os.Args[3:])
+				return
+			case "recover":
+				keysRecoverCommand(os.Args[3:])
+				return
+			case "verify-share":
+				keysVerifyShareCommand(os.Args[3:])
+				return
+			}
+		}
+		flag.Usage()
+
+	case "tsign":
+		if len(os.Args) > 2 {
+			switch os.Args[2] {
+			case "deal":
+				tsignDealCommand(os.Args[3:])
+				return
+			case "round1":
+				tsignRound1Command(os.Args[3:])
+				return
+			case "round2":
+				tsignRound2Command(os.Args[3:])
+				return
+			case "finalize":
+				tsignFinalizeCommand(os.Args[3:])
+				return
+			}
+		}
+		flag.Usage()
+
+	case "bls":
+		if len(os.Args) > 2 {
+			switch os.Args[2] {
+			case "keygen":
+				blsKeygenCommand(os.Args[3:])
+				return
+			case "sign":
+				blsSignCommand(os.Args[3:])
+				return
+			case "aggregate":
+				blsAggregateCommand(os.Args[3:])
+				return
+			case "verify":
+				blsVerifyCommand(os.Args[3:])
+				return
+			}
+		}
+		flag.Usage()
+
+	case "blind":
+		if len(os.Args) > 2 {
+			switch os.Args[2] {
+			case "request":
+				blindRequestCommand(os.Args[3:])
+				return
+			case "blind":
+				blindBlindCommand(os.Args[3:])
+				return
+			case "sign":
+				blindSignCommand(os.Args[3:])
+				return
+			case "unblind":
+				blindUnblindCommand(os.Args[3:])
+				return
+			case "verify":
+				blindVerifyCommand(os.Args[3:])
+				return
+			}
+		}
+		flag.Usage()
+
+	case "range":
+		if len(os.Args) > 2 {
+			switch os.Args[2] {
+			case "prove":
+				rangeProveCommand(os.Args[3:])
+				return
+			case "verify":
+				rangeVerifyCommand(os.Args[3:])
+				return
+			}
+		}
+		flag.Usage()
+
+	case "vrf":
+		if len(os.Args) > 2 {
+			switch os.Args[2] {
+			case "prove":
+				vrfProveCommand(os.Args[3:])
+				return
+			case "verify":
+				vrfVerifyCommand(os.Args[3:])
+				return
+			}
+		}
+		flag.Usage()
+
+	case "encrypt":
+		publicKeyX := encryptCmd.String("x", "", "Recipient public key X point")
+		publicKeyY := encryptCmd.String("y", "", "Recipient public key Y point")
+		f := encryptCmd.String("f", "", "Path to the file to encrypt, defaults to stdin")
+		seed := encryptCmd.String("seed", "", seedUsage)
+		encryptCmd.Parse(os.Args[2:])
+
+		if *publicKeyX == "" || *publicKeyY == "" {
+			encryptCmd.Usage()
+			return
+		}
+
+		publicKey := ParseCurvePoint(*publicKeyX, *publicKeyY)
+		if publicKey == nil {
+			fmt.Fprintf(os.Stderr, "Unable to parse public key pair -x %v -y %v\n", *publicKeyX, *publicKeyY)
+			os.Exit(1)
+		}
+
+		var plaintext []byte
+		var err error
+		if *f != "" {
+			plaintext, err = ioutil.ReadFile(*f)
+		} else {
+			plaintext, err = ioutil.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read message: %v\n", err)
+			os.Exit(1)
+		}
+
+		msg, err := ECIESEncrypt(randomSource(*seed), publicKey, plaintext)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encrypt message: %v\n", err)
+			os.Exit(1)
+		}
+
+		msgJSON, err := json.MarshalIndent(msg, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(msgJSON))
+
+	case "decrypt":
+		_secretKey := decryptCmd.String("s", "", "Your secret key")
+		f := decryptCmd.String("f", "", "Path to a JSON file containing the encrypted message")
+		decryptCmd.Parse(os.Args[2:])
+
+		if *_secretKey == "" || *f == "" {
+			decryptCmd.Usage()
+			return
+		}
+
+		secretKey, err := ParseBigInt(*_secretKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to parse secret key: -s %v: %v\n", *_secretKey, err)
+			os.Exit(1)
+		}
+
+		var msg EncryptedMessage
+		if err := readJSONFile(*f, &msg); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		plaintext, err := ECIESDecrypt(secretKey, &msg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to decrypt message: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(plaintext)
+
 	default:
 		flag.Usage()
 	}
 }
+
+// signCommand signs a message with the key at an index of a ring, or with
+// the keys at the same index of several rings using a multi-layer scheme
+func signCommand(args []string) {
+	signCmd := flag.NewFlagSet("sign", flag.ExitOnError)
+	keysFile := signCmd.String("f", "", "Load the ring and signing key from a JSON file")
+	layersFiles := signCmd.String("layers", "", "Comma separated JSON files, one ring per layer, for a multi-layer signature")
+	index := signCmd.Int("i", 0, "Index of the signing key(s) in the ring(s)")
+	m := signCmd.String("m", "", "The Hex encoded message to sign")
+	scheme := signCmd.String("scheme", "", "Signature scheme, ctlist, lsag or gk for one ring, mlsag or clsag for layers")
+	deterministic := signCmd.Bool("deterministic", false, "Derive the signature randomness from the key, message and ring (ctlist only)")
+	hedged := signCmd.Bool("hedged", false, "Mix fresh entropy into the deterministic randomness")
+	presigned := signCmd.String("presigned", "", "Finish a presignature file from presign, it is deleted so it can't be used twice (ctlist only)")
+	seed := signCmd.String("seed", "", seedUsage)
+	signCmd.Parse(args)
+
+	if (*keysFile == "") == (*layersFiles == "") || *m == "" {
+		signCmd.Usage()
+		return
+	}
+	if (*deterministic || *hedged) && (*layersFiles != "" || (*scheme != "" && *scheme != SchemeCtlist)) {
+		fmt.Fprintln(os.Stderr, "Deterministic signing is only supported by the ctlist scheme")
+		os.Exit(1)
+	}
+	if *hedged && !*deterministic {
+		fmt.Fprintln(os.Stderr, "-hedged requires -deterministic")
+		os.Exit(1)
+	}
+	if *presigned != "" && (*deterministic || *layersFiles != "" || (*scheme != "" && *scheme != SchemeCtlist)) {
+		fmt.Fprintln(os.Stderr, "-presigned is only supported by the ctlist scheme, without -deterministic")
+		os.Exit(1)
+	}
+
+	decoded, err := hex.DecodeString(*m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse hex string: %v\n", err)
+		os.Exit(1)
+	}
+
+	var paths []string
+	if *keysFile != "" {
+		paths = []string{*keysFile}
+	} else {
+		paths = strings.Split(*layersFiles, ",")
+	}
+
+	layers := make([]Ring, len(paths))
+	keys := make([]Scalar, len(paths))
+	for j, path := range paths {
+		if err := readJSONFile(path, &layers[j]); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if *index < 0 || *index >= len(layers[j].PrivKeys) || layers[j].PrivKeys[*index].IsZero() {
+			fmt.Fprintf(os.Stderr, "No private key at index %v of '%v'\n", *index, path)
+			os.Exit(1)
+		}
+		keys[j] = layers[j].PrivKeys[*index]
+	}
+
+	random := randomSource(*seed)
+	inputData := inputData{Curve: layers[0].group(), Message: decoded}
+	if *keysFile != "" {
+		inputData.PubKeys = layers[0].PubKeys
+		inputData.Proofs = layers[0].Proofs
+	} else {
+		for _, layer := range layers {
+			inputData.Layers = append(inputData.Layers, layer.PubKeys)
+			inputData.LayerProofs = append(inputData.LayerProofs, layer.Proofs)
+		}
+	}
+
+	switch {
+	case *keysFile != "" && (*scheme == "" || *scheme == SchemeCtlist):
+		var sig *RingSignature
+		if *presigned != "" {
+			sig, err = finishPresigned(*presigned, &layers[0], keys[0], decoded, *index)
+		} else if *deterministic {
+			var extra []byte
+			if *hedged {
+				extra = make([]byte, 32)
+				if _, err := io.ReadFull(random, extra); err != nil {
+					fmt.Fprintf(os.Stderr, "Unable to read entropy: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			sig, err = layers[0].DeterministicSignature(keys[0], decoded, *index, extra)
+		} else {
+			sig, err = layers[0].Signature(random, keys[0], decoded, *index)
+		}
+		if err == nil {
+			inputData.Signatures = append(inputData.Signatures, *sig)
+		}
+	case *keysFile != "" && *scheme == SchemeLSAG:
+		var sig *CompactRingSignature
+		sig, err = layers[0].CompactSignature(random, keys[0], decoded, *index)
+		if err == nil {
+			inputData.CompactSignatures = append(inputData.CompactSignatures, *sig)
+		}
+	case *keysFile != "" && *scheme == SchemeGK:
+		var sig *LogRingSignature
+		sig, err = layers[0].LogSignature(random, keys[0], decoded, *index)
+		if err == nil {
+			inputData.LogSignatures = append(inputData.LogSignatures, *sig)
+		}
+	case *layersFiles != "" && (*scheme == "" || *scheme == SchemeMLSAG):
+		var sig *MLSAGSignature
+		sig, err = MLSAGSign(random, layers, scalarInts(keys), [][]byte{decoded}, *index)
+		if err == nil {
+			inputData.MLSAGSignatures = append(inputData.MLSAGSignatures, *sig)
+		}
+	case *layersFiles != "" && *scheme == SchemeCLSAG:
+		var sig *CLSAGSignature
+		sig, err = CLSAGSign(random, layers, scalarInts(keys), decoded, *index)
+		if err == nil {
+			inputData.CLSAGSignatures = append(inputData.CLSAGSignatures, *sig)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Signature scheme not supported: -scheme %v\n", *scheme)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to sign message: %v\n", err)
+		os.Exit(1)
+	}
+
+	signatureJSON, err := json.MarshalIndent(&inputData, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(signatureJSON))
+}
+
+// finishPresigned reads and deletes a presignature, then finishes it with
+// the key at index of the ring
+func finishPresigned(path string, ring *Ring, key Scalar, message []byte, index int) (*RingSignature, error) {
+	var pre Presignature
+	if err := readJSONFile(path, &pre); err != nil {
+		return nil, err
+	}
+
+	if pre.Signer != index {
+		return nil, fmt.Errorf("Presignature is for index %v, not %v", pre.Signer, index)
+	}
+
+	// Presignatures are single use, whether or not signing succeeds
+	if err := os.Remove(path); err != nil {
+		return nil, fmt.Errorf("Unable to delete presignature '%v': %v", path, err)
+	}
+	return ring.FinishPresigned(&pre, key, message)
+}
+
+// presignCommand precomputes the message independent part of a signature
+// with the key at an index of a ring, to be finished by sign -presigned
+func presignCommand(args []string) {
+	presignCmd := flag.NewFlagSet("presign", flag.ExitOnError)
+	keysFile := presignCmd.String("f", "", "Load the ring and signing key from a JSON file")
+	index := presignCmd.Int("i", 0, "Index of the signing key in the ring")
+	outFile := presignCmd.String("o", "", "Path to write the presignature to, it is as secret as the key")
+	presignCmd.Parse(args)
+
+	if *keysFile == "" || *outFile == "" {
+		presignCmd.Usage()
+		return
+	}
+
+	var ring Ring
+	if err := readJSONFile(*keysFile, &ring); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *index < 0 || *index >= len(ring.PrivKeys) || ring.PrivKeys[*index].IsZero() {
+		fmt.Fprintf(os.Stderr, "No private key at index %v of '%v'\n", *index, *keysFile)
+		os.Exit(1)
+	}
+
+	pre, err := ring.Presign(rand.Reader, *index)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to presign: %v\n", err)
+		os.Exit(1)
+	}
+
+	preJSON, err := json.MarshalIndent(pre, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(*outFile, preJSON, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write presignature '%v': %v\n", *outFile, err)
+		os.Exit(1)
+	}
+}
+
+// stealthHandshakeCommand outputs the fingerprint and short authentication
+// string for an exchange of public keys, optionally verifying the
+// handshake produced by the other party.
+func stealthHandshakeCommand(args []string) {
+	handshakeCmd := flag.NewFlagSet("stealth handshake", flag.ExitOnError)
+	_mySecretKey := handshakeCmd.String("s", "", "Your secret key")
+	theirPublicKeyX := handshakeCmd.String("x", "", "Their public key X point")
+	theirPublicKeyY := handshakeCmd.String("y", "", "Their public key Y point")
+	sign := handshakeCmd.Bool("sign", false, "Sign the handshake with your secret key")
+	theirHandshakeFile := handshakeCmd.String("f", "", "Verify the handshake JSON file produced by the other party")
+	seed := handshakeCmd.String("seed", "", seedUsage)
+	handshakeCmd.Parse(args)
+
+	if *_mySecretKey == "" || *theirPublicKeyX == "" || *theirPublicKeyY == "" {
+		handshakeCmd.Usage()
+		return
+	}
+
+	mySecretKey, errMSK := ParseBigInt(*_mySecretKey)
+	if errMSK != nil || mySecretKey == nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse secret key: -s %v: %v\n", *_mySecretKey, errMSK)
+		os.Exit(1)
+	}
+
+	theirPublicKey := ParseCurvePoint(*theirPublicKeyX, *theirPublicKeyY)
+	if theirPublicKey == nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse public key pair -x %v -y %v\n", *theirPublicKeyX, *theirPublicKeyY)
+		os.Exit(1)
+	}
+
+	handshake, err := NewStealthHandshake(randomSource(*seed), mySecretKey, theirPublicKey, *sign)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate handshake: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *theirHandshakeFile != "" {
+		var theirHandshake StealthHandshake
+		if err := readJSONFile(*theirHandshakeFile, &theirHandshake); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		if err := theirHandshake.Verify(handshake); err != nil {
+			fmt.Fprintf(os.Stderr, "Handshake not verified: %v\n", err)
+			os.Exit(1)
+		}
+		if theirHandshake.Signature == nil {
+			fmt.Fprintln(os.Stderr, "Warning: their handshake is not signed")
+		}
+		fmt.Printf("Handshake verified, SAS: %v\n", handshake.SAS)
+		return
+	}
+
+	handshakeJSON, err := json.MarshalIndent(handshake, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(handshakeJSON))
+}
+
+// stealthBatchCommand derives a stealth address for every recipient in a
+// contacts file, writing the manifest to stdout and optionally one
+// encrypted notice file per recipient.
+func stealthBatchCommand(args []string) {
+	batchCmd := flag.NewFlagSet("stealth batch", flag.ExitOnError)
+	_mySecretKey := batchCmd.String("s", "", "Your secret key")
+	contactsFile := batchCmd.String("f", "", "Path to a JSON file containing the recipients")
+	noticesDir := batchCmd.String("notices", "", "Directory to write an encrypted notice per recipient to")
+	version := batchCmd.Int("v", StealthV1, "Key derivation version, 1 (original) or 2 (HKDF, bound to a context), both parties must use the same")
+	contract := batchCmd.String("contract", "", "Hex encoded contract address the addresses are bound to (v2 only)")
+	_denomination := batchCmd.String("denomination", "0", "Ring denomination the addresses are bound to (v2 only)")
+	purpose := batchCmd.String("purpose", "", "Purpose the addresses are bound to (v2 only)")
+	seed := batchCmd.String("seed", "", seedUsage)
+	batchCmd.Parse(args)
+
+	if *_mySecretKey == "" || *contactsFile == "" {
+		batchCmd.Usage()
+		return
+	}
+
+	mySecretKey, err := ParseBigInt(*_mySecretKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse secret key: -s %v: %v\n", *_mySecretKey, err)
+		os.Exit(1)
+	}
+
+	var contacts []StealthContact
+	if err := readJSONFile(*contactsFile, &contacts); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := parseStealthContext(*version, *contract, *_denomination, *purpose)
+	batch, err := NewStealthBatch(randomSource(*seed), *version, ctx, mySecretKey, contacts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate stealth batch: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *noticesDir != "" {
+		files, err := batch.NoticeFiles()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write notices: %v\n", err)
+			os.Exit(1)
+		}
+
+		for i, payment := range batch.Payments {
+			noticeJSON, err := json.MarshalIndent(payment.Notice, "", "  ")
+			if err != nil {
+				panic(err)
+			}
+
+			path := filepath.Join(*noticesDir, files[i])
+			if err := ioutil.WriteFile(path, noticeJSON, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to write notice '%v': %v\n", path, err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	batchJSON, err := json.MarshalIndent(batch, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(batchJSON))
+}
+
+// stealthOpenCommand decrypts a notice received from a stealth batch and
+// outputs the stealth address, including its secret key
+func stealthOpenCommand(args []string) {
+	openCmd := flag.NewFlagSet("stealth open", flag.ExitOnError)
+	_mySecretKey := openCmd.String("s", "", "Your secret key")
+	noticeFile := openCmd.String("f", "", "Path to a JSON file containing the encrypted notice")
+	openCmd.Parse(args)
+
+	if *_mySecretKey == "" || *noticeFile == "" {
+		openCmd.Usage()
+		return
+	}
+
+	mySecretKey, err := ParseBigInt(*_mySecretKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse secret key: -s %v: %v\n", *_mySecretKey, err)
+		os.Exit(1)
+	}
+
+	var msg EncryptedMessage
+	if err := readJSONFile(*noticeFile, &msg); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	notice, address, err := OpenStealthNotice(mySecretKey, &msg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open notice: %v\n", err)
+		os.Exit(1)
+	}
+
+	addressJSON, err := json.MarshalIndent(&struct {
+		Notice  *StealthNotice         `json:"notice"`
+		Address *PrivateStealthAddress `json:"address"`
+	}{notice, address}, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(addressJSON))
+}
+
+// stealthProveCommand derives one of our stealth addresses and proves it
+// was derived from our master public key
+func stealthProveCommand(args []string) {
+	proveCmd := flag.NewFlagSet("stealth prove", flag.ExitOnError)
+	nonce := proveCmd.Int("o", 0, "Nonce of the stealth address")
+	_mySecretKey := proveCmd.String("s", "", "Your secret key")
+	theirPublicKeyX := proveCmd.String("x", "", "Their public key X point")
+	theirPublicKeyY := proveCmd.String("y", "", "Their public key Y point")
+	version := proveCmd.Int("v", StealthV1, "Key derivation version, 1 (original) or 2 (HKDF, bound to a context), both parties must use the same")
+	contract := proveCmd.String("contract", "", "Hex encoded contract address the addresses are bound to (v2 only)")
+	_denomination := proveCmd.String("denomination", "0", "Ring denomination the addresses are bound to (v2 only)")
+	purpose := proveCmd.String("purpose", "", "Purpose the addresses are bound to (v2 only)")
+	context := proveCmd.String("c", "", "Hex encoded context to include in the proof, e.g. a case reference")
+	seed := proveCmd.String("seed", "", seedUsage)
+	proveCmd.Parse(args)
+
+	if *_mySecretKey == "" || *theirPublicKeyX == "" || *theirPublicKeyY == "" {
+		proveCmd.Usage()
+		return
+	}
+
+	mySecretKey, err := ParseBigInt(*_mySecretKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse secret key: -s %v: %v\n", *_mySecretKey, err)
+		os.Exit(1)
+	}
+
+	theirPublicKey := ParseCurvePoint(*theirPublicKeyX, *theirPublicKeyY)
+	if theirPublicKey == nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse public key pair -x %v -y %v\n", *theirPublicKeyX, *theirPublicKeyY)
+		os.Exit(1)
+	}
+
+	proofContext, err := hex.DecodeString(*context)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse hex string: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := parseStealthContext(*version, *contract, *_denomination, *purpose)
+	session, err := NewVersionedStealthSession(*version, ctx, mySecretKey, theirPublicKey, *nonce, 1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate stealth session: %v\n", err)
+		os.Exit(1)
+	}
+
+	proof, err := NewStealthProof(randomSource(*seed), mySecretKey, &session.MyAddresses[0], proofContext)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate proof: %v\n", err)
+		os.Exit(1)
+	}
+
+	proofJSON, err := json.MarshalIndent(proof, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(proofJSON))
+}
+
+// stealthVerifyProofCommand verifies a proof from stealthProveCommand
+func stealthVerifyProofCommand(args []string) {
+	verifyProofCmd := flag.NewFlagSet("stealth verify-proof", flag.ExitOnError)
+	f := verifyProofCmd.String("f", "", "Path to a JSON file containing the proof")
+	verifyProofCmd.Parse(args)
+
+	if *f == "" {
+		verifyProofCmd.Usage()
+		return
+	}
+
+	var proof StealthProof
+	if err := readJSONFile(*f, &proof); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if !proof.Verify() {
+		fmt.Fprintln(os.Stderr, "Proof not verified")
+		os.Exit(1)
+	}
+	fmt.Println("Proof verified")
+}
+
+// proveTauCommand proves that the key at one index of a ring produced the
+// tau of its signatures of a message
+func proveTauCommand(args []string) {
+	proveCmd := flag.NewFlagSet("prove-tau", flag.ExitOnError)
+	keysFile := proveCmd.String("f", "", "Load the ring and signing key from a JSON file")
+	index := proveCmd.Int("i", 0, "Index of the signing key in the ring")
+	m := proveCmd.String("m", "", "The Hex encoded message that was signed")
+	context := proveCmd.String("c", "", "Hex encoded context to include in the proof, e.g. an audit reference")
+	seed := proveCmd.String("seed", "", seedUsage)
+	proveCmd.Parse(args)
+
+	if *keysFile == "" || *m == "" {
+		proveCmd.Usage()
+		return
+	}
+
+	decoded, err := hex.DecodeString(*m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse hex string: %v\n", err)
+		os.Exit(1)
+	}
+	proofContext, err := hex.DecodeString(*context)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse hex string: %v\n", err)
+		os.Exit(1)
+	}
+
+	var ring Ring
+	if err := readJSONFile(*keysFile, &ring); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *index < 0 || *index >= len(ring.PrivKeys) || ring.PrivKeys[*index].IsZero() {
+		fmt.Fprintf(os.Stderr, "No private key at index %v of '%v'\n", *index, *keysFile)
+		os.Exit(1)
+	}
+
+	proof, err := NewTauProof(randomSource(*seed), ring.PrivKeys[*index], decoded, proofContext)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate proof: %v\n", err)
+		os.Exit(1)
+	}
+
+	proofJSON, err := json.MarshalIndent(proof, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(proofJSON))
+}
+
+// verifyTauCommand verifies a proof from proveTauCommand, and with a
+// signature file that the proven key is a member of its ring and the tau
+// is that of one of its signatures
+func verifyTauCommand(args []string) {
+	verifyCmd := flag.NewFlagSet("verify-tau", flag.ExitOnError)
+	f := verifyCmd.String("f", "", "Path to a JSON file containing the proof")
+	signaturesFile := verifyCmd.String("s", "", "Path to a JSON file containing the ring and signatures the tau must be from")
+	verifyCmd.Parse(args)
+
+	if *f == "" {
+		verifyCmd.Usage()
+		return
+	}
+
+	var proof TauProof
+	if err := readJSONFile(*f, &proof); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if !proof.Verify() {
+		fmt.Fprintln(os.Stderr, "Proof not verified")
+		os.Exit(1)
+	}
+
+	if *signaturesFile == "" {
+		fmt.Println("Proof verified")
+		return
+	}
+
+	var signatures inputData
+	if err := readJSONFile(*signaturesFile, &signatures); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if false == bytes.Equal(signatures.Message, proof.Message) {
+		fmt.Fprintln(os.Stderr, "Proof is for a different message than the signatures")
+		os.Exit(1)
+	}
+
+	member := -1
+	for i := range signatures.PubKeys {
+		if signatures.PubKeys[i].Equals(&proof.Public) {
+			member = i
+			break
+		}
+	}
+	if member < 0 {
+		fmt.Fprintln(os.Stderr, "Proven public key is not a member of the ring")
+		os.Exit(1)
+	}
+
+	var taus []CurvePoint
+	for _, sig := range signatures.Signatures {
+		taus = append(taus, sig.Tau)
+	}
+	for _, sig := range signatures.CompactSignatures {
+		taus = append(taus, sig.Tau)
+	}
+	for i := range taus {
+		if taus[i].Equals(&proof.Tau) {
+			fmt.Printf("Proof verified, the tau was produced by ring member %v\n", member)
+			return
+		}
+	}
+	fmt.Fprintln(os.Stderr, "No signature with the proven tau")
+	os.Exit(1)
+}
+
+// tsignDealCommand splits the key at one index of a ring into shares and
+// writes one file per participant
+func tsignDealCommand(args []string) {
+	dealCmd := flag.NewFlagSet("tsign deal", flag.ExitOnError)
+	keysFile := dealCmd.String("f", "", "Load the ring and the key to split from a JSON file")
+	index := dealCmd.Int("i", 0, "Index of the key to split in the ring")
+	threshold := dealCmd.Int("t", 2, "Number of participants needed to sign")
+	n := dealCmd.Int("n", 3, "Number of shares")
+	outDir := dealCmd.String("o", "", "Directory to write a share per participant to")
+	seed := dealCmd.String("seed", "", seedUsage)
+	dealCmd.Parse(args)
+
+	if *keysFile == "" || *outDir == "" {
+		dealCmd.Usage()
+		return
+	}
+
+	var ring Ring
+	if err := readJSONFile(*keysFile, &ring); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *index < 0 || *index >= len(ring.PrivKeys) || ring.PrivKeys[*index].IsZero() {
+		fmt.Fprintf(os.Stderr, "No private key at index %v of '%v'\n", *index, *keysFile)
+		os.Exit(1)
+	}
+	if !onBN256(ring) {
+		fmt.Fprintf(os.Stderr, "%v\n", errNotBN256("threshold"))
+		os.Exit(1)
+	}
+
+	shares, err := SplitThresholdKey(randomSource(*seed), ring.PrivKeys[*index].Int(), *threshold, *n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to split key: %v\n", err)
+		os.Exit(1)
+	}
+
+	for i := range shares {
+		shareJSON, err := json.MarshalIndent(&shares[i], "", "  ")
+		if err != nil {
+			panic(err)
+		}
+
+		path := filepath.Join(*outDir, fmt.Sprintf("share%d.json", shares[i].Index))
+		if err := ioutil.WriteFile(path, shareJSON, 0600); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write share '%v': %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Println(path)
+	}
+}
+
+// tsignRound1Command outputs a participant's commitment and saves their
+// secret nonces for the second round
+func tsignRound1Command(args []string) {
+	round1Cmd := flag.NewFlagSet("tsign round1", flag.ExitOnError)
+	shareFile := round1Cmd.String("f", "", "Path to a JSON file containing your key share")
+	m := round1Cmd.String("m", "", "The Hex encoded message to sign")
+	stateFile := round1Cmd.String("state", "", "Path to write your secret nonces to")
+	round1Cmd.Parse(args)
+
+	if *shareFile == "" || *m == "" || *stateFile == "" {
+		round1Cmd.Usage()
+		return
+	}
+
+	decoded, err := hex.DecodeString(*m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse hex string: %v\n", err)
+		os.Exit(1)
+	}
+
+	var share ThresholdShare
+	if err := readJSONFile(*shareFile, &share); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	commitment, nonces, err := ThresholdRound1(rand.Reader, &share, decoded)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate commitment: %v\n", err)
+		os.Exit(1)
+	}
+
+	noncesJSON, err := json.MarshalIndent(nonces, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(*stateFile, noncesJSON, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write state '%v': %v\n", *stateFile, err)
+		os.Exit(1)
+	}
+
+	commitmentJSON, err := json.MarshalIndent(commitment, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(commitmentJSON))
+}
+
+// tsignRound2Command outputs a participant's response, the saved nonces
+// are deleted so they can't be used for a second signature
+func tsignRound2Command(args []string) {
+	round2Cmd := flag.NewFlagSet("tsign round2", flag.ExitOnError)
+	shareFile := round2Cmd.String("f", "", "Path to a JSON file containing your key share")
+	ringFile := round2Cmd.String("r", "", "Path to a JSON file containing the ring")
+	m := round2Cmd.String("m", "", "The Hex encoded message to sign")
+	stateFile := round2Cmd.String("state", "", "Path to your secret nonces from the first round")
+	commitmentFiles := round2Cmd.String("c", "", "Comma separated JSON files, the commitment of every participant")
+	round2Cmd.Parse(args)
+
+	if *shareFile == "" || *ringFile == "" || *m == "" || *stateFile == "" || *commitmentFiles == "" {
+		round2Cmd.Usage()
+		return
+	}
+
+	decoded, err := hex.DecodeString(*m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse hex string: %v\n", err)
+		os.Exit(1)
+	}
+
+	var share ThresholdShare
+	if err := readJSONFile(*shareFile, &share); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	var nonces ThresholdNonces
+	if err := readJSONFile(*stateFile, &nonces); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	var ring Ring
+	if err := readJSONFile(*ringFile, &ring); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	commitments := readThresholdCommitments(*commitmentFiles)
+
+	// Nonces are single use, whether or not this round succeeds
+	if err := os.Remove(*stateFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to delete state '%v': %v\n", *stateFile, err)
+		os.Exit(1)
+	}
+
+	response, err := ThresholdRound2(&share, &nonces, &ring, decoded, commitments)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate response: %v\n", err)
+		os.Exit(1)
+	}
+
+	responseJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(responseJSON))
+}
+
+// tsignFinalizeCommand combines the responses of all participants into a
+// ring signature, in the same format as sign
+func tsignFinalizeCommand(args []string) {
+	finalizeCmd := flag.NewFlagSet("tsign finalize", flag.ExitOnError)
+	ringFile := finalizeCmd.String("r", "", "Path to a JSON file containing the ring")
+	m := finalizeCmd.String("m", "", "The Hex encoded message to sign")
+	commitmentFiles := finalizeCmd.String("c", "", "Comma separated JSON files, the commitment of every participant")
+	responseFiles := finalizeCmd.String("p", "", "Comma separated JSON files, the response of every participant")
+	finalizeCmd.Parse(args)
+
+	if *ringFile == "" || *m == "" || *commitmentFiles == "" || *responseFiles == "" {
+		finalizeCmd.Usage()
+		return
+	}
+
+	decoded, err := hex.DecodeString(*m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse hex string: %v\n", err)
+		os.Exit(1)
+	}
+
+	var ring Ring
+	if err := readJSONFile(*ringFile, &ring); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	commitments := readThresholdCommitments(*commitmentFiles)
+
+	var responses []ThresholdResponse
+	for _, path := range strings.Split(*responseFiles, ",") {
+		var response ThresholdResponse
+		if err := readJSONFile(path, &response); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		responses = append(responses, response)
+	}
+
+	sig, err := ThresholdFinalize(&ring, decoded, commitments, responses)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to combine signature: %v\n", err)
+		os.Exit(1)
+	}
+
+	inputData := inputData{
+		Curve:      ring.group(),
+		PubKeys:    ring.PubKeys,
+		Proofs:     ring.Proofs,
+		Message:    decoded,
+		Signatures: []RingSignature{*sig},
+	}
+	signatureJSON, err := json.MarshalIndent(&inputData, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(signatureJSON))
+}
+
+// readThresholdCommitments reads a comma separated list of commitment files
+func readThresholdCommitments(paths string) []ThresholdCommitment {
+	var commitments []ThresholdCommitment
+	for _, path := range strings.Split(paths, ",") {
+		var commitment ThresholdCommitment
+		if err := readJSONFile(path, &commitment); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		commitments = append(commitments, commitment)
+	}
+	return commitments
+}
+
+// keysSplitCommand splits a secret key into Shamir shares
+func keysSplitCommand(args []string) {
+	splitCmd := flag.NewFlagSet("keys split", flag.ExitOnError)
+	_secretKey := splitCmd.String("s", "", "The secret key to split")
+	threshold := splitCmd.Int("t", 2, "Number of shares needed to recover the key")
+	n := splitCmd.Int("n", 3, "Number of shares")
+	feldman := splitCmd.Bool("feldman", false, "Include commitments shareholders can verify their shares with")
+	curve := splitCmd.String("curve", DefaultCurve, curveUsage)
+	seed := splitCmd.String("seed", "", seedUsage)
+	splitCmd.Parse(args)
+
+	if *_secretKey == "" {
+		splitCmd.Usage()
+		return
+	}
+
+	// Shares are modulo the order of BN256 and don't record the curve, a
+	// key of another curve may not be below it
+	if parseCurve(*curve) != BN256 {
+		fmt.Fprintf(os.Stderr, "Keys can only be split on %v\n", DefaultCurve)
+		os.Exit(1)
+	}
+
+	secretKey, err := ParseBigInt(*_secretKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse secret key: -s %v: %v\n", *_secretKey, err)
+		os.Exit(1)
+	}
+
+	split, err := ShamirSplit(randomSource(*seed), secretKey, *threshold, *n, *feldman)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to split key: %v\n", err)
+		os.Exit(1)
+	}
+
+	splitJSON, err := json.MarshalIndent(split, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(splitJSON))
+}
+
+// keysRecoverCommand recovers a secret key from Shamir shares, checking
+// them against the commitments if a split file is given
+func keysRecoverCommand(args []string) {
+	recoverCmd := flag.NewFlagSet("keys recover", flag.ExitOnError)
+	splitFile := recoverCmd.String("f", "", "Path to the output of split, to verify the shares against its commitments")
+	recoverCmd.Parse(args)
+
+	if recoverCmd.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: orbital keys recover [-f split.json] share...")
+		recoverCmd.PrintDefaults()
+		return
+	}
+
+	var commitments []CurvePoint
+	if *splitFile != "" {
+		var split SecretShares
+		if err := readJSONFile(*splitFile, &split); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if len(split.Commitments) == 0 {
+			fmt.Fprintf(os.Stderr, "No commitments in '%v'\n", *splitFile)
+			os.Exit(1)
+		}
+		commitments = split.Commitments
+	}
+
+	var shares []ShamirShare
+	for _, encoded := range recoverCmd.Args() {
+		share, err := DecodeShamirShare(encoded)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to parse share %v: %v\n", encoded, err)
+			os.Exit(1)
+		}
+		if commitments != nil && !VerifyShamirShare(share, commitments) {
+			fmt.Fprintf(os.Stderr, "Share %v not verified\n", share.Index)
+			os.Exit(1)
+		}
+		shares = append(shares, *share)
+	}
+
+	secretKey, err := ShamirRecover(shares)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to recover key: %v\n", err)
+		os.Exit(1)
+	}
+
+	keyJSON, err := json.MarshalIndent(&struct {
+		Secret *hexBig    `json:"secret"`
+		Public CurvePoint `json:"public"`
+	}{
+		Secret: (*hexBig)(secretKey),
+		Public: derivePublicKey(secretKey),
+	}, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(keyJSON))
+}
+
+// keysVerifyShareCommand lets a shareholder check their share against the
+// commitments published with the split
+func keysVerifyShareCommand(args []string) {
+	verifyShareCmd := flag.NewFlagSet("keys verify-share", flag.ExitOnError)
+	splitFile := verifyShareCmd.String("f", "", "Path to the output of split, or just its commitments")
+	encoded := verifyShareCmd.String("share", "", "The encoded share to verify")
+	verifyShareCmd.Parse(args)
+
+	if *splitFile == "" || *encoded == "" {
+		verifyShareCmd.Usage()
+		return
+	}
+
+	var split struct {
+		Commitments []CurvePoint `json:"commitments"`
+	}
+	if err := readJSONFile(*splitFile, &split); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	share, err := DecodeShamirShare(*encoded)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse share: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !VerifyShamirShare(share, split.Commitments) {
+		fmt.Fprintln(os.Stderr, "Share not verified")
+		os.Exit(1)
+	}
+	fmt.Println("Share verified")
+}
+
+// blsKeygenCommand generates a BLS key
+func blsKeygenCommand(args []string) {
+	keygenCmd := flag.NewFlagSet("bls keygen", flag.ExitOnError)
+	seed := keygenCmd.String("seed", "", seedUsage)
+	keygenCmd.Parse(args)
+
+	key, err := NewBLSKey(randomSource(*seed))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate key: %v\n", err)
+		os.Exit(1)
+	}
+
+	keyJSON, err := json.MarshalIndent(key, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(keyJSON))
+}
+
+// readRingHash returns the hash of the public keys of a ring file, which
+// is the message BLS signers attest to with -ring
+func readRingHash(path string) []byte {
+	var ring Ring
+	if err := readJSONFile(path, &ring); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	hash := ring.PublicKeysHashed()
+	return hash[:]
+}
+
+// blsSignCommand signs a message, or the public keys of a ring, with a
+// BLS key
+func blsSignCommand(args []string) {
+	signCmd := flag.NewFlagSet("bls sign", flag.ExitOnError)
+	keyFile := signCmd.String("f", "", "Load the BLS key from a JSON file")
+	m := signCmd.String("m", "", "The Hex encoded message to sign")
+	ringFile := signCmd.String("ring", "", "Sign the hash of the public keys of a ring file instead of a message")
+	signCmd.Parse(args)
+
+	if *keyFile == "" || (*m == "") == (*ringFile == "") {
+		signCmd.Usage()
+		return
+	}
+
+	var key BLSKey
+	if err := readJSONFile(*keyFile, &key); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	var message []byte
+	if *ringFile != "" {
+		message = readRingHash(*ringFile)
+	} else {
+		decoded, err := hex.DecodeString(*m)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to parse hex string: %v\n", err)
+			os.Exit(1)
+		}
+		message = decoded
+	}
+
+	sig, err := BLSSign(key.Secret, message)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to sign: %v\n", err)
+		os.Exit(1)
+	}
+
+	sigJSON, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(sigJSON))
+}
+
+// blsAggregateCommand combines BLS signature files into one signature
+func blsAggregateCommand(args []string) {
+	aggregateCmd := flag.NewFlagSet("bls aggregate", flag.ExitOnError)
+	aggregateCmd.Parse(args)
+
+	if aggregateCmd.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: orbital bls aggregate signature.json...")
+		return
+	}
+
+	sigs := make([]BLSSignature, aggregateCmd.NArg())
+	for i, path := range aggregateCmd.Args() {
+		if err := readJSONFile(path, &sigs[i]); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	aggregate, err := BLSAggregate(sigs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to aggregate signatures: %v\n", err)
+		os.Exit(1)
+	}
+
+	sigJSON, err := json.MarshalIndent(aggregate, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(sigJSON))
+}
+
+// blsVerifyCommand verifies a BLS signature by a threshold of trusted
+// keys, optionally checking every signer attested to a ring
+func blsVerifyCommand(args []string) {
+	verifyCmd := flag.NewFlagSet("bls verify", flag.ExitOnError)
+	f := verifyCmd.String("f", "", "Path to a JSON file containing the signature")
+	keysFile := verifyCmd.String("keys", "", "Path to a JSON list of the trusted public keys, every signer must be one of them")
+	threshold := verifyCmd.Int("threshold", 0, "Number of distinct trusted keys which must have signed, defaults to all of them")
+	ringFile := verifyCmd.String("ring", "", "Require every signer to have signed the public keys of a ring file")
+	verifyCmd.Parse(args)
+
+	if *f == "" || *keysFile == "" {
+		verifyCmd.Usage()
+		return
+	}
+
+	var sig BLSSignature
+	if err := readJSONFile(*f, &sig); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	var trusted []G2Point
+	if err := readJSONFile(*keysFile, &trusted); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *threshold == 0 {
+		*threshold = len(trusted)
+	}
+
+	if *ringFile != "" {
+		hash := readRingHash(*ringFile)
+		for i, signer := range sig.Signers {
+			if false == bytes.Equal(signer.Message, hash) {
+				fmt.Fprintf(os.Stderr, "Signer %v did not sign the ring\n", i)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if err := sig.VerifyTrusted(trusted, *threshold); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Signature verified, %v signers\n", len(sig.Signers))
+}
+
+// readPrivateKey returns the private key at index of the ring file at path,
+// exiting if there is none
+func readPrivateKey(path string, index int) Scalar {
+	var ring Ring
+	if err := readJSONFile(path, &ring); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if index < 0 || index >= len(ring.PrivKeys) || ring.PrivKeys[index].IsZero() {
+		fmt.Fprintf(os.Stderr, "No private key at index %v of '%v'\n", index, path)
+		os.Exit(1)
+	}
+	return ring.PrivKeys[index]
+}
+
+// writeJSONOutput prints v as indented JSON
+func writeJSONOutput(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(data))
+}
+
+// writeSecretJSONFile writes v as indented JSON to a file only the owner
+// can read, exiting on failure
+func writeSecretJSONFile(path string, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write file '%v': %v\n", path, err)
+		os.Exit(1)
+	}
+}
+
+// blindSessionPath returns the path of the session file of the signing
+// key with the public key in dir, there is one for each key so a signer
+// can only have a single session open at a time
+func blindSessionPath(dir string, public *CurvePoint) string {
+	h := sha256.Sum256(public.Marshal())
+	return filepath.Join(dir, fmt.Sprintf("blind-session-%x.json", h[:8]))
+}
+
+// blindRequestCommand opens a blind signing session with the key at an
+// index of a ring, writing the secret nonce to the key's session file and
+// printing the commitment for the requester. It refuses to open a session
+// while the key has one open, as concurrent sessions allow forgeries.
+func blindRequestCommand(args []string) {
+	requestCmd := flag.NewFlagSet("blind request", flag.ExitOnError)
+	keysFile := requestCmd.String("f", "", "Load the signing key from a JSON ring file")
+	index := requestCmd.Int("i", 0, "Index of the signing key in the ring")
+	sessionDir := requestCmd.String("d", ".", "Directory of the open sessions, the session is used by blind sign")
+	requestCmd.Parse(args)
+
+	if *keysFile == "" {
+		requestCmd.Usage()
+		return
+	}
+
+	key := readPrivateKey(*keysFile, *index)
+	session, commitment, err := NewBlindSignerSession(rand.Reader, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open session: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	path := blindSessionPath(*sessionDir, &session.Public)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		fmt.Fprintf(os.Stderr, "A session is already open for this key in '%v', finish it with blind sign first\n", path)
+		os.Exit(1)
+	}
+	if err == nil {
+		_, err = file.Write(data)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write session '%v': %v\n", path, err)
+		os.Exit(1)
+	}
+
+	writeJSONOutput(commitment)
+}
+
+// blindBlindCommand blinds a token with the signer's commitment, writing
+// the blinding factors to a file and printing the challenge for the signer
+func blindBlindCommand(args []string) {
+	blindCmd := flag.NewFlagSet("blind blind", flag.ExitOnError)
+	commitmentFile := blindCmd.String("c", "", "Path to a JSON file containing the signer's commitment")
+	m := blindCmd.String("m", "", "The Hex encoded token to be signed")
+	requestFile := blindCmd.String("o", "", "Path to write the secret blinding factors to, they are used by blind unblind")
+	seed := blindCmd.String("seed", "", seedUsage)
+	blindCmd.Parse(args)
+
+	if *commitmentFile == "" || *m == "" || *requestFile == "" {
+		blindCmd.Usage()
+		return
+	}
+
+	decoded, err := hex.DecodeString(*m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse hex string: %v\n", err)
+		os.Exit(1)
+	}
+
+	var commitment BlindCommitment
+	if err := readJSONFile(*commitmentFile, &commitment); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	request, challenge, err := NewBlindRequest(randomSource(*seed), &commitment, decoded)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to blind token: %v\n", err)
+		os.Exit(1)
+	}
+
+	writeSecretJSONFile(*requestFile, request)
+	writeJSONOutput(challenge)
+}
+
+// blindSignCommand answers a blinded challenge, deleting the key's session
+// file so the nonce can't be used twice and another session can be opened
+func blindSignCommand(args []string) {
+	signCmd := flag.NewFlagSet("blind sign", flag.ExitOnError)
+	keysFile := signCmd.String("f", "", "Load the signing key from a JSON ring file")
+	index := signCmd.Int("i", 0, "Index of the signing key in the ring")
+	sessionDir := signCmd.String("d", ".", "Directory of the open sessions, the key's session is deleted")
+	challengeFile := signCmd.String("c", "", "Path to a JSON file containing the blinded challenge")
+	signCmd.Parse(args)
+
+	if *keysFile == "" || *challengeFile == "" {
+		signCmd.Usage()
+		return
+	}
+
+	key := readPrivateKey(*keysFile, *index)
+	public := key.Group().ScalarBaseMult(key.Int())
+	sessionFile := blindSessionPath(*sessionDir, &public)
+
+	var challenge BlindChallenge
+	if err := readJSONFile(*challengeFile, &challenge); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	var session BlindSignerSession
+	if err := readJSONFile(sessionFile, &session); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	// Sessions are single use, whether or not signing succeeds
+	if err := os.Remove(sessionFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to delete session '%v': %v\n", sessionFile, err)
+		os.Exit(1)
+	}
+
+	response, err := session.Sign(key, &challenge)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to sign: %v\n", err)
+		os.Exit(1)
+	}
+	writeJSONOutput(response)
+}
+
+// blindUnblindCommand unblinds the signer's response into a signed token
+func blindUnblindCommand(args []string) {
+	unblindCmd := flag.NewFlagSet("blind unblind", flag.ExitOnError)
+	requestFile := unblindCmd.String("r", "", "Path to the blinding factors from blind blind")
+	responseFile := unblindCmd.String("s", "", "Path to a JSON file containing the signer's response")
+	unblindCmd.Parse(args)
+
+	if *requestFile == "" || *responseFile == "" {
+		unblindCmd.Usage()
+		return
+	}
+
+	var request BlindRequest
+	if err := readJSONFile(*requestFile, &request); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	var response BlindResponse
+	if err := readJSONFile(*responseFile, &response); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	token, err := request.Unblind(&response)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to unblind: %v\n", err)
+		os.Exit(1)
+	}
+	writeJSONOutput(token)
+}
+
+// blindVerifyCommand verifies a token from blindUnblindCommand was signed
+// by the operator
+func blindVerifyCommand(args []string) {
+	verifyCmd := flag.NewFlagSet("blind verify", flag.ExitOnError)
+	f := verifyCmd.String("f", "", "Path to a JSON file containing the token")
+	_public := verifyCmd.String("public", "", "The public key of the operator the token must be signed by, as x,y")
+	verifyCmd.Parse(args)
+
+	if *f == "" || *_public == "" {
+		verifyCmd.Usage()
+		os.Exit(1)
+	}
+
+	var token BlindToken
+	if err := readJSONFile(*f, &token); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	xy := strings.Split(*_public, ",")
+	var public *CurvePoint
+	if len(xy) == 2 {
+		public = ParseGroupPoint(token.Public.Group(), xy[0], xy[1])
+	}
+	if public == nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse public key: -public %v\n", *_public)
+		os.Exit(1)
+	}
+	if !token.Public.Equals(public) {
+		fmt.Fprintln(os.Stderr, "Token is signed by a different public key")
+		os.Exit(1)
+	}
+
+	if !token.Verify() {
+		fmt.Fprintln(os.Stderr, "Token not verified")
+		os.Exit(1)
+	}
+	fmt.Printf("Token verified: %x\n", token.Message)
+}
+
+// rangeProveCommand commits to a value and proves it is in range, writing
+// the opening of the commitment to a file
+func rangeProveCommand(args []string) {
+	proveCmd := flag.NewFlagSet("range prove", flag.ExitOnError)
+	_value := proveCmd.String("v", "", "The value to commit to")
+	bits := proveCmd.Int("bits", maxRangeBits, "Prove the value is below 2^bits, a power of two up to 64")
+	outFile := proveCmd.String("o", "", "Path to write the opening of the commitment to, it must be kept secret")
+	seed := proveCmd.String("seed", "", seedUsage)
+	proveCmd.Parse(args)
+
+	if *_value == "" || *outFile == "" {
+		proveCmd.Usage()
+		return
+	}
+
+	value, err := ParseBigInt(*_value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse value: -v %v: %v\n", *_value, err)
+		os.Exit(1)
+	}
+
+	random := randomSource(*seed)
+	_, opening, err := NewPedersenCommitment(random, value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to commit to value: %v\n", err)
+		os.Exit(1)
+	}
+	proof, err := NewRangeProof(random, value, opening.Blinding, *bits)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate proof: %v\n", err)
+		os.Exit(1)
+	}
+
+	openingJSON, err := json.MarshalIndent(opening, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(*outFile, openingJSON, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write opening '%v': %v\n", *outFile, err)
+		os.Exit(1)
+	}
+
+	proofJSON, err := json.MarshalIndent(proof, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(proofJSON))
+}
+
+// rangeVerifyCommand verifies a proof from rangeProveCommand, and that an
+// opening is of its commitment
+func rangeVerifyCommand(args []string) {
+	verifyCmd := flag.NewFlagSet("range verify", flag.ExitOnError)
+	f := verifyCmd.String("f", "", "Path to a JSON file containing the proof")
+	openingFile := verifyCmd.String("opening", "", "Path to a JSON file containing an opening of the commitment")
+	verifyCmd.Parse(args)
+
+	if *f == "" {
+		verifyCmd.Usage()
+		return
+	}
+
+	var proof RangeProof
+	if err := readJSONFile(*f, &proof); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if !proof.Verify() {
+		fmt.Fprintln(os.Stderr, "Proof not verified")
+		os.Exit(1)
+	}
+
+	if *openingFile != "" {
+		var opening PedersenOpening
+		if err := readJSONFile(*openingFile, &opening); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if !opening.Verify(&proof.Commitment) {
+			fmt.Fprintln(os.Stderr, "Opening is not of the commitment")
+			os.Exit(1)
+		}
+		fmt.Printf("Proof verified, the commitment is to %v\n", opening.Value.Int())
+		return
+	}
+	fmt.Printf("Proof verified, the committed value is below 2^%v\n", proof.Bits)
+}
+
+// vrfProveCommand evaluates the VRF of an input with the key at one index
+// of a ring
+func vrfProveCommand(args []string) {
+	proveCmd := flag.NewFlagSet("vrf prove", flag.ExitOnError)
+	keysFile := proveCmd.String("f", "", "Load the key from a JSON ring file")
+	index := proveCmd.Int("i", 0, "Index of the key in the ring")
+	m := proveCmd.String("m", "", "The Hex encoded input")
+	proveCmd.Parse(args)
+
+	if *keysFile == "" || *m == "" {
+		proveCmd.Usage()
+		return
+	}
+
+	decoded, err := hex.DecodeString(*m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse hex string: %v\n", err)
+		os.Exit(1)
+	}
+
+	var ring Ring
+	if err := readJSONFile(*keysFile, &ring); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if *index < 0 || *index >= len(ring.PrivKeys) || ring.PrivKeys[*index].IsZero() {
+		fmt.Fprintf(os.Stderr, "No private key at index %v of '%v'\n", *index, *keysFile)
+		os.Exit(1)
+	}
+
+	proof, err := VRFProve(ring.PrivKeys[*index], decoded)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to evaluate VRF: %v\n", err)
+		os.Exit(1)
+	}
+
+	proofJSON, err := json.MarshalIndent(proof, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(proofJSON))
+}
+
+// vrfVerifyCommand verifies a proof from vrfProveCommand by a public key
+// for an input fixed in advance, and prints the order of a number of items
+// determined by its output
+func vrfVerifyCommand(args []string) {
+	verifyCmd := flag.NewFlagSet("vrf verify", flag.ExitOnError)
+	f := verifyCmd.String("f", "", "Path to a JSON file containing the proof")
+	_public := verifyCmd.String("public", "", "The public key the proof must be by, as x,y")
+	m := verifyCmd.String("m", "", "The Hex encoded input the proof must be of")
+	n := verifyCmd.Int("order", 0, "Print the order of this many items determined by the output")
+	verifyCmd.Parse(args)
+
+	if *f == "" || *_public == "" || *m == "" {
+		verifyCmd.Usage()
+		os.Exit(1)
+	}
+
+	input, err := hex.DecodeString(*m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse hex string: %v\n", err)
+		os.Exit(1)
+	}
+
+	xy := strings.Split(*_public, ",")
+	var public *CurvePoint
+	if len(xy) == 2 {
+		public = ParseCurvePoint(xy[0], xy[1])
+	}
+	if public == nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse public key: -public %v\n", *_public)
+		os.Exit(1)
+	}
+
+	var proof VRFProof
+	if err := readJSONFile(*f, &proof); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if !proof.Public.Equals(public) {
+		fmt.Fprintln(os.Stderr, "Proof is by a different public key")
+		os.Exit(1)
+	}
+	if false == bytes.Equal(proof.Input, input) {
+		fmt.Fprintln(os.Stderr, "Proof is of a different input")
+		os.Exit(1)
+	}
+
+	if !proof.Verify() {
+		fmt.Fprintln(os.Stderr, "Proof not verified")
+		os.Exit(1)
+	}
+	fmt.Printf("Proof verified, output %x\n", proof.Output)
+
+	if *n > 0 {
+		order, err := proof.Permutation(*n)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		orderJSON, err := json.Marshal(order)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(orderJSON))
+	}
+}
+
+// parseStealthContext parses the command line flags which bind stealth
+// addresses to a contract, denomination and purpose. Only version 2 has a
+// context, so they are an error with any other version rather than ignored.
+func parseStealthContext(version int, contract string, denomination string, purpose string) *StealthContext {
+	if version != StealthV2 {
+		if contract != "" || denomination != "0" || purpose != "" {
+			fmt.Fprintln(os.Stderr, "-contract, -denomination and -purpose are only used with -v 2")
+			os.Exit(1)
+		}
+		return nil
+	}
+
+	contractAddress, err := hex.DecodeString(strings.TrimPrefix(contract, "0x"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse contract address: -contract %v: %v\n", contract, err)
+		os.Exit(1)
+	}
+
+	d, err := ParseBigInt(denomination)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to parse denomination: -denomination %v: %v\n", denomination, err)
+		os.Exit(1)
+	}
+
+	return &StealthContext{
+		Contract:     contractAddress,
+		Denomination: d,
+		Purpose:      purpose,
+	}
+}
+
+// seedUsage describes the -seed flag of the commands which generate randomness
+const seedUsage = "Seed for reproducible output, insecure, for demos and tests only"
+
+// allowMissingPopUsage describes the -allow-missing-pop flag of the
+// commands which check a ring
+const allowMissingPopUsage = "Accept ring members without a proof of possession, for legacy rings, invalid proofs are always rejected"
+
+// verifyRingPossession checks the proofs of possession of a ring, allowing
+// members without one with -allow-missing-pop
+func verifyRingPossession(ring *Ring, allowMissing bool) error {
+	if allowMissing {
+		return ring.VerifyPossessionAllowMissing()
+	}
+	return ring.VerifyPossession()
+}
+
+var curveUsage = "Curve of the keys, one of " + CurveNames()
+
+// parseCurve returns the group named by the -curve flag, exiting if there
+// is no such curve
+func parseCurve(name string) Group {
+	g, err := CurveByName(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	return g
+}
+
+// randomSource returns the system random number generator, or when a seed
+// is given a generator whose output is determined by it. Anyone who knows
+// the seed can reproduce the keys and nonces, so it must never be used for
+// real funds.
+func randomSource(seed string) io.Reader {
+	if seed == "" {
+		return rand.Reader
+	}
+
+	fmt.Fprintln(os.Stderr, "Warning: using a seeded random number generator, the output is not secret")
+	return newHmacDRBG([]byte(seed))
+}
+
+// readJSONFile reads the file at path and decodes its JSON contents into v
+func readJSONFile(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read file '%v': %v", path, err)
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("Unable to parse file '%v': %v", path, err)
+	}
+
+	return nil
+}
@@ -3,3 +3,223 @@
 // SPDX-License-Identifier: LGPL-3.0+
 
 package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Update the golden files in testdata")
+
+// runMainEnv is set to run the command line instead of the tests, so they
+// can run it as a separate process
+const runMainEnv = "ORBITAL_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runOrbital runs the command line with args in dir, returning its output
+func runOrbital(dir string, args ...string) ([]byte, error) {
+	binary, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(binary, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("orbital %v: %v: %s", strings.Join(args, " "), err, stderr.Bytes())
+	}
+	return out, nil
+}
+
+// orbital runs the command line with args in dir, writing its output to
+// the file out unless it is empty
+func orbital(t *testing.T, dir string, out string, args ...string) {
+	data, err := runOrbital(dir, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "" {
+		if err := ioutil.WriteFile(filepath.Join(dir, out), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkGolden compares actual with the named file in testdata
+func checkGolden(t *testing.T, name string, actual []byte) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Fatalf("Output differs from %v:\n%s", path, actual)
+	}
+}
+
+func TestSeededGenerateGolden(t *testing.T) {
+	// Equivalent to: orbital generate -n 2 -seed orbital -allow-missing-pop
+	ring := &Ring{}
+	if err := ring.Generate(newHmacDRBG([]byte("orbital")), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	ringJSON, err := json.MarshalIndent(ring, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "generate-seed-orbital.json", append(ringJSON, '\n'))
+}
+
+func TestSeededSignatureGolden(t *testing.T) {
+	random := newHmacDRBG([]byte("orbital"))
+	ring := &Ring{}
+	if err := ring.Generate(random, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	message := []byte("foobarbaz")
+	sig, err := ring.Signature(random, ring.PrivKeys[1], message, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ring.VerifySignature(message, *sig) {
+		t.Fatal("Signature not verified")
+	}
+
+	sigJSON, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "signature-seed-orbital.json", append(sigJSON, '\n'))
+}
+
+func TestCommandLineSignVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orbital")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	orbital(t, dir, "keys.json", "generate", "-n", "3")
+	orbital(t, dir, "keys2.json", "generate", "-n", "3")
+	for _, scheme := range []string{SchemeCtlist, SchemeLSAG} {
+		orbital(t, dir, "sig.json", "sign", "-f", "keys.json", "-i", "1", "-m", "0102", "-scheme", scheme)
+		orbital(t, dir, "", "verify", "-f", "sig.json", "-m", "0102")
+	}
+	for _, scheme := range []string{SchemeMLSAG, SchemeCLSAG} {
+		orbital(t, dir, "sig.json", "sign", "-layers", "keys.json,keys2.json", "-i", "1", "-m", "0102", "-scheme", scheme)
+		orbital(t, dir, "", "verify", "-f", "sig.json", "-m", "0102")
+	}
+
+	orbital(t, dir, "", "tsign", "deal", "-f", "keys.json", "-i", "1", "-t", "2", "-n", "3", "-o", ".")
+	for _, i := range []string{"1", "3"} {
+		orbital(t, dir, "commit"+i+".json", "tsign", "round1", "-f", "share"+i+".json", "-m", "0102", "-state", "state"+i+".json")
+	}
+	for _, i := range []string{"1", "3"} {
+		orbital(t, dir, "response"+i+".json", "tsign", "round2", "-f", "share"+i+".json", "-r", "keys.json", "-m", "0102", "-state", "state"+i+".json", "-c", "commit1.json,commit3.json")
+	}
+	orbital(t, dir, "sig.json", "tsign", "finalize", "-r", "keys.json", "-m", "0102", "-c", "commit1.json,commit3.json", "-p", "response1.json,response3.json")
+	orbital(t, dir, "", "verify", "-f", "sig.json", "-m", "0102")
+}
+
+func TestCommandLineMissingPossession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orbital")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	orbital(t, dir, "keys.json", "generate", "-n", "3")
+	orbital(t, dir, "legacy.json", "generate", "-n", "3", "-allow-missing-pop")
+
+	for _, files := range []string{"legacy.json", "keys.json,legacy.json"} {
+		flag := "-layers"
+		if false == strings.Contains(files, ",") {
+			flag = "-f"
+		}
+		orbital(t, dir, "sig.json", "sign", flag, files, "-i", "0", "-m", "0102")
+		if _, err := runOrbital(dir, "verify", "-f", "sig.json", "-m", "0102"); err == nil {
+			t.Fatalf("Verified a signature by %v without proofs of possession", files)
+		}
+		orbital(t, dir, "", "verify", "-f", "sig.json", "-m", "0102", "-allow-missing-pop")
+	}
+}
+
+func TestCommandLineStealthDefaultVersion(t *testing.T) {
+	_, mySecret, err := generateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirPublic, _, err := generateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, y := theirPublic.GetXY()
+	args := []string{"stealth", "-s", fmt.Sprintf("0x%x", mySecret), "-x", fmt.Sprintf("0x%x", x), "-y", fmt.Sprintf("0x%x", y)}
+
+	// The command line derives the same addresses as the library and inputs
+	out, err := runOrbital(".", args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := NewStealthSession(mySecret, theirPublic, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(out), expected) {
+		t.Fatalf("Command line stealth session differs from NewStealthSession:\n%s\n%s", out, expected)
+	}
+
+	if _, err := runOrbital(".", append(args, "-purpose", "payroll")...); err == nil {
+		t.Fatal("Accepted a stealth context without -v 2")
+	}
+}
+
+func TestCommandLineSplitOnlyBN256(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orbital")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	orbital(t, dir, "keys.json", "generate", "-n", "3", "-curve", "secp256k1")
+	if _, err := runOrbital(dir, "tsign", "deal", "-f", "keys.json", "-i", "0", "-o", "."); err == nil {
+		t.Fatal("Dealt shares of a secp256k1 key")
+	}
+	if _, err := runOrbital(dir, "keys", "split", "-s", "0x1234", "-curve", "secp256k1"); err == nil {
+		t.Fatal("Split a secp256k1 key")
+	}
+	orbital(t, dir, "", "keys", "split", "-s", "0x1234")
+}
@@ -0,0 +1,505 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Multi-layer signature scheme identifiers, included in the JSON representation
+const (
+	// SchemeMLSAG has a response per layer for each ring member
+	SchemeMLSAG = "mlsag"
+
+	// SchemeCLSAG aggregates the layers into a single response per member
+	SchemeCLSAG = "clsag"
+)
+
+var (
+	// mlsagLabel domain separates the MLSAG challenge hashes
+	mlsagLabel = []byte("orbital-mlsag-v1")
+
+	// clsagLabel domain separates the CLSAG challenge hashes
+	clsagLabel = []byte("orbital-clsag-v1")
+
+	// clsagAggLabel domain separates the CLSAG aggregation coefficients
+	clsagAggLabel = []byte("orbital-clsag-agg-v1")
+)
+
+// An MLSAGSignature proves knowledge of the secret keys for every layer at
+// the same (hidden) index of a set of rings, with a key image per layer:
+//
+//   taus = tau_1, ..., tau_m
+//   sigma = c0, s_11, ..., s_1m, ..., s_n1, ..., s_nm
+//
+type MLSAGSignature struct {
+	Taus []CurvePoint `json:"taus"`
+	C0   *big.Int     `json:"c0"`
+	S    [][]*big.Int `json:"s"`
+}
+
+// A CLSAGSignature proves the same as an MLSAGSignature, but aggregates the
+// layers so there is a single response per ring member:
+//
+//   sigma = c0, s_1, ..., s_n
+//
+type CLSAGSignature struct {
+	Taus []CurvePoint `json:"taus"`
+	C0   *big.Int     `json:"c0"`
+	S    []*big.Int   `json:"s"`
+}
+
+// MarshalJSON converts an MLSAGSignature to a JSON representation
+func (sig *MLSAGSignature) MarshalJSON() ([]byte, error) {
+	s := make([][]*hexBig, len(sig.S))
+	for i, row := range sig.S {
+		s[i] = make([]*hexBig, len(row))
+		for j, v := range row {
+			s[i][j] = (*hexBig)(v)
+		}
+	}
+
+	return json.Marshal(&struct {
+		Scheme string       `json:"scheme"`
+		Taus   []CurvePoint `json:"taus"`
+		C0     *hexBig      `json:"c0"`
+		S      [][]*hexBig  `json:"s"`
+	}{
+		Scheme: SchemeMLSAG,
+		Taus:   sig.Taus,
+		C0:     (*hexBig)(sig.C0),
+		S:      s,
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to an MLSAGSignature struct
+func (sig *MLSAGSignature) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Scheme string       `json:"scheme"`
+		Taus   []CurvePoint `json:"taus"`
+		C0     *hexBig      `json:"c0"`
+		S      [][]*hexBig  `json:"s"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.Scheme != SchemeMLSAG {
+		return fmt.Errorf("Unexpected signature scheme: %v", aux.Scheme)
+	}
+
+	if aux.C0 == nil {
+		return errors.New("Invalid signature, no c0 specified")
+	}
+
+	s := make([][]*big.Int, len(aux.S))
+	for i, row := range aux.S {
+		s[i] = make([]*big.Int, len(row))
+		for j, v := range row {
+			if v == nil {
+				return errors.New("Invalid signature, null response")
+			}
+			s[i][j] = (*big.Int)(v)
+		}
+	}
+	sig.Taus = aux.Taus
+	sig.C0 = (*big.Int)(aux.C0)
+	sig.S = s
+	return nil
+}
+
+// MarshalJSON converts a CLSAGSignature to a JSON representation
+func (sig *CLSAGSignature) MarshalJSON() ([]byte, error) {
+	s := make([]*hexBig, len(sig.S))
+	for i, v := range sig.S {
+		s[i] = (*hexBig)(v)
+	}
+
+	return json.Marshal(&struct {
+		Scheme string       `json:"scheme"`
+		Taus   []CurvePoint `json:"taus"`
+		C0     *hexBig      `json:"c0"`
+		S      []*hexBig    `json:"s"`
+	}{
+		Scheme: SchemeCLSAG,
+		Taus:   sig.Taus,
+		C0:     (*hexBig)(sig.C0),
+		S:      s,
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a CLSAGSignature struct
+func (sig *CLSAGSignature) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Scheme string       `json:"scheme"`
+		Taus   []CurvePoint `json:"taus"`
+		C0     *hexBig      `json:"c0"`
+		S      []*hexBig    `json:"s"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.Scheme != SchemeCLSAG {
+		return fmt.Errorf("Unexpected signature scheme: %v", aux.Scheme)
+	}
+
+	if aux.C0 == nil {
+		return errors.New("Invalid signature, no c0 specified")
+	}
+
+	s := make([]*big.Int, len(aux.S))
+	for i, v := range aux.S {
+		if v == nil {
+			return errors.New("Invalid signature, null response")
+		}
+		s[i] = (*big.Int)(v)
+	}
+	sig.Taus = aux.Taus
+	sig.C0 = (*big.Int)(aux.C0)
+	sig.S = s
+	return nil
+}
+
+// layerMessagePoints maps the message of each layer onto the curve, a
+// single message is shared by all layers
+func layerMessagePoints(messages [][]byte, m int) ([]*CurvePoint, error) {
+	if len(messages) != 1 && len(messages) != m {
+		return nil, fmt.Errorf("Expected 1 or %v messages but got %v", m, len(messages))
+	}
+
+	points := make([]*CurvePoint, m)
+	for j := range points {
+		if len(messages) == 1 {
+			points[j] = messagePoint(messages[0])
+		} else {
+			points[j] = messagePoint(messages[j])
+		}
+	}
+	return points, nil
+}
+
+// checkLayers verifies every layer is a ring of the same size, returning it
+func checkLayers(layers []Ring) (int, error) {
+	if len(layers) == 0 {
+		return 0, errors.New("No layers provided")
+	}
+
+	n := len(layers[0].PubKeys)
+	if n == 0 {
+		return 0, errors.New("Empty ring")
+	}
+
+	if !onBN256(layers...) {
+		return 0, errNotBN256("layered")
+	}
+
+	for j, layer := range layers {
+		if len(layer.PubKeys) != n {
+			return 0, fmt.Errorf("Layer %v has %v members, expected %v", j, len(layer.PubKeys), n)
+		}
+	}
+	return n, nil
+}
+
+// layersPrefix binds a challenge to every ring, message point and key image
+func layersPrefix(layers []Ring, hashps []*CurvePoint, taus []CurvePoint) [][]byte {
+	var prefix [][]byte
+	for j := range layers {
+		ringHash := layers[j].PublicKeysHashed()
+		prefix = append(prefix, ringHash[:], hashps[j].Marshal(), taus[j].Marshal())
+	}
+	return prefix
+}
+
+// checkSignatureScalars checks every scalar is in the range [0, N)
+func checkSignatureScalars(scalars ...*big.Int) bool {
+	N := CurvePoint{}.Order()
+	for _, v := range scalars {
+		if v == nil || v.Sign() < 0 || v.Cmp(N) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkKeyImages checks every key image is a valid point, other than the
+// point at infinity
+func checkKeyImages(taus []CurvePoint, m int) bool {
+	if len(taus) != m {
+		return false
+	}
+	for _, tau := range taus {
+		if tau.p == nil || tau.IsInfinity() || !tau.IsOnCurve() {
+			return false
+		}
+	}
+	return true
+}
+
+// MLSAGSign generates an MLSAG signature with the secret keys of every
+// layer at the signer index, from Noether (IACR 2015/1098):
+//
+//   tau_j ← H_j(m)^x_j
+//   c_{π+1} ← H(..., g^u_1, H_1^u_1, ..., g^u_m, H_m^u_m)
+//   c_{i+1} ← H(..., g^s_i1 · y_i1^c_i, H_1^s_i1 · tau_1^c_i, ...)   for i ≠ π
+//   s_πj ← u_j - c_π·x_j
+//
+func MLSAGSign(random io.Reader, layers []Ring, keys []*big.Int, messages [][]byte, signer int) (*MLSAGSignature, error) {
+	N := CurvePoint{}.Order()
+	n, err := checkLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+	m := len(layers)
+
+	if len(keys) != m {
+		return nil, fmt.Errorf("Expected %v keys but got %v", m, len(keys))
+	}
+	if signer < 0 || signer >= n {
+		return nil, fmt.Errorf("Signer index out of range: %v", signer)
+	}
+
+	hashps, err := layerMessagePoints(messages, m)
+	if err != nil {
+		return nil, err
+	}
+
+	xs := make([]*big.Int, m)
+	taus := make([]CurvePoint, m)
+	for j := range keys {
+		xs[j] = new(big.Int).Mod(keys[j], N)
+		taus[j] = hashps[j].ScalarMult(xs[j])
+	}
+	prefix := layersPrefix(layers, hashps, taus)
+
+	s := make([][]*big.Int, n)
+	c := make([]*big.Int, n)
+
+	us := make([]*big.Int, m)
+	commitments := append([][]byte{}, prefix...)
+	for j := range us {
+		var err error
+		us[j], err = CurvePoint{}.RandomN(random)
+		if err != nil {
+			return nil, err
+		}
+		L := CurvePoint{}.ScalarBaseMult(us[j])
+		R := hashps[j].ScalarMult(us[j])
+		commitments = append(commitments, L.Marshal(), R.Marshal())
+	}
+	c[(signer+1)%n] = hashToScalar(mlsagLabel, commitments...)
+
+	for k := 1; k < n; k++ {
+		i := (signer + k) % n
+		s[i] = make([]*big.Int, m)
+		commitments = append([][]byte{}, prefix...)
+		for j := 0; j < m; j++ {
+			var err error
+			s[i][j], err = CurvePoint{}.RandomN(random)
+			if err != nil {
+				return nil, err
+			}
+			L := layers[j].PubKeys[i].ParameterPointAdd(s[i][j], c[i])
+			R := hashps[j].HashPointAdd(taus[j], s[i][j], c[i])
+			commitments = append(commitments, L.Marshal(), R.Marshal())
+		}
+		c[(i+1)%n] = hashToScalar(mlsagLabel, commitments...)
+	}
+
+	s[signer] = make([]*big.Int, m)
+	for j := 0; j < m; j++ {
+		cx := new(big.Int).Mul(c[signer], xs[j])
+		s[signer][j] = new(big.Int).Sub(us[j], cx)
+		s[signer][j].Mod(s[signer][j], N)
+	}
+
+	return &MLSAGSignature{taus, c[0], s}, nil
+}
+
+// MLSAGVerify verifies an MLSAG signature over a set of rings
+func MLSAGVerify(layers []Ring, messages [][]byte, sigma MLSAGSignature) bool {
+	n, err := checkLayers(layers)
+	if err != nil {
+		return false
+	}
+	m := len(layers)
+
+	hashps, err := layerMessagePoints(messages, m)
+	if err != nil {
+		return false
+	}
+
+	if len(sigma.S) != n || !checkKeyImages(sigma.Taus, m) || !checkSignatureScalars(sigma.C0) {
+		return false
+	}
+	for _, row := range sigma.S {
+		if len(row) != m || !checkSignatureScalars(row...) {
+			return false
+		}
+	}
+	prefix := layersPrefix(layers, hashps, sigma.Taus)
+
+	c := sigma.C0
+	for i := 0; i < n; i++ {
+		commitments := append([][]byte{}, prefix...)
+		for j := 0; j < m; j++ {
+			L := layers[j].PubKeys[i].ParameterPointAdd(sigma.S[i][j], c)
+			R := hashps[j].HashPointAdd(sigma.Taus[j], sigma.S[i][j], c)
+			commitments = append(commitments, L.Marshal(), R.Marshal())
+		}
+		c = hashToScalar(mlsagLabel, commitments...)
+	}
+
+	return c.Cmp(sigma.C0) == 0
+}
+
+// clsagCoefficients derives the aggregation coefficient of each layer:
+//
+//   μ_j ← H_agg(j, ...)
+//
+func clsagCoefficients(prefix [][]byte, m int) []*big.Int {
+	mu := make([]*big.Int, m)
+	for j := range mu {
+		mu[j] = hashToScalar(clsagAggLabel, append([][]byte{{byte(j >> 8), byte(j)}}, prefix...)...)
+	}
+	return mu
+}
+
+// clsagChallenge computes the next challenge in the ring
+func clsagChallenge(prefix [][]byte, L CurvePoint, R CurvePoint) *big.Int {
+	parts := append([][]byte{}, prefix...)
+	return hashToScalar(clsagLabel, append(parts, L.Marshal(), R.Marshal())...)
+}
+
+// clsagAggregate returns Π P_j^μ_j
+func clsagAggregate(points []CurvePoint, mu []*big.Int) CurvePoint {
+	acc := points[0].ScalarMult(mu[0])
+	for j := 1; j < len(points); j++ {
+		acc = acc.Add(points[j].ScalarMult(mu[j]))
+	}
+	return acc
+}
+
+// CLSAGSign generates a CLSAG signature with the secret keys of every
+// layer at the signer index, see Goodell, Noether & RandomRun (IACR
+// 2019/654). All layers share the message, so the aggregated key image
+// has the same base as each layer's key image:
+//
+//   W_i ← Π y_ij^μ_j,  W~ ← Π tau_j^μ_j
+//   c_{π+1} ← H(..., g^u, H(m)^u)
+//   c_{i+1} ← H(..., g^s_i · W_i^c_i, H(m)^s_i · W~^c_i)   for i ≠ π
+//   s_π ← u - c_π·Σ μ_j·x_j
+//
+func CLSAGSign(random io.Reader, layers []Ring, keys []*big.Int, message []byte, signer int) (*CLSAGSignature, error) {
+	N := CurvePoint{}.Order()
+	n, err := checkLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+	m := len(layers)
+
+	if len(keys) != m {
+		return nil, fmt.Errorf("Expected %v keys but got %v", m, len(keys))
+	}
+	if signer < 0 || signer >= n {
+		return nil, fmt.Errorf("Signer index out of range: %v", signer)
+	}
+
+	hashp := messagePoint(message)
+	hashps := make([]*CurvePoint, m)
+	taus := make([]CurvePoint, m)
+	for j := range keys {
+		hashps[j] = hashp
+		taus[j] = hashp.ScalarMult(new(big.Int).Mod(keys[j], N))
+	}
+	prefix := layersPrefix(layers, hashps, taus)
+	mu := clsagCoefficients(prefix, m)
+
+	// Aggregated secret key, key image and public keys
+	x := new(big.Int)
+	for j := range keys {
+		x.Add(x, new(big.Int).Mul(mu[j], keys[j]))
+	}
+	x.Mod(x, N)
+	tau := clsagAggregate(taus, mu)
+
+	s := make([]*big.Int, n)
+	c := make([]*big.Int, n)
+
+	u, err := CurvePoint{}.RandomN(random)
+	if err != nil {
+		return nil, err
+	}
+	L := CurvePoint{}.ScalarBaseMult(u)
+	R := hashp.ScalarMult(u)
+	c[(signer+1)%n] = clsagChallenge(prefix, L, R)
+
+	for k := 1; k < n; k++ {
+		i := (signer + k) % n
+		s[i], err = CurvePoint{}.RandomN(random)
+		if err != nil {
+			return nil, err
+		}
+
+		W := clsagAggregate(memberKeys(layers, i), mu)
+		L = W.ParameterPointAdd(s[i], c[i])
+		R = hashp.HashPointAdd(tau, s[i], c[i])
+		c[(i+1)%n] = clsagChallenge(prefix, L, R)
+	}
+
+	cx := new(big.Int).Mul(c[signer], x)
+	s[signer] = new(big.Int).Sub(u, cx)
+	s[signer].Mod(s[signer], N)
+
+	return &CLSAGSignature{taus, c[0], s}, nil
+}
+
+// CLSAGVerify verifies a CLSAG signature over a set of rings
+func CLSAGVerify(layers []Ring, message []byte, sigma CLSAGSignature) bool {
+	n, err := checkLayers(layers)
+	if err != nil {
+		return false
+	}
+	m := len(layers)
+
+	if len(sigma.S) != n || !checkKeyImages(sigma.Taus, m) || !checkSignatureScalars(sigma.C0) || !checkSignatureScalars(sigma.S...) {
+		return false
+	}
+
+	hashp := messagePoint(message)
+	hashps := make([]*CurvePoint, m)
+	for j := range hashps {
+		hashps[j] = hashp
+	}
+	prefix := layersPrefix(layers, hashps, sigma.Taus)
+	mu := clsagCoefficients(prefix, m)
+	tau := clsagAggregate(sigma.Taus, mu)
+
+	c := sigma.C0
+	for i := 0; i < n; i++ {
+		W := clsagAggregate(memberKeys(layers, i), mu)
+		L := W.ParameterPointAdd(sigma.S[i], c)
+		R := hashp.HashPointAdd(tau, sigma.S[i], c)
+		c = clsagChallenge(prefix, L, R)
+	}
+
+	return c.Cmp(sigma.C0) == 0
+}
+
+// memberKeys returns the public key of member i in every layer
+func memberKeys(layers []Ring, i int) []CurvePoint {
+	keys := make([]CurvePoint, len(layers))
+	for j := range layers {
+		keys[j] = layers[j].PubKeys[i]
+	}
+	return keys
+}
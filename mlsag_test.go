@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func generateLayers(n int, m int) []Ring {
+	layers := make([]Ring, m)
+	for j := range layers {
+		layers[j] = generateRing(n)
+	}
+	return layers
+}
+
+func layerKeys(layers []Ring, i int) []*big.Int {
+	keys := make([]*big.Int, len(layers))
+	for j := range layers {
+		keys[j] = layers[j].PrivKeys[i].Int()
+	}
+	return keys
+}
+
+func TestMLSAGSignature(t *testing.T) {
+	message := []byte("foobarbaz")
+
+	for _, size := range [][2]int{{1, 1}, {3, 1}, {3, 2}, {4, 3}} {
+		n, m := size[0], size[1]
+		layers := generateLayers(n, m)
+
+		for i := 0; i < n; i++ {
+			sig, err := MLSAGSign(rand.Reader, layers, layerKeys(layers, i), [][]byte{message}, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !MLSAGVerify(layers, [][]byte{message}, *sig) {
+				t.Errorf("Signature not verified for %v members and %v layers", n, m)
+			}
+		}
+	}
+}
+
+func TestMLSAGKeyImages(t *testing.T) {
+	layers := generateLayers(3, 2)
+	messages := [][]byte{[]byte("ring one"), []byte("ring two")}
+
+	sig, err := MLSAGSign(rand.Reader, layers, layerKeys(layers, 1), messages, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !MLSAGVerify(layers, messages, *sig) {
+		t.Fatal("Signature with a message per layer not verified")
+	}
+
+	// The key image of each layer matches a signature in that ring alone
+	for j := range layers {
+		single, err := layers[j].Signature(rand.Reader, layers[j].PrivKeys[1], messages[j], 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !single.Tau.Equals(&sig.Taus[j]) {
+			t.Errorf("Key image of layer %v differs from single ring signature", j)
+		}
+	}
+}
+
+func TestMLSAGSignatureBad(t *testing.T) {
+	layers := generateLayers(3, 2)
+	message := [][]byte{[]byte("foobarbaz")}
+
+	sig, err := MLSAGSign(rand.Reader, layers, layerKeys(layers, 0), message, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if MLSAGVerify(layers, [][]byte{[]byte("badmessage")}, *sig) {
+		t.Fatal("Signature verified for wrong message")
+	}
+
+	// Keys from different indexes of each layer
+	mixed := []*big.Int{layers[0].PrivKeys[0].Int(), layers[1].PrivKeys[1].Int()}
+	forged, err := MLSAGSign(rand.Reader, layers, mixed, message, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if MLSAGVerify(layers, message, *forged) {
+		t.Fatal("Signature with keys from different indexes verified")
+	}
+
+	swapped := []Ring{layers[1], layers[0]}
+	if MLSAGVerify(swapped, message, *sig) {
+		t.Fatal("Signature verified with layers reordered")
+	}
+
+	if _, err := MLSAGSign(rand.Reader, layers, layerKeys(layers, 0)[:1], message, 0); err == nil {
+		t.Fatal("Accepted fewer keys than layers")
+	}
+
+	uneven := []Ring{layers[0], generateRing(2)}
+	if _, err := MLSAGSign(rand.Reader, uneven, layerKeys(layers, 0), message, 0); err == nil {
+		t.Fatal("Accepted layers of different sizes")
+	}
+}
+
+func TestCLSAGSignature(t *testing.T) {
+	message := []byte("foobarbaz")
+
+	for _, size := range [][2]int{{1, 1}, {3, 1}, {3, 2}, {4, 3}} {
+		n, m := size[0], size[1]
+		layers := generateLayers(n, m)
+
+		for i := 0; i < n; i++ {
+			sig, err := CLSAGSign(rand.Reader, layers, layerKeys(layers, i), message, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := n
+			actual := len(sig.S)
+			if actual != expected {
+				t.Errorf("Expected %v but got %v", expected, actual)
+			}
+			if !CLSAGVerify(layers, message, *sig) {
+				t.Errorf("Signature not verified for %v members and %v layers", n, m)
+			}
+		}
+	}
+}
+
+func TestCLSAGSignatureBad(t *testing.T) {
+	layers := generateLayers(3, 2)
+	message := []byte("foobarbaz")
+
+	sig, err := CLSAGSign(rand.Reader, layers, layerKeys(layers, 2), message, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if CLSAGVerify(layers, []byte("badmessage"), *sig) {
+		t.Fatal("Signature verified for wrong message")
+	}
+
+	mixed := []*big.Int{layers[0].PrivKeys[2].Int(), layers[1].PrivKeys[0].Int()}
+	forged, err := CLSAGSign(rand.Reader, layers, mixed, message, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if CLSAGVerify(layers, message, *forged) {
+		t.Fatal("Signature with keys from different indexes verified")
+	}
+
+	// Swapping key images between layers changes the aggregation
+	bad := *sig
+	bad.Taus = []CurvePoint{sig.Taus[1], sig.Taus[0]}
+	if CLSAGVerify(layers, message, bad) {
+		t.Fatal("Signature verified with key images swapped")
+	}
+}
+
+func TestMultiLayerSignatureJSON(t *testing.T) {
+	layers := generateLayers(2, 2)
+	message := []byte("foobarbaz")
+
+	mlsag, err := MLSAGSign(rand.Reader, layers, layerKeys(layers, 0), [][]byte{message}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clsag, err := CLSAGSign(rand.Reader, layers, layerKeys(layers, 1), message, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(mlsag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decodedMLSAG MLSAGSignature
+	if err := json.Unmarshal(data, &decodedMLSAG); err != nil {
+		t.Fatal(err)
+	}
+	if !MLSAGVerify(layers, [][]byte{message}, decodedMLSAG) {
+		t.Fatal("MLSAG signature not verified after JSON round trip")
+	}
+
+	var wrongScheme CLSAGSignature
+	if err := json.Unmarshal(data, &wrongScheme); err == nil {
+		t.Fatal("Decoded MLSAG signature as CLSAG")
+	}
+
+	data, err = json.Marshal(clsag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decodedCLSAG CLSAGSignature
+	if err := json.Unmarshal(data, &decodedCLSAG); err != nil {
+		t.Fatal(err)
+	}
+	if !CLSAGVerify(layers, message, decodedCLSAG) {
+		t.Fatal("CLSAG signature not verified after JSON round trip")
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import "math/big"
+
+// strausWindow is the number of bits of each scalar processed per step of
+// Straus' method and the fixed-base tables
+const strausWindow = 4
+
+// pippengerThreshold is the number of points from which Pippenger's
+// bucket method is faster than Straus' method
+const pippengerThreshold = 32
+
+// doubleTimes returns p doubled k times. The doublings are done by bn256
+// as a multiplication by 2^k, which is much faster than adding the point
+// to itself as Add only detects doubling after most of the work.
+func doubleTimes(p CurvePoint, k int) CurvePoint {
+	if p.IsInfinity() {
+		return p
+	}
+	return p.ScalarMult(new(big.Int).Lsh(bigOne, uint(k)))
+}
+
+// scalarDigit returns the width bits of x starting at bit offset
+func scalarDigit(x *big.Int, offset int, width int) int {
+	d := 0
+	for i := width - 1; i >= 0; i-- {
+		d = d<<1 | int(x.Bit(offset+i))
+	}
+	return d
+}
+
+// reduceScalars returns the scalars reduced modulo the group order, and
+// the number of bits of the largest
+func reduceScalars(g Group, scalars []*big.Int) ([]*big.Int, int) {
+	N := g.Order()
+
+	reduced := make([]*big.Int, len(scalars))
+	bitLen := 0
+	for i, x := range scalars {
+		reduced[i] = new(big.Int).Mod(x, N)
+		if reduced[i].BitLen() > bitLen {
+			bitLen = reduced[i].BitLen()
+		}
+	}
+	return reduced, bitLen
+}
+
+// MultiScalarMult returns the sum of the points of the group each
+// multiplied by the corresponding scalar:
+//
+//   ∑ points_i · scalars_i
+//
+// This is much faster than multiplying each point separately, as the
+// doublings are shared between all of the points. The sum of no points is
+// the point at infinity of the group.
+//
+func MultiScalarMult(g Group, points []CurvePoint, scalars []*big.Int) CurvePoint {
+	if len(points) != len(scalars) {
+		panic("MultiScalarMult: number of points and scalars differ")
+	}
+
+	if len(points) == 0 {
+		return g.Infinity()
+	}
+	// A single point shares no doublings, bn256 multiplies it faster
+	if len(points) == 1 {
+		return points[0].ScalarMult(scalars[0])
+	}
+	if len(points) < pippengerThreshold {
+		return straus(g, points, scalars)
+	}
+	return pippenger(g, points, scalars)
+}
+
+// straus implements Straus' method (Shamir's trick) with a fixed window,
+// the multiples 1..2^w-1 of every point are precomputed and the scalars
+// are processed together from the most significant window down
+func straus(g Group, points []CurvePoint, scalars []*big.Int) CurvePoint {
+	reduced, bitLen := reduceScalars(g, scalars)
+
+	tables := make([][]CurvePoint, len(points))
+	for i, p := range points {
+		tables[i] = make([]CurvePoint, 1<<strausWindow)
+		tables[i][1] = p
+		for d := 2; d < len(tables[i]); d++ {
+			tables[i][d] = tables[i][d-1].Add(p)
+		}
+	}
+
+	acc := g.Infinity()
+	windows := (bitLen + strausWindow - 1) / strausWindow
+	for w := windows - 1; w >= 0; w-- {
+		acc = doubleTimes(acc, strausWindow)
+		for i := range points {
+			if d := scalarDigit(reduced[i], w*strausWindow, strausWindow); d != 0 {
+				acc = acc.Add(tables[i][d])
+			}
+		}
+	}
+
+	return acc
+}
+
+// pippengerWindow returns the window width for Pippenger's method with n points
+func pippengerWindow(n int) int {
+	c := big.NewInt(int64(n)).BitLen() - 2
+	if c < strausWindow {
+		return strausWindow
+	}
+	return c
+}
+
+// pippenger implements Pippenger's bucket method, for each window the
+// points are sorted into buckets by their digit and the buckets are
+// summed with a running total so bucket d is counted d times
+func pippenger(g Group, points []CurvePoint, scalars []*big.Int) CurvePoint {
+	reduced, bitLen := reduceScalars(g, scalars)
+	c := pippengerWindow(len(points))
+
+	acc := g.Infinity()
+	windows := (bitLen + c - 1) / c
+	for w := windows - 1; w >= 0; w-- {
+		acc = doubleTimes(acc, c)
+
+		buckets := make([]*CurvePoint, 1<<uint(c))
+		for i, p := range points {
+			d := scalarDigit(reduced[i], w*c, c)
+			if d == 0 {
+				continue
+			}
+			if buckets[d] == nil {
+				bucket := p
+				buckets[d] = &bucket
+			} else {
+				*buckets[d] = buckets[d].Add(p)
+			}
+		}
+
+		running := g.Infinity()
+		sum := g.Infinity()
+		for d := len(buckets) - 1; d > 0; d-- {
+			if buckets[d] != nil {
+				running = running.Add(*buckets[d])
+			}
+			sum = sum.Add(running)
+		}
+		acc = acc.Add(sum)
+	}
+
+	return acc
+}
+
+// A fixedBaseTable holds the multiples d·16^i·P of a point for every
+// 4 bit window i and digit d, so multiplying P by a scalar takes one
+// addition per window and no doublings.
+type fixedBaseTable struct {
+	group   Group
+	windows [][]CurvePoint
+}
+
+// newFixedBaseTable precomputes the table of multiples of p
+func newFixedBaseTable(p CurvePoint) *fixedBaseTable {
+	g := p.Group()
+	count := (g.Order().BitLen() + strausWindow - 1) / strausWindow
+
+	t := &fixedBaseTable{group: g, windows: make([][]CurvePoint, count)}
+	base := p
+	for i := range t.windows {
+		t.windows[i] = make([]CurvePoint, 1<<strausWindow)
+		t.windows[i][1] = base
+		for d := 2; d < len(t.windows[i]); d++ {
+			t.windows[i][d] = t.windows[i][d-1].Add(base)
+		}
+		base = t.windows[i][len(t.windows[i])-1].Add(base)
+	}
+
+	return t
+}
+
+// mul returns the point the table was built for multiplied by x
+func (t *fixedBaseTable) mul(x *big.Int) CurvePoint {
+	x = new(big.Int).Mod(x, t.group.Order())
+
+	acc := t.group.Infinity()
+	for i := range t.windows {
+		if d := scalarDigit(x, i*strausWindow, strausWindow); d != 0 {
+			acc = acc.Add(t.windows[i][d])
+		}
+	}
+	return acc
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/clearmatics/bn256"
+)
+
+// naiveMultiScalarMult multiplies each point separately with bn256
+func naiveMultiScalarMult(points []CurvePoint, scalars []*big.Int) CurvePoint {
+	acc := BN256.Infinity()
+	for i, p := range points {
+		acc = acc.Add(newBN256Point(new(bn256.G1).ScalarMult(p.g1(), scalars[i])))
+	}
+	return acc
+}
+
+// randomPointsAndScalars returns n random points and scalars
+func randomPointsAndScalars(t testing.TB, n int) ([]CurvePoint, []*big.Int) {
+	points := make([]CurvePoint, n)
+	scalars, err := randomScalars(rand.Reader, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range points {
+		x, err := CurvePoint{}.RandomN(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		points[i] = newBN256Point(new(bn256.G1).ScalarBaseMult(x))
+	}
+	return points, scalars
+}
+
+func TestMultiScalarMult(t *testing.T) {
+	N := CurvePoint{}.Order()
+
+	for _, n := range []int{1, 2, 5, pippengerThreshold - 1, pippengerThreshold, 100} {
+		points, scalars := randomPointsAndScalars(t, n)
+
+		// Scalars of zero, one and above the group order are handled
+		scalars[0] = big.NewInt(0)
+		if n > 1 {
+			scalars[1] = big.NewInt(1)
+		}
+		if n > 2 {
+			scalars[2] = new(big.Int).Add(N, big.NewInt(7))
+		}
+
+		expected := naiveMultiScalarMult(points, scalars)
+		actual := MultiScalarMult(BN256, points, scalars)
+		if !pointsEqual(expected, actual) {
+			t.Errorf("Wrong result for %v points", n)
+		}
+	}
+
+	for _, g := range Curves {
+		if sum := MultiScalarMult(g, nil, nil); !sum.IsInfinity() || sum.Group() != g {
+			t.Errorf("Empty sum should be the point at infinity of %v", g.Name())
+		}
+	}
+}
+
+func TestFixedBaseTable(t *testing.T) {
+	N := CurvePoint{}.Order()
+
+	x, err := CurvePoint{}.RandomN(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(16), x, new(big.Int).Sub(N, bigOne), N} {
+		expected := newBN256Point(new(bn256.G1).ScalarBaseMult(k))
+		actual := CurvePoint{}.ScalarBaseMult(k)
+		if !pointsEqual(expected, actual) {
+			t.Errorf("Wrong result for %v", k)
+		}
+	}
+
+	// Tables for other points give the same result as ScalarMult
+	p := CurvePoint{}.ScalarBaseMult(x)
+	table := newFixedBaseTable(p)
+	expected := p.ScalarMult(x)
+	actual := table.mul(x)
+	if !pointsEqual(expected, actual) {
+		t.Errorf("Wrong result from table for %v", p)
+	}
+}
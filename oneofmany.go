@@ -0,0 +1,403 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// SchemeGK is the logarithmic size one-out-of-many proof based scheme
+const SchemeGK = "gk"
+
+// gkLabel domain separates the one-out-of-many proof challenge
+var gkLabel = []byte("orbital-gk-v1")
+
+// generatorH is a second generator whose discrete log relative to the
+// standard generator is unknown, as it is derived using hash-to-curve
+var generatorH = NewCurvePointFromString([]byte("orbital-generator-h"))
+
+// A LogRingSignature is a linkable ring signature whose size grows with
+// the logarithm of the ring size. It is a one-out-of-many proof, from
+// Groth & Kohlweiss (IACR 2014/764), that one of the public keys is a
+// commitment to zero, extended to prove that Tau uses the same secret key:
+//
+//   tau = H(m)^x, where y_l = g^x for some hidden l
+//
+// For a ring padded to 2^k members the signature has 5k points and 3k+1
+// scalars, plus Tau.
+//
+type LogRingSignature struct {
+	Tau CurvePoint   `json:"tau"`
+	CL  []CurvePoint `json:"cl"`
+	CA  []CurvePoint `json:"ca"`
+	CB  []CurvePoint `json:"cb"`
+	CD  []CurvePoint `json:"cd"`
+	CE  []CurvePoint `json:"ce"`
+	F   []*big.Int   `json:"f"`
+	ZA  []*big.Int   `json:"za"`
+	ZB  []*big.Int   `json:"zb"`
+	ZD  *big.Int     `json:"zd"`
+}
+
+type logRingSignatureJSON struct {
+	Scheme string       `json:"scheme"`
+	Tau    CurvePoint   `json:"tau"`
+	CL     []CurvePoint `json:"cl"`
+	CA     []CurvePoint `json:"ca"`
+	CB     []CurvePoint `json:"cb"`
+	CD     []CurvePoint `json:"cd"`
+	CE     []CurvePoint `json:"ce"`
+	F      []*hexBig    `json:"f"`
+	ZA     []*hexBig    `json:"za"`
+	ZB     []*hexBig    `json:"zb"`
+	ZD     *hexBig      `json:"zd"`
+}
+
+func toHexBigs(values []*big.Int) []*hexBig {
+	out := make([]*hexBig, len(values))
+	for i, v := range values {
+		out[i] = (*hexBig)(v)
+	}
+	return out
+}
+
+func fromHexBigs(values []*hexBig) ([]*big.Int, error) {
+	out := make([]*big.Int, len(values))
+	for i, v := range values {
+		if v == nil {
+			return nil, errors.New("Invalid signature, null scalar")
+		}
+		out[i] = (*big.Int)(v)
+	}
+	return out, nil
+}
+
+// MarshalJSON converts a LogRingSignature to a JSON representation
+func (sig *LogRingSignature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&logRingSignatureJSON{
+		Scheme: SchemeGK,
+		Tau:    sig.Tau,
+		CL:     sig.CL,
+		CA:     sig.CA,
+		CB:     sig.CB,
+		CD:     sig.CD,
+		CE:     sig.CE,
+		F:      toHexBigs(sig.F),
+		ZA:     toHexBigs(sig.ZA),
+		ZB:     toHexBigs(sig.ZB),
+		ZD:     (*hexBig)(sig.ZD),
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a LogRingSignature struct
+func (sig *LogRingSignature) UnmarshalJSON(data []byte) error {
+	var aux logRingSignatureJSON
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.Scheme != SchemeGK {
+		return fmt.Errorf("Unexpected signature scheme: %v", aux.Scheme)
+	}
+
+	if aux.ZD == nil {
+		return errors.New("Invalid signature, no zd specified")
+	}
+
+	var f, za, zb []*big.Int
+	if f, err = fromHexBigs(aux.F); err != nil {
+		return err
+	}
+	if za, err = fromHexBigs(aux.ZA); err != nil {
+		return err
+	}
+	if zb, err = fromHexBigs(aux.ZB); err != nil {
+		return err
+	}
+
+	*sig = LogRingSignature{aux.Tau, aux.CL, aux.CA, aux.CB, aux.CD, aux.CE, f, za, zb, (*big.Int)(aux.ZD)}
+	return nil
+}
+
+// paddedPubKeys returns the public keys padded to a power of two, of at
+// least 2, by repeating the last key, and the number of bits in an index
+func (r *Ring) paddedPubKeys() ([]CurvePoint, int) {
+	bits := 1
+	for 1<<uint(bits) < len(r.PubKeys) {
+		bits++
+	}
+
+	keys := append([]CurvePoint{}, r.PubKeys...)
+	for len(keys) < 1<<uint(bits) {
+		keys = append(keys, r.PubKeys[len(r.PubKeys)-1])
+	}
+	return keys, bits
+}
+
+// pedersenCommit returns h^m · g^r
+func pedersenCommit(m *big.Int, r *big.Int) CurvePoint {
+	return generatorH.ScalarMult(m).Add(CurvePoint{}.ScalarBaseMult(r))
+}
+
+// pointsEqual compares two points, either of which may be the point at infinity
+func pointsEqual(a CurvePoint, b CurvePoint) bool {
+	if a.IsInfinity() || b.IsInfinity() {
+		return a.IsInfinity() && b.IsInfinity()
+	}
+	return a.Equals(&b)
+}
+
+// polyMul multiplies a polynomial, as coefficients of increasing degree,
+// by the linear polynomial c0 + c1·x modulo the group order
+func polyMul(p []*big.Int, c0 *big.Int, c1 *big.Int) []*big.Int {
+	N := CurvePoint{}.Order()
+	out := make([]*big.Int, len(p)+1)
+	for k := range out {
+		out[k] = new(big.Int)
+	}
+	for k, v := range p {
+		out[k].Add(out[k], new(big.Int).Mul(v, c0))
+		out[k+1].Add(out[k+1], new(big.Int).Mul(v, c1))
+	}
+	for _, v := range out {
+		v.Mod(v, N)
+	}
+	return out
+}
+
+// gkChallenge computes the Fiat-Shamir challenge over the statement and
+// every commitment in the signature
+func gkChallenge(keys []CurvePoint, hashp *CurvePoint, sig *LogRingSignature) *big.Int {
+	var parts [][]byte
+	for _, key := range keys {
+		parts = append(parts, key.Marshal())
+	}
+	parts = append(parts, hashp.Marshal(), sig.Tau.Marshal())
+	for _, points := range [][]CurvePoint{sig.CL, sig.CA, sig.CB, sig.CD, sig.CE} {
+		for _, p := range points {
+			parts = append(parts, p.Marshal())
+		}
+	}
+	return hashToScalar(gkLabel, parts...)
+}
+
+// randomScalars returns n uniformly random scalars
+func randomScalars(random io.Reader, n int) ([]*big.Int, error) {
+	out := make([]*big.Int, n)
+	for i := range out {
+		var err error
+		out[i], err = CurvePoint{}.RandomN(random)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// LogSignature generates a logarithmic size linkable ring signature, the
+// signer index is committed to bit by bit:
+//
+//   CL_j ← Com(l_j; r_j),  CA_j ← Com(a_j; s_j),  CB_j ← Com(l_j·a_j; t_j)
+//   CD_k ← Π_i y_i^p_ik · g^ρ_k,  CE_k ← H(m)^ρ_k
+//
+// where p_i(x) = Π_j f_j,i_j(x) = δ_il·x^k + Σ p_ik·x^k, with f_j,1 = l_j·x + a_j
+// and f_j,0 = x - f_j,1. After the challenge x the responses are:
+//
+//   f_j ← l_j·x + a_j,  za_j ← r_j·x + s_j,  zb_j ← r_j·(x - f_j) + t_j
+//   zd ← sk·x^k - Σ ρ_k·x^k
+//
+func (r *Ring) LogSignature(random io.Reader, pk Scalar, message []byte, signer int) (*LogRingSignature, error) {
+	N := CurvePoint{}.Order()
+	if signer < 0 || signer >= len(r.PubKeys) {
+		return nil, fmt.Errorf("Signer index out of range: %v", signer)
+	}
+	if !onBN256(*r) {
+		return nil, errNotBN256(SchemeGK)
+	}
+
+	keys, m := r.paddedPubKeys()
+	x := pk.Int()
+	hashp := messagePoint(message)
+	sig := &LogRingSignature{Tau: hashp.ScalarMult(x)}
+
+	rs, err := randomScalars(random, m)
+	if err != nil {
+		return nil, err
+	}
+	as, err := randomScalars(random, m)
+	if err != nil {
+		return nil, err
+	}
+	ss, err := randomScalars(random, m)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := randomScalars(random, m)
+	if err != nil {
+		return nil, err
+	}
+	rhos, err := randomScalars(random, m)
+	if err != nil {
+		return nil, err
+	}
+
+	bits := make([]*big.Int, m)
+	for j := 0; j < m; j++ {
+		bits[j] = big.NewInt(int64((signer >> uint(j)) & 1))
+		la := new(big.Int).Mul(bits[j], as[j])
+		sig.CL = append(sig.CL, pedersenCommit(bits[j], rs[j]))
+		sig.CA = append(sig.CA, pedersenCommit(as[j], ss[j]))
+		sig.CB = append(sig.CB, pedersenCommit(la.Mod(la, N), ts[j]))
+	}
+
+	// CD_k ← Π_i y_i^p_ik · g^ρ_k
+	coefficients := make([][]*big.Int, m)
+	for i := range keys {
+		p := []*big.Int{big.NewInt(1)}
+		for j := 0; j < m; j++ {
+			negA := new(big.Int).Sub(N, as[j])
+			if (i>>uint(j))&1 == 1 {
+				p = polyMul(p, as[j], bits[j])
+			} else {
+				p = polyMul(p, negA, new(big.Int).Sub(bigOne, bits[j]))
+			}
+		}
+		for k := 0; k < m; k++ {
+			coefficients[k] = append(coefficients[k], p[k])
+		}
+	}
+	for k := 0; k < m; k++ {
+		cd := MultiScalarMult(BN256, keys, coefficients[k])
+		sig.CD = append(sig.CD, cd.Add(CurvePoint{}.ScalarBaseMult(rhos[k])))
+	}
+
+	for k := 0; k < m; k++ {
+		sig.CE = append(sig.CE, hashp.ScalarMult(rhos[k]))
+	}
+
+	challenge := gkChallenge(keys, hashp, sig)
+
+	for j := 0; j < m; j++ {
+		f := new(big.Int).Mul(bits[j], challenge)
+		f.Add(f, as[j]).Mod(f, N)
+
+		za := new(big.Int).Mul(rs[j], challenge)
+		za.Add(za, ss[j]).Mod(za, N)
+
+		zb := new(big.Int).Sub(challenge, f)
+		zb.Mul(zb, rs[j]).Add(zb, ts[j]).Mod(zb, N)
+
+		sig.F = append(sig.F, f)
+		sig.ZA = append(sig.ZA, za)
+		sig.ZB = append(sig.ZB, zb)
+	}
+
+	// zd ← sk·x^m - Σ ρ_k·x^k
+	xk := big.NewInt(1)
+	zd := new(big.Int)
+	for k := 0; k < m; k++ {
+		zd.Sub(zd, new(big.Int).Mul(rhos[k], xk))
+		xk.Mul(xk, challenge).Mod(xk, N)
+	}
+	zd.Add(zd, new(big.Int).Mul(x, xk))
+	sig.ZD = zd.Mod(zd, N)
+
+	return sig, nil
+}
+
+// LogSignatures generates a logarithmic size signature for every private key in the ring
+func (r *Ring) LogSignatures(random io.Reader, message []byte) ([]LogRingSignature, error) {
+	var signaturesArr []LogRingSignature
+
+	for i, privKey := range r.PrivKeys {
+		signature, err := r.LogSignature(random, privKey, message, i)
+		if err != nil {
+			return nil, err
+		}
+		signaturesArr = append(signaturesArr, *signature)
+	}
+
+	return signaturesArr, nil
+}
+
+// VerifyLogSignature verifies a logarithmic size ring signature, checking:
+//
+//   CL_j^x · CA_j = Com(f_j; za_j)
+//   CL_j^(x-f_j) · CB_j = Com(0; zb_j)
+//   Π_i y_i^(Π_j f_j,i_j) = g^zd · Π_k CD_k^(x^k)
+//   tau^(x^m) = H(m)^zd · Π_k CE_k^(x^k)
+//
+func (r *Ring) VerifyLogSignature(message []byte, sig LogRingSignature) bool {
+	N := CurvePoint{}.Order()
+	if len(r.PubKeys) == 0 || !onBN256(*r) {
+		return false
+	}
+	keys, m := r.paddedPubKeys()
+
+	for _, points := range [][]CurvePoint{sig.CL, sig.CA, sig.CB, sig.CD, sig.CE} {
+		if len(points) != m || !checkKeyImages(points, m) {
+			return false
+		}
+	}
+	for _, scalars := range [][]*big.Int{sig.F, sig.ZA, sig.ZB} {
+		if len(scalars) != m || !checkSignatureScalars(scalars...) {
+			return false
+		}
+	}
+	if !checkSignatureScalars(sig.ZD) || !checkKeyImages([]CurvePoint{sig.Tau}, 1) {
+		return false
+	}
+
+	hashp := messagePoint(message)
+	challenge := gkChallenge(keys, hashp, &sig)
+
+	// f_j,1 = f_j and f_j,0 = x - f_j
+	f0 := make([]*big.Int, m)
+	for j := 0; j < m; j++ {
+		f0[j] = new(big.Int).Sub(challenge, sig.F[j])
+		f0[j].Mod(f0[j], N)
+
+		lhs := sig.CL[j].ScalarMult(challenge).Add(sig.CA[j])
+		if !pointsEqual(lhs, pedersenCommit(sig.F[j], sig.ZA[j])) {
+			return false
+		}
+
+		lhs = sig.CL[j].ScalarMult(f0[j]).Add(sig.CB[j])
+		if !pointsEqual(lhs, CurvePoint{}.ScalarBaseMult(sig.ZB[j])) {
+			return false
+		}
+	}
+
+	ts := make([]*big.Int, len(keys))
+	for i := range keys {
+		t := big.NewInt(1)
+		for j := 0; j < m; j++ {
+			if (i>>uint(j))&1 == 1 {
+				t.Mul(t, sig.F[j])
+			} else {
+				t.Mul(t, f0[j])
+			}
+			t.Mod(t, N)
+		}
+		ts[i] = t
+	}
+	ring := MultiScalarMult(BN256, keys, ts)
+
+	xks := make([]*big.Int, m)
+	xk := big.NewInt(1)
+	for k := 0; k < m; k++ {
+		xks[k] = new(big.Int).Set(xk)
+		xk.Mul(xk, challenge).Mod(xk, N)
+	}
+	ringRHS := MultiScalarMult(BN256, sig.CD, xks).Add(CurvePoint{}.ScalarBaseMult(sig.ZD))
+	tagRHS := MultiScalarMult(BN256, append([]CurvePoint{*hashp}, sig.CE...), append([]*big.Int{sig.ZD}, xks...))
+
+	return pointsEqual(ring, ringRHS) && pointsEqual(sig.Tau.ScalarMult(xk), tagRHS)
+}
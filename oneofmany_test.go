@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestLogSignature(t *testing.T) {
+	message := []byte("foobarbaz")
+
+	for _, n := range []int{1, 2, 3, 4, 5, 8} {
+		r := generateRing(n)
+		for i := 0; i < n; i++ {
+			sig, err := r.LogSignature(rand.Reader, r.PrivKeys[i], message, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !r.VerifyLogSignature(message, *sig) {
+				t.Errorf("Signature by %v not verified for ring of %v", i, n)
+			}
+		}
+	}
+}
+
+func TestLogSignatureSize(t *testing.T) {
+	for _, size := range [][2]int{{1, 1}, {2, 1}, {3, 2}, {4, 2}, {5, 3}, {16, 4}} {
+		r := Ring{PubKeys: make([]CurvePoint, size[0])}
+		_, bits := r.paddedPubKeys()
+		if bits != size[1] {
+			t.Errorf("Expected %v bits for ring of %v but got %v", size[1], size[0], bits)
+		}
+	}
+}
+
+func TestLogSignatureTau(t *testing.T) {
+	r := generateRing(3)
+	message := []byte("foobarbaz")
+
+	// Tau is the same as the original scheme, so double spends are
+	// detected across both schemes
+	sig, err := r.Signature(rand.Reader, r.PrivKeys[2], message, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logSig, err := r.LogSignature(rand.Reader, r.PrivKeys[2], message, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sig.Tau.Equals(&logSig.Tau) {
+		t.Fatal("Key images differ between schemes")
+	}
+}
+
+func TestVerifyLogSignatureBad(t *testing.T) {
+	r := generateRing(4)
+	message := []byte("foobarbaz")
+
+	sig, err := r.LogSignature(rand.Reader, r.PrivKeys[1], message, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.VerifyLogSignature([]byte("badmessage"), *sig) {
+		t.Fatal("Signature verified for wrong message")
+	}
+
+	other := generateRing(4)
+	if other.VerifyLogSignature(message, *sig) {
+		t.Fatal("Signature verified for wrong ring")
+	}
+
+	// Tau of another key
+	_, priv, _ := generateKeyPair(rand.Reader)
+	bad := *sig
+	bad.Tau = messagePoint(message).ScalarMult(priv)
+	if r.VerifyLogSignature(message, bad) {
+		t.Fatal("Signature verified with substituted tau")
+	}
+
+	bad = *sig
+	bad.ZD = new(big.Int).Add(sig.ZD, bigOne)
+	if r.VerifyLogSignature(message, bad) {
+		t.Fatal("Altered signature verified")
+	}
+
+	bad = *sig
+	bad.F = sig.F[1:]
+	if r.VerifyLogSignature(message, bad) {
+		t.Fatal("Truncated signature verified")
+	}
+
+	forged, err := r.LogSignature(rand.Reader, NewScalar(BN256, priv), message, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.VerifyLogSignature(message, *forged) {
+		t.Fatal("Signature by non-member verified")
+	}
+}
+
+func TestLogSignatureJSON(t *testing.T) {
+	r := generateRing(3)
+	message := []byte("foobarbaz")
+
+	sig, err := r.LogSignature(rand.Reader, r.PrivKeys[0], message, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded LogRingSignature
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !r.VerifyLogSignature(message, decoded) {
+		t.Fatal("Signature not verified after JSON round trip")
+	}
+}
+
+// benchmarkRingSizes are the ring sizes the signature schemes are compared at
+var benchmarkRingSizes = []int{4, 16, 64, 256, 1024}
+
+// BenchmarkSchemeSign compares signing with the original and logarithmic
+// size schemes, logging the JSON encoded size of each signature
+func BenchmarkSchemeSign(b *testing.B) {
+	message := []byte("foobarbaz")
+
+	for _, n := range benchmarkRingSizes {
+		r := generateRing(n)
+
+		b.Run(fmt.Sprintf("ctlist/%v", n), func(b *testing.B) {
+			var sig *RingSignature
+			var err error
+			for i := 0; i < b.N; i++ {
+				sig, err = r.Signature(rand.Reader, r.PrivKeys[0], message, 0)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			data, _ := json.Marshal(sig)
+			b.Logf("%v bytes/sig", len(data))
+		})
+
+		b.Run(fmt.Sprintf("gk/%v", n), func(b *testing.B) {
+			var sig *LogRingSignature
+			var err error
+			for i := 0; i < b.N; i++ {
+				sig, err = r.LogSignature(rand.Reader, r.PrivKeys[0], message, 0)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			data, _ := json.Marshal(sig)
+			b.Logf("%v bytes/sig", len(data))
+		})
+	}
+}
+
+// BenchmarkSchemeVerify compares verification with the original and
+// logarithmic size schemes
+func BenchmarkSchemeVerify(b *testing.B) {
+	message := []byte("foobarbaz")
+
+	for _, n := range benchmarkRingSizes {
+		r := generateRing(n)
+
+		sig, err := r.Signature(rand.Reader, r.PrivKeys[0], message, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("ctlist/%v", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if !r.VerifySignature(message, *sig) {
+					b.Fatal("Signature not verified")
+				}
+			}
+		})
+
+		logSig, err := r.LogSignature(rand.Reader, r.PrivKeys[0], message, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("gk/%v", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if !r.VerifyLogSignature(message, *logSig) {
+					b.Fatal("Signature not verified")
+				}
+			}
+		})
+	}
+}
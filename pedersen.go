@@ -0,0 +1,81 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+)
+
+// A PedersenOpening is the value and blinding factor of a Pedersen
+// commitment on BN256:
+//
+//   C = h^v · g^r
+//
+// where h is generatorH, whose discrete log to g is unknown as it is hashed
+// onto the curve. The commitment hides the value, and can't be opened to
+// another one without knowing that discrete log. Commitments can be added,
+// the sum is a commitment to the sum of the values and blinding factors.
+//
+type PedersenOpening struct {
+	Value    Scalar `json:"value"`
+	Blinding Scalar `json:"blinding"`
+}
+
+// PedersenCommit returns the commitment h^v · g^r
+func PedersenCommit(value Scalar, blinding Scalar) CurvePoint {
+	return pedersenCommit(value.value(), blinding.value())
+}
+
+// NewPedersenCommitment commits to a value with a random blinding factor
+func NewPedersenCommitment(random io.Reader, value *big.Int) (CurvePoint, *PedersenOpening, error) {
+	blinding, err := RandomScalar(BN256, random)
+	if err != nil {
+		return CurvePoint{}, nil, err
+	}
+
+	opening := &PedersenOpening{NewScalar(BN256, value), blinding}
+	return opening.Commitment(), opening, nil
+}
+
+// Commitment returns the commitment the opening is of
+func (o *PedersenOpening) Commitment() CurvePoint {
+	return PedersenCommit(o.Value, o.Blinding)
+}
+
+// Verify returns true if the opening is of the commitment c
+func (o *PedersenOpening) Verify(c *CurvePoint) bool {
+	if c == nil || c.Group() != BN256 {
+		return false
+	}
+	commitment := o.Commitment()
+	return pointsEqual(commitment, *c)
+}
+
+// UnmarshalJSON converts a JSON representation to a PedersenOpening struct
+func (o *PedersenOpening) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Value    *hexBig `json:"value"`
+		Blinding *hexBig `json:"blinding"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	value, err := ParseScalar(BN256, (*big.Int)(aux.Value))
+	if err != nil {
+		return err
+	}
+	blinding, err := ParseScalar(BN256, (*big.Int)(aux.Blinding))
+	if err != nil {
+		return err
+	}
+
+	o.Value = value
+	o.Blinding = blinding
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// popLabel domain separates proofs of possession from Schnorr signatures of
+// messages
+var popLabel = []byte("orbital-pop")
+
+// ProvePossession proves knowledge of the secret key x of a public key with
+// a Schnorr signature under its own label, whose challenge commits to the
+// public key:
+//
+//   k ← random
+//   c ← H(g^k, g^x)
+//   s ← k - c·x
+//
+// A ring member who attaches one to their public key can't have derived it
+// from the keys of others, as in a rogue key attack.
+//
+func ProvePossession(random io.Reader, x Scalar) (*SchnorrSignature, error) {
+	return schnorrSign(random, x, popLabel, nil)
+}
+
+// VerifyPossession checks a proof of possession of the secret key of pub
+func VerifyPossession(pub *CurvePoint, proof *SchnorrSignature) bool {
+	return schnorrVerify(pub, popLabel, nil, proof)
+}
+
+// ProvePossession makes a proof of possession for every member of the ring
+// whose private key is known, replacing any previous proofs
+func (r *Ring) ProvePossession(random io.Reader) error {
+	proofs := make([]*SchnorrSignature, len(r.PubKeys))
+	for i := range proofs {
+		if i >= len(r.PrivKeys) || r.PrivKeys[i].IsZero() {
+			continue
+		}
+
+		proof, err := ProvePossession(random, r.PrivKeys[i])
+		if err != nil {
+			return err
+		}
+		proofs[i] = proof
+	}
+
+	r.Proofs = proofs
+	return nil
+}
+
+// VerifyPossession checks every member of the ring has a valid proof of
+// possession
+func (r *Ring) VerifyPossession() error {
+	return r.verifyPossession(false)
+}
+
+// VerifyPossessionAllowMissing checks the proofs of possession of the ring
+// members which have one, for rings made before proofs were introduced. It
+// doesn't protect against rogue keys, so VerifyPossession should be used
+// wherever the members can be asked for their proofs.
+//
+func (r *Ring) VerifyPossessionAllowMissing() error {
+	return r.verifyPossession(true)
+}
+
+// verifyPossession checks the proofs of possession of the ring members. An
+// invalid proof is always an error, and a missing one is unless allowed.
+func (r *Ring) verifyPossession(allowMissing bool) error {
+	if len(r.Proofs) != 0 && len(r.Proofs) != len(r.PubKeys) {
+		return fmt.Errorf("Ring has %v proofs of possession for %v members", len(r.Proofs), len(r.PubKeys))
+	}
+
+	for i := range r.PubKeys {
+		var proof *SchnorrSignature
+		if i < len(r.Proofs) {
+			proof = r.Proofs[i]
+		}
+
+		if proof == nil {
+			if false == allowMissing {
+				return fmt.Errorf("No proof of possession for ring member %v", i)
+			}
+			continue
+		}
+		if false == VerifyPossession(&r.PubKeys[i], proof) {
+			return fmt.Errorf("Invalid proof of possession for ring member %v", i)
+		}
+	}
+	return nil
+}
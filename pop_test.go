@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+)
+
+func TestProofOfPossession(t *testing.T) {
+	for _, g := range Curves {
+		pub, priv, err := newKeyPair(g, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		proof, err := ProvePossession(rand.Reader, NewScalar(g, priv))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyPossession(pub, proof) {
+			t.Fatalf("%v: proof of possession not verified", g.Name())
+		}
+
+		other, _, err := newKeyPair(g, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if VerifyPossession(other, proof) {
+			t.Fatalf("%v: proof verified for another key", g.Name())
+		}
+
+		// A rogue key is the difference of a key the attacker knows and
+		// another member's key, its secret key isn't known
+		rogue := pub.Sub(*other)
+		if VerifyPossession(&rogue, proof) {
+			t.Fatalf("%v: proof verified for a rogue key", g.Name())
+		}
+	}
+
+	// A Schnorr signature is not a proof of possession
+	pub, priv, err := generateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := SchnorrSign(rand.Reader, priv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VerifyPossession(pub, sig) {
+		t.Fatal("Schnorr signature accepted as a proof of possession")
+	}
+	if _, err := ProvePossession(rand.Reader, Scalar{}); err == nil {
+		t.Fatal("Proved possession of a zero key")
+	}
+}
+
+func TestRingPossession(t *testing.T) {
+	r := generateRing(3)
+	if err := r.VerifyPossessionAllowMissing(); err != nil {
+		t.Fatal("A ring without proofs should only be rejected when they are required")
+	}
+	if err := r.VerifyPossession(); err == nil {
+		t.Fatal("Accepted a ring without proofs when they are required")
+	}
+
+	if err := r.ProvePossession(rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.VerifyPossession(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Proofs are kept with the ring
+	data, err := json.Marshal(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Ring
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.VerifyPossession(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Swapped proofs are invalid even when they aren't required
+	loaded.Proofs[0], loaded.Proofs[1] = loaded.Proofs[1], loaded.Proofs[0]
+	if err := loaded.VerifyPossessionAllowMissing(); err == nil {
+		t.Fatal("Accepted a ring with invalid proofs")
+	}
+
+	// A missing proof
+	loaded.Proofs[0], loaded.Proofs[1] = loaded.Proofs[1], nil
+	if err := loaded.VerifyPossessionAllowMissing(); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.VerifyPossession(); err == nil {
+		t.Fatal("Accepted a ring with a missing proof")
+	}
+
+	loaded.Proofs = loaded.Proofs[:2]
+	if err := loaded.VerifyPossessionAllowMissing(); err == nil {
+		t.Fatal("Accepted a ring with fewer proofs than members")
+	}
+}
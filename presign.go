@@ -0,0 +1,265 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// A Presignature is the part of a ring signature which doesn't depend on
+// the message, computed ahead of time so signing is quick once the message
+// is known. It holds the random scalars and the commitments against the
+// generator, which are half of the work of signing:
+//
+//   a_j ← g^t_j · P_j^c_j   for j ≠ signer
+//   a_signer ← g^r
+//
+// A presignature is as secret as the private key and must be used for
+// exactly one signature, finishing two messages with it reveals the key.
+//
+type Presignature struct {
+	RingHash [sha256.Size]byte
+	Signer   int
+	R        *big.Int
+	Ctlist   []*big.Int
+	A        []CurvePoint
+
+	used bool
+}
+
+// MarshalJSON converts a Presignature to a JSON representation
+func (p *Presignature) MarshalJSON() ([]byte, error) {
+	if p.used {
+		return nil, errors.New("Presignature has already been used")
+	}
+
+	ctlist := make([]*hexBig, len(p.Ctlist))
+	for i, v := range p.Ctlist {
+		ctlist[i] = (*hexBig)(v)
+	}
+
+	return json.Marshal(&struct {
+		Ring   string       `json:"ring"`
+		Signer int          `json:"signer"`
+		R      *hexBig      `json:"r"`
+		Ctlist []*hexBig    `json:"ctlist"`
+		A      []CurvePoint `json:"a"`
+	}{
+		Ring:   hex.EncodeToString(p.RingHash[:]),
+		Signer: p.Signer,
+		R:      (*hexBig)(p.R),
+		Ctlist: ctlist,
+		A:      p.A,
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a Presignature struct
+func (p *Presignature) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Ring   string       `json:"ring"`
+		Signer int          `json:"signer"`
+		R      *hexBig      `json:"r"`
+		Ctlist []*hexBig    `json:"ctlist"`
+		A      []CurvePoint `json:"a"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	ringHash, err := hex.DecodeString(aux.Ring)
+	if err != nil || len(ringHash) != sha256.Size {
+		return errors.New("Invalid presignature, bad ring hash")
+	}
+	if aux.R == nil {
+		return errors.New("Invalid presignature, no r specified")
+	}
+
+	// The curve isn't recorded, it is that of the commitments. A zero r
+	// would make the signer's response -c·x, revealing the key.
+	var g Group = BN256
+	if len(aux.A) > 0 {
+		g = aux.A[0].Group()
+	}
+	r, err := ParseScalar(g, (*big.Int)(aux.R))
+	if err != nil {
+		return fmt.Errorf("Invalid presignature: %v", err)
+	}
+	if r.IsZero() {
+		return errors.New("Invalid presignature, r is zero")
+	}
+
+	ctlist := make([]*big.Int, len(aux.Ctlist))
+	for i, v := range aux.Ctlist {
+		if v == nil {
+			return errors.New("Invalid presignature, null scalar")
+		}
+		if _, err := ParseScalar(g, (*big.Int)(v)); err != nil {
+			return fmt.Errorf("Invalid presignature: %v", err)
+		}
+		ctlist[i] = (*big.Int)(v)
+	}
+
+	copy(p.RingHash[:], ringHash)
+	p.Signer = aux.Signer
+	p.R = r.Int()
+	p.Ctlist = ctlist
+	p.A = aux.A
+	return nil
+}
+
+// Presign precomputes the message independent part of a signature by the
+// member of the ring at index signer. The scalars are drawn in the same
+// order as Signature, so with the same randomness the finished signature
+// is the same.
+func (r *Ring) Presign(random io.Reader, signer int) (*Presignature, error) {
+	n := len(r.PubKeys)
+	if signer < 0 || signer >= n {
+		return nil, errors.New("Signer is not a member of the ring")
+	}
+	g := r.group()
+
+	pre := &Presignature{
+		RingHash: r.PublicKeysHashed(),
+		Signer:   signer,
+		Ctlist:   make([]*big.Int, 2*n),
+		A:        make([]CurvePoint, n),
+	}
+
+	for j := 0; j < n; j++ {
+		if j == signer {
+			ri, err := randomScalar(g, random)
+			if err != nil {
+				return nil, err
+			}
+			pre.R = ri
+			pre.Ctlist[2*j] = big.NewInt(0)
+			pre.Ctlist[2*j+1] = big.NewInt(0)
+			pre.A[j] = g.ScalarBaseMult(ri)
+			continue
+		}
+
+		cj, err := randomScalar(g, random)
+		if err != nil {
+			return nil, err
+		}
+		tj, err := randomScalar(g, random)
+		if err != nil {
+			return nil, err
+		}
+		pre.Ctlist[2*j] = cj
+		pre.Ctlist[2*j+1] = tj
+		pre.A[j] = r.PubKeys[j].ParameterPointAdd(tj, cj)
+	}
+
+	return pre, nil
+}
+
+// FinishPresigned completes a presigned signature of message, see
+// SigningContext.FinishPresigned
+func (r *Ring) FinishPresigned(pre *Presignature, pk Scalar, message []byte) (*RingSignature, error) {
+	return NewSigningContext(r, message).FinishPresigned(pre, pk)
+}
+
+// FinishPresigned completes a presigned signature. As τ = H(m)^x, the
+// commitments against the message point each need a single multiplication:
+//
+//   b_j ← H(m)^(t_j + x·c_j)   for j ≠ signer
+//   b_signer ← H(m)^r
+//
+// The presignature is used up whether or not signing succeeds, its scalars
+// are cleared and it can't be finished or saved again.
+//
+func (ctx *SigningContext) FinishPresigned(pre *Presignature, pk Scalar) (*RingSignature, error) {
+	N := ctx.group.Order()
+
+	if pre.used {
+		return nil, errors.New("Presignature has already been used")
+	}
+	pre.used = true
+	ri, ctlist, commitments := pre.R, pre.Ctlist, pre.A
+	pre.R, pre.Ctlist, pre.A = nil, nil, nil
+
+	n := len(ctx.Ring.PubKeys)
+	if false == bytes.Equal(pre.RingHash[:], ctx.ringHash[:]) {
+		return nil, errors.New("Presignature is for a different ring")
+	}
+	if pre.Signer < 0 || pre.Signer >= n || len(ctlist) != 2*n || len(commitments) != n {
+		return nil, fmt.Errorf("Presignature does not match a ring of %v members", n)
+	}
+	for _, a := range commitments {
+		if a.p == nil || a.Group() != ctx.group {
+			return nil, fmt.Errorf("Presignature is not on the curve of the ring: %v", ctx.group.Name())
+		}
+	}
+	if false == isValidScalar(ctx.group, ri) {
+		return nil, errors.New("Presignature has an invalid r")
+	}
+	for _, v := range ctlist {
+		if v == nil || v.Sign() < 0 || v.Cmp(N) >= 0 {
+			return nil, errors.New("Presignature has a scalar out of range")
+		}
+	}
+
+	x, err := ctx.privateKey(pk)
+	if err != nil {
+		return nil, err
+	}
+	public := ctx.group.ScalarBaseMult(x)
+	if false == public.Equals(&ctx.Ring.PubKeys[pre.Signer]) {
+		return nil, errors.New("Private key is not the signer's")
+	}
+
+	// Every commitment is a multiple of H(m), so for large rings its table
+	// is worth building
+	hashpMul := ctx.hashpMul
+	if n >= precomputeThreshold && ctx.hashpTable == nil {
+		hashpMul = newFixedBaseTable(*ctx.hashp).mul
+	}
+	tau := hashpMul(x)
+
+	hasher := newRingHasherX(ctx.hashpX, &tau)
+	defer hasher.release()
+
+	out := make([]*big.Int, 2*n)
+	csum := big.NewInt(0)
+	for j := 0; j < n; j++ {
+		var b CurvePoint
+		if j == pre.Signer {
+			b = hashpMul(ri)
+		} else {
+			cj, tj := ctlist[2*j], ctlist[2*j+1]
+			e := new(big.Int).Mul(x, cj)
+			e.Add(e, tj)
+			b = hashpMul(e.Mod(e, N))
+
+			out[2*j] = cj
+			out[2*j+1] = tj
+			csum.Add(csum, cj)
+		}
+
+		hasher.add(&commitments[j], &b)
+	}
+
+	csum.Mod(csum, N)
+	c := hasher.scalar()
+	c.Sub(c, csum)
+	c.Mod(c, N)
+
+	ti := new(big.Int).Mul(c, x)
+	ti.Sub(ri, ti)
+	ti.Mod(ti, N)
+	out[2*pre.Signer] = c
+	out[2*pre.Signer+1] = ti
+
+	return &RingSignature{tau, newScalars(ctx.group, out)}, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestPresignSignature(t *testing.T) {
+	r := generateRing(4)
+	message := []byte("foobarbaz")
+	signer := 2
+
+	pre, err := r.Presign(newHmacDRBG([]byte("presign")), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := r.FinishPresigned(pre, r.PrivKeys[signer], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.VerifySignature(message, *sig) {
+		t.Fatal("Presigned signature not verified")
+	}
+
+	// The same randomness gives the same signature as signing in one go
+	expected, err := r.Signature(newHmacDRBG([]byte("presign")), r.PrivKeys[signer], message, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if false == reflect.DeepEqual(signaturesHex([]RingSignature{*sig}), signaturesHex([]RingSignature{*expected})) {
+		t.Fatal("Presigned signature differs from Signature")
+	}
+}
+
+func TestPresignSingleUse(t *testing.T) {
+	r := generateRing(3)
+
+	pre, err := r.Presign(rand.Reader, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.FinishPresigned(pre, r.PrivKeys[0], []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.FinishPresigned(pre, r.PrivKeys[0], []byte("second"))
+	if err == nil {
+		t.Fatal("Should not finish a presignature twice")
+	}
+	if _, err := json.Marshal(pre); err == nil {
+		t.Fatal("Should not save a used presignature")
+	}
+
+	// A failed attempt uses the presignature up too
+	pre, err = r.Presign(rand.Reader, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.FinishPresigned(pre, r.PrivKeys[0], []byte("first")); err == nil {
+		t.Fatal("Should not finish with another member's key")
+	}
+	if _, err := r.FinishPresigned(pre, r.PrivKeys[1], []byte("first")); err == nil {
+		t.Fatal("Should not finish a presignature after a failed attempt")
+	}
+}
+
+func TestPresignJSON(t *testing.T) {
+	r := generateRing(3)
+	message := []byte("foobarbaz")
+
+	pre, err := r.Presign(rand.Reader, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(pre)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded Presignature
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := r.FinishPresigned(&loaded, r.PrivKeys[1], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.VerifySignature(message, *sig) {
+		t.Fatal("Signature from a loaded presignature not verified")
+	}
+
+	// A presignature only finishes for the ring it was made for
+	other := generateRing(3)
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.FinishPresigned(&loaded, other.PrivKeys[1], message); err == nil {
+		t.Fatal("Should not finish a presignature for a different ring")
+	}
+}
+
+func TestPresignInvalidScalars(t *testing.T) {
+	r := generateRing(3)
+	order := "0x" + CurvePoint{}.Order().Text(16)
+
+	pre, err := r.Presign(rand.Reader, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(pre)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A zero r reveals the key, and no scalar may be above the group order
+	for _, tamper := range []func(map[string]interface{}){
+		func(m map[string]interface{}) { m["r"] = "0x0" },
+		func(m map[string]interface{}) { m["r"] = order },
+		func(m map[string]interface{}) { m["ctlist"].([]interface{})[0] = order },
+	} {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatal(err)
+		}
+		tamper(fields)
+		tampered, err := json.Marshal(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var loaded Presignature
+		if err := json.Unmarshal(tampered, &loaded); err == nil {
+			t.Fatalf("Loaded an invalid presignature: %s", tampered)
+		}
+	}
+
+	// Nor is one made in code finished
+	pre.R = big.NewInt(0)
+	if _, err := r.FinishPresigned(pre, r.PrivKeys[1], []byte("foobarbaz")); err == nil {
+		t.Fatal("Should not finish a presignature with a zero r")
+	}
+}
+
+// BenchmarkPresign compares signing in one go with the time left to sign
+// once a presignature has been made, for a ring of 16 members
+func BenchmarkPresign(b *testing.B) {
+	r := generateRing(16)
+	message := []byte("foobarbaz")
+
+	b.Run("sign", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := r.Signature(rand.Reader, r.PrivKeys[0], message, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("presign", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := r.Presign(rand.Reader, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("finish", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			pre, err := r.Presign(rand.Reader, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.StartTimer()
+			if _, err := r.FinishPresigned(pre, r.PrivKeys[0], message); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,477 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+)
+
+// maxRangeBits is the largest range a proof can show a value is in, 2^64
+const maxRangeBits = 64
+
+// rangeProofLabel domain separates the challenges of range proofs
+var rangeProofLabel = []byte("orbital-range-proof-v1")
+
+// rangeProofBases are the vectors of generators of the range proofs and the
+// generator of the inner product, hashed onto the curve so no discrete log
+// between any of them is known. Hashing them takes a while, so it is only
+// done the first time a range proof is made or verified.
+//
+var rangeProofBases struct {
+	once sync.Once
+	g    []CurvePoint
+	h    []CurvePoint
+	u    CurvePoint
+}
+
+// rangeProofGenerators returns the first n of each vector of generators,
+// and the generator of the inner product
+func rangeProofGenerators(n int) ([]CurvePoint, []CurvePoint, CurvePoint) {
+	b := &rangeProofBases
+	b.once.Do(func() {
+		b.g = make([]CurvePoint, maxRangeBits)
+		b.h = make([]CurvePoint, maxRangeBits)
+		for i := range b.g {
+			b.g[i] = *NewCurvePointFromString([]byte(fmt.Sprintf("orbital-range-proof-g-%d", i)))
+			b.h[i] = *NewCurvePointFromString([]byte(fmt.Sprintf("orbital-range-proof-h-%d", i)))
+		}
+		b.u = *NewCurvePointFromString([]byte("orbital-range-proof-u"))
+	})
+	return b.g[:n], b.h[:n], b.u
+}
+
+// A RangeProof is a Bulletproofs range proof, from Bünz et al. (IACR
+// 2017/1066), that a Pedersen commitment is to a value between 0 and 2^n,
+// without revealing it. The value is split into n bits which are committed
+// to in A, and an inner product argument shows they are bits and add up to
+// the value. The proof has 2·log2(n) + 4 points and 5 scalars.
+//
+type RangeProof struct {
+	Bits       int
+	Commitment CurvePoint
+	A          CurvePoint
+	S          CurvePoint
+	T1         CurvePoint
+	T2         CurvePoint
+	TauX       Scalar
+	Mu         Scalar
+	T          Scalar
+	L          []CurvePoint
+	R          []CurvePoint
+	FinalA     Scalar
+	FinalB     Scalar
+}
+
+type rangeProofJSON struct {
+	Bits       int          `json:"bits"`
+	Commitment CurvePoint   `json:"commitment"`
+	A          CurvePoint   `json:"a"`
+	S          CurvePoint   `json:"s"`
+	T1         CurvePoint   `json:"t1"`
+	T2         CurvePoint   `json:"t2"`
+	TauX       *hexBig      `json:"taux"`
+	Mu         *hexBig      `json:"mu"`
+	T          *hexBig      `json:"t"`
+	L          []CurvePoint `json:"l"`
+	R          []CurvePoint `json:"r"`
+	FinalA     *hexBig      `json:"finalA"`
+	FinalB     *hexBig      `json:"finalB"`
+}
+
+// MarshalJSON converts a RangeProof to a JSON representation
+func (p *RangeProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&rangeProofJSON{
+		Bits:       p.Bits,
+		Commitment: p.Commitment,
+		A:          p.A,
+		S:          p.S,
+		T1:         p.T1,
+		T2:         p.T2,
+		TauX:       (*hexBig)(p.TauX.Int()),
+		Mu:         (*hexBig)(p.Mu.Int()),
+		T:          (*hexBig)(p.T.Int()),
+		L:          p.L,
+		R:          p.R,
+		FinalA:     (*hexBig)(p.FinalA.Int()),
+		FinalB:     (*hexBig)(p.FinalB.Int()),
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a RangeProof struct
+func (p *RangeProof) UnmarshalJSON(data []byte) error {
+	var aux rangeProofJSON
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	scalars, err := scalarsFromJSON(BN256, []*hexBig{aux.TauX, aux.Mu, aux.T, aux.FinalA, aux.FinalB})
+	if err != nil {
+		return fmt.Errorf("Invalid range proof: %v", err)
+	}
+
+	*p = RangeProof{
+		Bits:       aux.Bits,
+		Commitment: aux.Commitment,
+		A:          aux.A,
+		S:          aux.S,
+		T1:         aux.T1,
+		T2:         aux.T2,
+		TauX:       scalars[0],
+		Mu:         scalars[1],
+		T:          scalars[2],
+		L:          aux.L,
+		R:          aux.R,
+		FinalA:     scalars[3],
+		FinalB:     scalars[4],
+	}
+	return nil
+}
+
+// rangeProofRounds returns the number of rounds of the inner product
+// argument for a proof of n bits, which must be a power of two up to
+// maxRangeBits
+func rangeProofRounds(n int) (int, error) {
+	if n < 1 || n > maxRangeBits || n&(n-1) != 0 {
+		return 0, fmt.Errorf("Range proofs must be of a power of two bits up to %v, got %v", maxRangeBits, n)
+	}
+
+	rounds := 0
+	for 1<<uint(rounds) < n {
+		rounds++
+	}
+	return rounds, nil
+}
+
+// scalarPowers returns 1, x, x², ..., x^(n-1)
+func scalarPowers(x Scalar, n int) []Scalar {
+	out := make([]Scalar, n)
+	power := NewScalar(x.Group(), bigOne)
+	for i := range out {
+		out[i] = power
+		power = power.Mul(x)
+	}
+	return out
+}
+
+// innerProduct returns <a, b>, the sum of the products of the elements
+func innerProduct(a []Scalar, b []Scalar) Scalar {
+	var sum Scalar
+	for i := range a {
+		sum = sum.Add(a[i].Mul(b[i]))
+	}
+	return sum
+}
+
+// sumScalars returns the sum of the elements
+func sumScalars(v []Scalar) Scalar {
+	var sum Scalar
+	for _, s := range v {
+		sum = sum.Add(s)
+	}
+	return sum
+}
+
+// vectorCommit returns g^α · G^a · H^b for the vectors of generators G and H
+func vectorCommit(alpha Scalar, G []CurvePoint, a []Scalar, H []CurvePoint, b []Scalar) CurvePoint {
+	points := append([]CurvePoint{BN256.ScalarBaseMult(bigOne)}, G...)
+	points = append(points, H...)
+	scalars := append([]Scalar{alpha}, a...)
+	scalars = append(scalars, b...)
+	return MultiScalarMult(BN256, points, scalarInts(scalars))
+}
+
+// NewRangeProof proves that the commitment h^v · g^γ, of the value v with
+// the blinding factor γ, is to a value between 0 and 2^bits:
+//
+//   aL ← bits of v, aR ← aL - 1
+//   A ← g^α · G^aL · H^aR, S ← g^ρ · G^sL · H^sR    for random α, ρ, sL, sR
+//   y, z ← H(V, A, S)
+//   l(X) ← aL - z + sL·X
+//   r(X) ← y^n ∘ (aR + z + sR·X) + z²·2^n
+//   t(X) = <l(X), r(X)> = t0 + t1·X + t2·X²
+//   T1 ← h^t1 · g^τ1, T2 ← h^t2 · g^τ2
+//   x ← H(T1, T2)
+//   τx ← τ2·x² + τ1·x + z²·γ, μ ← α + ρ·x, t ← <l(x), r(x)>
+//
+// followed by an inner product argument that l(x) and r(x) are the
+// openings of A · S^x with the inner product t.
+//
+func NewRangeProof(random io.Reader, value *big.Int, blinding Scalar, bits int) (*RangeProof, error) {
+	if _, err := rangeProofRounds(bits); err != nil {
+		return nil, err
+	}
+	if value.Sign() < 0 || value.BitLen() > bits {
+		return nil, fmt.Errorf("Value is not between 0 and 2^%v", bits)
+	}
+	if blinding.Group() != BN256 {
+		return nil, errors.New("Range proofs are only supported on " + DefaultCurve)
+	}
+
+	n := bits
+	G, H, U := rangeProofGenerators(n)
+	one := NewScalar(BN256, bigOne)
+
+	aL := make([]Scalar, n)
+	aR := make([]Scalar, n)
+	for i := range aL {
+		aL[i] = NewScalar(BN256, big.NewInt(int64(value.Bit(i))))
+		aR[i] = aL[i].Sub(one)
+	}
+
+	// α, ρ, τ1, τ2 and the blinding vectors sL, sR
+	values, err := randomScalars(random, 4+2*n)
+	if err != nil {
+		return nil, err
+	}
+	blindings := newScalars(BN256, values)
+	alpha, rho, tau1, tau2 := blindings[0], blindings[1], blindings[2], blindings[3]
+	sL, sR := blindings[4:4+n], blindings[4+n:]
+
+	proof := &RangeProof{
+		Bits:       bits,
+		Commitment: PedersenCommit(NewScalar(BN256, value), blinding),
+		A:          vectorCommit(alpha, G, aL, H, aR),
+		S:          vectorCommit(rho, G, sL, H, sR),
+	}
+
+	t := newTranscript(BN256, rangeProofLabel)
+	t.append("bits", []byte{byte(bits)})
+	t.appendPoint("V", &proof.Commitment)
+	t.appendPoint("A", &proof.A)
+	t.appendPoint("S", &proof.S)
+	y := t.challengeScalar()
+	z := t.challengeScalar()
+
+	yn := scalarPowers(y, n)
+	twon := scalarPowers(NewScalar(BN256, big.NewInt(2)), n)
+	z2 := z.Mul(z)
+
+	// l(X) = l0 + l1·X and r(X) = r0 + r1·X
+	l0 := make([]Scalar, n)
+	r0 := make([]Scalar, n)
+	r1 := make([]Scalar, n)
+	for i := range l0 {
+		l0[i] = aL[i].Sub(z)
+		r0[i] = yn[i].Mul(aR[i].Add(z)).Add(z2.Mul(twon[i]))
+		r1[i] = yn[i].Mul(sR[i])
+	}
+	t1 := innerProduct(l0, r1).Add(innerProduct(sL, r0))
+	t2 := innerProduct(sL, r1)
+
+	proof.T1 = PedersenCommit(t1, tau1)
+	proof.T2 = PedersenCommit(t2, tau2)
+	t.appendPoint("T1", &proof.T1)
+	t.appendPoint("T2", &proof.T2)
+	x := t.challengeScalar()
+
+	l := make([]Scalar, n)
+	r := make([]Scalar, n)
+	for i := range l {
+		l[i] = l0[i].Add(sL[i].Mul(x))
+		r[i] = r0[i].Add(r1[i].Mul(x))
+	}
+	proof.T = innerProduct(l, r)
+	proof.TauX = tau2.Mul(x).Mul(x).Add(tau1.Mul(x)).Add(z2.Mul(blinding))
+	proof.Mu = alpha.Add(rho.Mul(x))
+
+	t.append("taux", proof.TauX.Bytes())
+	t.append("mu", proof.Mu.Bytes())
+	t.append("t", proof.T.Bytes())
+	w := t.challengeScalar()
+
+	// The inner product argument is over H'_i = H_i^(y^-i), so that
+	// H'^r(x) commits to r(x) without the powers of y
+	ones := scalarPowers(NewScalar(BN256, bigOne), n)
+	yInv := scalarPowers(y.Inv(), n)
+	proof.L, proof.R, proof.FinalA, proof.FinalB = innerProductProve(t, G, ones, H, yInv, U.ScalarMult(w.Int()), l, r)
+	return proof, nil
+}
+
+// innerProductProve proves knowledge of a and b such that P = G'^a · H'^b ·
+// u^<a,b>, where G'_i = G_i^gs_i and H'_i = H_i^hs_i, halving the vectors
+// each round:
+//
+//   cL ← <a_lo, b_hi>, cR ← <a_hi, b_lo>
+//   L ← G'_hi^a_lo · H'_lo^b_hi · u^cL, R ← G'_lo^a_hi · H'_hi^b_lo · u^cR
+//   e ← H(L, R)
+//   a ← a_lo·e + a_hi·e⁻¹, b ← b_lo·e⁻¹ + b_hi·e
+//   G' ← G'_lo^e⁻¹ ∘ G'_hi^e, H' ← H'_lo^e ∘ H'_hi^e⁻¹
+//
+// until a and b are single scalars. The folded generators aren't computed,
+// instead the scale of each original generator is kept: after the vectors
+// are halved to m elements G'_i is the sum of the G_j with j = i modulo m,
+// so L and R are each a single multiplication of the original generators.
+//
+func innerProductProve(t *transcript, G []CurvePoint, gs []Scalar, H []CurvePoint, hs []Scalar, u CurvePoint, a, b []Scalar) ([]CurvePoint, []CurvePoint, Scalar, Scalar) {
+	n := len(G)
+	gs = append([]Scalar{}, gs...)
+	hs = append([]Scalar{}, hs...)
+
+	var Ls, Rs []CurvePoint
+	for m := len(a); m > 1; m /= 2 {
+		k := m / 2
+		lPoints := []CurvePoint{u}
+		rPoints := []CurvePoint{u}
+		lScalars := []Scalar{innerProduct(a[:k], b[k:])}
+		rScalars := []Scalar{innerProduct(a[k:], b[:k])}
+		for j := 0; j < n; j++ {
+			if i := j % m; i < k {
+				lPoints, lScalars = append(lPoints, H[j]), append(lScalars, b[i+k].Mul(hs[j]))
+				rPoints, rScalars = append(rPoints, G[j]), append(rScalars, a[i+k].Mul(gs[j]))
+			} else {
+				lPoints, lScalars = append(lPoints, G[j]), append(lScalars, a[i-k].Mul(gs[j]))
+				rPoints, rScalars = append(rPoints, H[j]), append(rScalars, b[i-k].Mul(hs[j]))
+			}
+		}
+		L := MultiScalarMult(BN256, lPoints, scalarInts(lScalars))
+		R := MultiScalarMult(BN256, rPoints, scalarInts(rScalars))
+
+		t.appendPoint("L", &L)
+		t.appendPoint("R", &R)
+		e := t.challengeScalar()
+		eInv := e.Inv()
+		Ls = append(Ls, L)
+		Rs = append(Rs, R)
+
+		nextA := make([]Scalar, k)
+		nextB := make([]Scalar, k)
+		for i := 0; i < k; i++ {
+			nextA[i] = a[i].Mul(e).Add(a[k+i].Mul(eInv))
+			nextB[i] = b[i].Mul(eInv).Add(b[k+i].Mul(e))
+		}
+		for j := 0; j < n; j++ {
+			if j%m < k {
+				gs[j], hs[j] = gs[j].Mul(eInv), hs[j].Mul(e)
+			} else {
+				gs[j], hs[j] = gs[j].Mul(e), hs[j].Mul(eInv)
+			}
+		}
+		a, b = nextA, nextB
+	}
+	return Ls, Rs, a[0], b[0]
+}
+
+// Verify checks the commitment is to a value between 0 and 2^Bits. The
+// polynomial is checked with:
+//
+//   h^t · g^τx = V^z² · h^δ(y,z) · T1^x · T2^x²
+//   δ(y,z) = (z - z²)·<1, y^n> - z³·<1, 2^n>
+//
+// and the inner product argument, folded into a single multiplication of
+// all of the generators, with:
+//
+//   A · S^x · G^(-z - a·s) · H^(z + (z²·2^n - b·s⁻¹) ∘ y^-n) · g^-μ
+//     · u^(w·(t - a·b)) · Π L_j^(e_j²) · R_j^(e_j⁻²) = 1
+//
+// where s_i is the product of e_j or e_j⁻¹ for each round j, as bit j of i,
+// counting from the most significant, is 1 or 0.
+//
+func (p *RangeProof) Verify() bool {
+	rounds, err := rangeProofRounds(p.Bits)
+	if err != nil || len(p.L) != rounds || len(p.R) != rounds {
+		return false
+	}
+	points := []CurvePoint{p.Commitment, p.A, p.S, p.T1, p.T2}
+	points = append(append(points, p.L...), p.R...)
+	for _, point := range points {
+		if point.Group() != BN256 {
+			return false
+		}
+	}
+	for _, s := range []Scalar{p.TauX, p.Mu, p.T, p.FinalA, p.FinalB} {
+		if s.Group() != BN256 {
+			return false
+		}
+	}
+
+	n := p.Bits
+	G, H, U := rangeProofGenerators(n)
+	g := BN256.ScalarBaseMult(bigOne)
+
+	t := newTranscript(BN256, rangeProofLabel)
+	t.append("bits", []byte{byte(n)})
+	t.appendPoint("V", &p.Commitment)
+	t.appendPoint("A", &p.A)
+	t.appendPoint("S", &p.S)
+	y := t.challengeScalar()
+	z := t.challengeScalar()
+	t.appendPoint("T1", &p.T1)
+	t.appendPoint("T2", &p.T2)
+	x := t.challengeScalar()
+	t.append("taux", p.TauX.Bytes())
+	t.append("mu", p.Mu.Bytes())
+	t.append("t", p.T.Bytes())
+	w := t.challengeScalar()
+
+	e := make([]Scalar, rounds)
+	for j := range e {
+		t.appendPoint("L", &p.L[j])
+		t.appendPoint("R", &p.R[j])
+		e[j] = t.challengeScalar()
+		if e[j].IsZero() {
+			return false
+		}
+	}
+	if y.IsZero() {
+		return false
+	}
+
+	yn := scalarPowers(y, n)
+	twon := scalarPowers(NewScalar(BN256, big.NewInt(2)), n)
+	z2 := z.Mul(z)
+	z3 := z2.Mul(z)
+	delta := z.Sub(z2).Mul(sumScalars(yn)).Sub(z3.Mul(sumScalars(twon)))
+
+	// h^(t - δ) · g^τx · V^-z² · T1^-x · T2^-x² = 1
+	polynomial := MultiScalarMult(
+		BN256,
+		[]CurvePoint{*generatorH, g, p.Commitment, p.T1, p.T2},
+		scalarInts([]Scalar{p.T.Sub(delta), p.TauX, z2.Neg(), x.Neg(), x.Mul(x).Neg()}),
+	)
+	if false == polynomial.IsInfinity() {
+		return false
+	}
+
+	// s_i for each generator, from the challenges of the rounds
+	s := make([]Scalar, n)
+	for i := range s {
+		s[i] = NewScalar(BN256, bigOne)
+		for j := range e {
+			if (i>>uint(rounds-1-j))&1 == 1 {
+				s[i] = s[i].Mul(e[j])
+			} else {
+				s[i] = s[i].Mul(e[j].Inv())
+			}
+		}
+	}
+
+	yInv := scalarPowers(y.Inv(), n)
+	points = []CurvePoint{p.A, p.S, g, U}
+	scalars := []Scalar{
+		NewScalar(BN256, bigOne),
+		x,
+		p.Mu.Neg(),
+		w.Mul(p.T.Sub(p.FinalA.Mul(p.FinalB))),
+	}
+	for i := 0; i < n; i++ {
+		points = append(points, G[i], H[i])
+		scalars = append(scalars,
+			z.Neg().Sub(p.FinalA.Mul(s[i])),
+			z.Add(z2.Mul(twon[i]).Sub(p.FinalB.Mul(s[i].Inv())).Mul(yInv[i])),
+		)
+	}
+	for j := range e {
+		e2 := e[j].Mul(e[j])
+		points = append(points, p.L[j], p.R[j])
+		scalars = append(scalars, e2, e2.Inv())
+	}
+	return MultiScalarMult(BN256, points, scalarInts(scalars)).IsInfinity()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestPedersenCommitment(t *testing.T) {
+	a, openA, err := NewPedersenCommitment(rand.Reader, big.NewInt(40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, openB, err := NewPedersenCommitment(rand.Reader, big.NewInt(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !openA.Verify(&a) || openA.Verify(&b) {
+		t.Fatal("Opening does not verify against its own commitment only")
+	}
+
+	// The sum of commitments is a commitment to the sum
+	sum := a.Add(b)
+	openSum := &PedersenOpening{openA.Value.Add(openB.Value), openA.Blinding.Add(openB.Blinding)}
+	if !openSum.Verify(&sum) || openSum.Value.Int().Cmp(big.NewInt(42)) != 0 {
+		t.Fatal("Commitments are not additive")
+	}
+
+	data, err := json.Marshal(openA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded PedersenOpening
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Verify(&a) {
+		t.Fatalf("Opening not verified after a JSON round trip: %s", data)
+	}
+}
+
+func TestRangeProof(t *testing.T) {
+	blinding, err := RandomScalar(BN256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	max64 := new(big.Int).Sub(new(big.Int).Lsh(bigOne, 64), bigOne)
+
+	for _, tc := range []struct {
+		value *big.Int
+		bits  int
+	}{
+		{big.NewInt(0), 1},
+		{big.NewInt(1), 1},
+		{big.NewInt(0), 8},
+		{big.NewInt(255), 8},
+		{big.NewInt(1000), 16},
+		{max64, 64},
+	} {
+		proof, err := NewRangeProof(rand.Reader, tc.value, blinding, tc.bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proof.Verify() {
+			t.Fatalf("Range proof of %v in %v bits not verified", tc.value, tc.bits)
+		}
+		opening := PedersenOpening{NewScalar(BN256, tc.value), blinding}
+		if !opening.Verify(&proof.Commitment) {
+			t.Fatalf("Range proof of %v is not of its commitment", tc.value)
+		}
+	}
+}
+
+func TestRangeProofOutOfRange(t *testing.T) {
+	blinding, err := RandomScalar(BN256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []*big.Int{big.NewInt(256), big.NewInt(-1)} {
+		if _, err := NewRangeProof(rand.Reader, value, blinding, 8); err == nil {
+			t.Fatalf("Proved %v is in 8 bits", value)
+		}
+	}
+	for _, bits := range []int{0, 12, 128} {
+		if _, err := NewRangeProof(rand.Reader, big.NewInt(1), blinding, bits); err == nil {
+			t.Fatalf("Proved a range of %v bits", bits)
+		}
+	}
+}
+
+func TestRangeProofTampering(t *testing.T) {
+	blinding, err := RandomScalar(BN256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := NewRangeProof(rand.Reader, big.NewInt(1000), blinding, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded RangeProof
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Verify() {
+		t.Fatalf("Range proof not verified after a JSON round trip: %s", data)
+	}
+
+	one := NewScalar(BN256, bigOne)
+	tampered := []func(p *RangeProof){
+		// A commitment to another value
+		func(p *RangeProof) { p.Commitment = p.Commitment.Add(*generatorH) },
+		func(p *RangeProof) { p.T = p.T.Add(one) },
+		func(p *RangeProof) { p.TauX = p.TauX.Add(one) },
+		func(p *RangeProof) { p.Mu = p.Mu.Add(one) },
+		func(p *RangeProof) { p.FinalA = p.FinalA.Add(one) },
+		func(p *RangeProof) { p.L[0], p.R[0] = p.R[0], p.L[0] },
+		func(p *RangeProof) { p.Bits = 8 },
+		func(p *RangeProof) { p.L, p.R = p.L[1:], p.R[1:] },
+	}
+	for i, tamper := range tampered {
+		var p RangeProof
+		if err := json.Unmarshal(data, &p); err != nil {
+			t.Fatal(err)
+		}
+		tamper(&p)
+		if p.Verify() {
+			t.Fatalf("Tampered range proof %v verified", i)
+		}
+	}
+}
@@ -7,48 +7,100 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"math/big"
 )
 
-// A Ring is a number of public/private key pairs
+// A Ring is a number of public/private key pairs, of the group Curve or
+// BN256 when it is nil. A private key which isn't known is zero. Members
+// may attach a proof of possession of their key, see ProvePossession.
 type Ring struct {
-	PubKeys  []CurvePoint `json:"pubkeys"`
-	PrivKeys []*big.Int   `json:"privkeys"`
+	PubKeys  []CurvePoint        `json:"pubkeys"`
+	PrivKeys []Scalar            `json:"privkeys"`
+	Proofs   []*SchnorrSignature `json:"proofs,omitempty"`
+	Curve    Group               `json:"-"`
+}
+
+// group returns the group of the keys of the ring, when Curve isn't set
+// it is the group of the first public key
+func (r *Ring) group() Group {
+	if r.Curve != nil {
+		return r.Curve
+	}
+	if len(r.PubKeys) > 0 {
+		return r.PubKeys[0].Group()
+	}
+	return BN256
 }
 
 // MarshalJSON converts a Ring to a JSON representation
 func (r *Ring) MarshalJSON() ([]byte, error) {
-	pks := make([]*hexBig, len(r.PrivKeys))
-	for i, v := range r.PrivKeys {
-		pks[i] = (*hexBig)(v)
+	pks := make([]*Scalar, len(r.PrivKeys))
+	for i := range r.PrivKeys {
+		if false == r.PrivKeys[i].IsZero() {
+			pks[i] = &r.PrivKeys[i]
+		}
 	}
 
 	return json.Marshal(&struct {
-		PubKeys  []CurvePoint `json:"pubkeys"`
-		PrivKeys []*hexBig    `json:"privkeys"`
+		Curve    string              `json:"curve"`
+		PubKeys  []CurvePoint        `json:"pubkeys"`
+		PrivKeys []*Scalar           `json:"privkeys"`
+		Proofs   []*SchnorrSignature `json:"proofs,omitempty"`
 	}{
+		Curve:    r.group().Name(),
 		PubKeys:  r.PubKeys,
 		PrivKeys: pks,
+		Proofs:   r.Proofs,
 	})
 }
 
 // UnmarshalJSON converts a JSON representation to a Ring struct
 func (r *Ring) UnmarshalJSON(data []byte) error {
 	var aux struct {
-		PubKeys  []CurvePoint `json:"pubkeys"`
-		PrivKeys []*hexBig    `json:"privkeys"`
+		Curve    string              `json:"curve"`
+		PubKeys  []CurvePoint        `json:"pubkeys"`
+		PrivKeys []*hexBig           `json:"privkeys"`
+		Proofs   []*SchnorrSignature `json:"proofs"`
 	}
 	err := json.Unmarshal(data, &aux)
 	if err != nil {
 		return err
 	}
 
-	pks := make([]*big.Int, len(aux.PrivKeys))
+	g, err := CurveByName(aux.Curve)
+	if err != nil {
+		return err
+	}
+	for _, pub := range aux.PubKeys {
+		if pub.Group() != g {
+			return fmt.Errorf("Public key is not on the curve of the ring: %v", g.Name())
+		}
+		if pub.IsInfinity() {
+			return errors.New("Public key is the point at infinity")
+		}
+	}
+
+	if len(aux.Proofs) != 0 && len(aux.Proofs) != len(aux.PubKeys) {
+		return fmt.Errorf("Ring has %v proofs of possession for %v members", len(aux.Proofs), len(aux.PubKeys))
+	}
+
+	pks := make([]Scalar, len(aux.PrivKeys))
 	for i, v := range aux.PrivKeys {
-		pks[i] = (*big.Int)(v)
+		if v == nil {
+			continue
+		}
+		pks[i], err = ParseScalar(g, (*big.Int)(v))
+		if err != nil {
+			return fmt.Errorf("Invalid private key %v: %v", i, err)
+		}
 	}
-	r.PrivKeys = pks[:]
+	r.PrivKeys = pks
 	r.PubKeys = aux.PubKeys
+	r.Proofs = aux.Proofs
+	r.Curve = g
 	return nil
 }
 
@@ -72,13 +124,13 @@ func (r Ring) PublicKeysHashed() [sha256.Size]byte {
 }
 
 // Generate creates public and private keypairs for a ring with the size of n
-func (r *Ring) Generate(n int) error {
+func (r *Ring) Generate(random io.Reader, n int) error {
 	for i := 0; i < n; i++ {
-		public, private, err := generateKeyPair()
+		public, private, err := newKeyPair(r.group(), random)
 		if err != nil {
 			return err
 		}
-		r.PrivKeys = append(r.PrivKeys, private)
+		r.PrivKeys = append(r.PrivKeys, NewScalar(r.group(), private))
 		r.PubKeys = append(r.PubKeys, *public)
 	}
 
@@ -98,131 +150,47 @@ func (r *Ring) PubKeyIndex(pk CurvePoint) int {
 
 }
 
-// Signature generates a signature
-func (r *Ring) Signature(pk *big.Int, message []byte, signer int) (*RingSignature, error) {
-	N := CurvePoint{}.Order()
+// messagePoint maps the 256 bit message token onto the curve of BN256,
+// longer messages are truncated and shorter ones are zero padded
+func messagePoint(message []byte) *CurvePoint {
+	return groupMessagePoint(BN256, message)
+}
 
-	// Message is a 256 bit token which uniquely identifies the Ring and the public keys
-	// of all of its participants
+// groupMessagePoint maps the message token onto the curve of the group g
+func groupMessagePoint(g Group, message []byte) *CurvePoint {
 	var messageHash [32]byte
 	copy(messageHash[:], message)
-	hashp := NewCurvePointFromHash(messageHash)
-
-	// Calculate Tau
-	pk.Mod(pk, N)
-	hashSP := hashp.ScalarMult(pk)
-
-	// hashout = H(hash.X, tau)
-	hashAcc := sha256.Sum256(append(hashp.Marshal()[:32], hashSP.Marshal()...))
-
-	n := len(r.PubKeys)
-	var ctlist []*big.Int //This has to be 2n so here we have n = 4 so 2n = 8 :)
-	var a, b CurvePoint
-	var ri *big.Int
-
-	csum := big.NewInt(0)
-
-	for j := 0; j < n; j++ {
-
-		if j != signer {
-			cj := CurvePoint{}.RandomN()
-			tj := CurvePoint{}.RandomN()
-
-			a = r.PubKeys[j].ParameterPointAdd(tj, cj)
-
-			b = hashp.HashPointAdd(hashSP, tj, cj)
-			ctlist = append(ctlist, cj)
-			ctlist = append(ctlist, tj)
-			csum.Add(csum, cj)
-		}
-
-		if j == signer {
-			dummy := big.NewInt(0)
-			ctlist = append(ctlist, dummy)
-			ctlist = append(ctlist, dummy)
-			ri = CurvePoint{}.RandomN()
-			a = CurvePoint{}.ScalarBaseMult(ri)
-			b = hashp.ScalarMult(ri)
-		}
-
-		hashAcc = sha256.Sum256(append(hashAcc[:], append(a.Marshal(), b.Marshal()...)...))
-	}
-
-	hashb := new(big.Int).SetBytes(hashAcc[:])
-	hashb.Mod(hashb, N)
-
-	csum.Mod(csum, N)
-	c := new(big.Int).Sub(hashb, csum)
-	c.Mod(c, N)
-
-	cx := new(big.Int).Mul(c, pk)
-	cx.Mod(cx, N)
-	ti := new(big.Int).Sub(ri, cx)
-	ti.Mod(ti, N)
-	ctlist[2*signer] = c
-	ctlist[2*signer+1] = ti
-
-	return &RingSignature{hashSP, ctlist}, nil
+	p := g.HashToPoint(messageHash)
+	return &p
 }
 
-// Signatures generates a signature given a message
-func (r *Ring) Signatures(message []byte) ([]RingSignature, error) {
-
-	var signaturesArr []RingSignature
+// Signature generates a signature
+func (r *Ring) Signature(random io.Reader, pk Scalar, message []byte, signer int) (*RingSignature, error) {
+	return NewSigningContext(r, message).Sign(random, pk, signer)
+}
 
-	for i, privKey := range r.PrivKeys {
-		pub := r.PubKeys[i]
-		signerNumber := r.PubKeyIndex(pub)
-		signature, err := r.Signature(privKey, message, signerNumber)
-		if err != nil {
-			return nil, err
-		}
-		signaturesArr = append(signaturesArr, *signature)
-	}
+// DeterministicSignature generates a signature with the randomness drawn
+// from an HMAC-DRBG seeded with the private key, message and ring, so it
+// doesn't rely on the system random number generator:
+//
+//   seed ← x | SHA256(m) | PublicKeysHashed | signer | extra
+//
+// Signing the same message twice gives the same signature. Extra entropy,
+// when given, is mixed into the seed so a broken generator is no worse
+// than deterministic signing.
+//
+func (r *Ring) DeterministicSignature(pk Scalar, message []byte, signer int, extra []byte) (*RingSignature, error) {
+	return NewSigningContext(r, message).DeterministicSign(pk, signer, extra)
+}
 
-	return signaturesArr, nil
+// Signatures generates a signature given a message with every private key
+// of the ring, see SigningContext.SignAll
+func (r *Ring) Signatures(random io.Reader, message []byte) ([]RingSignature, error) {
+	return NewSigningContext(r, message).SignAll(random)
 }
 
-// VerifySignature verifys a signature given a message
+// VerifySignature verifys a signature given a message, see
+// SigningContext.Verify
 func (r *Ring) VerifySignature(message []byte, sigma RingSignature) bool {
-	// ring verification
-	// assumes R = pk1, pk2, ..., pkn
-	// sigma = H(m||R)^x_i, c1, t1, ..., cn, tn = taux, tauy, c1, t1, ..., cn, tn
-	tau := sigma.Tau
-	ctlist := sigma.Ctlist
-	n := len(r.PubKeys)
-	N := CurvePoint{}.Order() //group.N
-
-	var messageHash [32]byte
-	copy(messageHash[:], message)
-	hashp := NewCurvePointFromHash(messageHash)
-
-	hashAcc := sha256.Sum256(append(hashp.Marshal()[:32], tau.Marshal()...))
-
-	csum := big.NewInt(0)
-
-	for j := 0; j < n; j++ {
-		cj := ctlist[2*j]
-		tj := ctlist[2*j+1]
-		cj.Mod(cj, N)
-		tj.Mod(tj, N)
-
-		yc := r.PubKeys[j].ScalarMult(cj)     // y^c = g^(xc)
-		gt := CurvePoint{}.ScalarBaseMult(tj) // g^t + y^c
-		gt = gt.Add(yc)
-
-		tauc := tau.ScalarMult(cj) //H(m||R)^(xc)
-		H := hashp.ScalarMult(tj)  //H(m||R)^t
-		H = H.Add(tauc)            // fieldJacobianToBigAffine `normalizes' values before returning so yes - normalize uses fast reduction using specialised form of secp256k1's prime! :D
-
-		hashAcc = sha256.Sum256(append(hashAcc[:], append(gt.Marshal(), H.Marshal()...)...))
-
-		csum.Add(csum, cj)
-		csum.Mod(csum, N)
-	}
-
-	hashout := new(big.Int).SetBytes(hashAcc[:])
-	hashout.Mod(hashout, N)
-	csum.Mod(csum, N)
-	return csum.Cmp(hashout) == 0
+	return NewSigningContext(r, message).Verify(sigma)
 }
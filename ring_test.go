@@ -5,12 +5,22 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
 	"testing"
+
+	"github.com/clearmatics/bn256"
 )
 
 func generateRing(i int) Ring {
 	ring := &Ring{}
-	ring.Generate(i)
+	ring.Generate(rand.Reader, i)
 	return *ring
 }
 
@@ -36,7 +46,7 @@ func TestRingSignature(t *testing.T) {
 	r := generateRing(i)
 	message := []byte("foobarbaz")
 
-	_, err := r.Signature(r.PrivKeys[0], message, 0)
+	_, err := r.Signature(rand.Reader, r.PrivKeys[0], message, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -50,7 +60,7 @@ func BenchmarkRingSignature(b *testing.B) {
 		message := []byte("foobarbaz")
 
 		b.StartTimer()
-		_, err := r.Signature(r.PrivKeys[0], message, 0)
+		_, err := r.Signature(rand.Reader, r.PrivKeys[0], message, 0)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -62,7 +72,7 @@ func TestRingSignatures(t *testing.T) {
 	r := generateRing(i)
 	s := []byte("foobarbaz")
 
-	sigs, err := r.Signatures(s)
+	sigs, err := r.Signatures(rand.Reader, s)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -82,7 +92,7 @@ func BenchmarkRingSignatures(b *testing.B) {
 		message := []byte("foobarbaz")
 
 		b.StartTimer()
-		_, err := r.Signatures(message)
+		_, err := r.Signatures(rand.Reader, message)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -94,7 +104,7 @@ func TestVerifySignature(t *testing.T) {
 	r := generateRing(i)
 	message := []byte("foobarbaz")
 
-	sig, err := r.Signature(r.PrivKeys[0], message, 0)
+	sig, err := r.Signature(rand.Reader, r.PrivKeys[0], message, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -115,7 +125,7 @@ func BenchmarkVerifySignature(b *testing.B) {
 		r := generateRing(i)
 		message := []byte("foobarbaz")
 
-		sig, err := r.Signature(r.PrivKeys[0], message, 0)
+		sig, err := r.Signature(rand.Reader, r.PrivKeys[0], message, 0)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -136,7 +146,7 @@ func TestVerifySignatures(t *testing.T) {
 	i := 4
 	r := generateRing(i)
 	message := []byte("foobarbaz")
-	sigs, err := r.Signatures(message)
+	sigs, err := r.Signatures(rand.Reader, message)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -158,7 +168,7 @@ func BenchmarkVerifySignatures(b *testing.B) {
 		i := 4
 		r := generateRing(i)
 		message := []byte("foobarbaz")
-		sigs, err := r.Signatures(message)
+		sigs, err := r.Signatures(rand.Reader, message)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -180,7 +190,7 @@ func TestVerifySignatureBad(t *testing.T) {
 	r := generateRing(i)
 	message := []byte("foobarbaz")
 
-	sig, err := r.Signature(r.PrivKeys[0], message, 0)
+	sig, err := r.Signature(rand.Reader, r.PrivKeys[0], message, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -211,3 +221,288 @@ func TestPubKeyIndexNotFound(t *testing.T) {
 		t.Errorf("Expected %v but got %v", expected, actual)
 	}
 }
+
+// katRing is a ring with the private keys 1, 2 and 3
+func katRing() Ring {
+	var r Ring
+	for i := int64(1); i <= 3; i++ {
+		r.PrivKeys = append(r.PrivKeys, NewScalar(BN256, big.NewInt(i)))
+		r.PubKeys = append(r.PubKeys, derivePublicKey(big.NewInt(i)))
+	}
+	return r
+}
+
+func TestDeterministicSignatureKAT(t *testing.T) {
+	tests := []struct {
+		extra  []byte
+		ctlist []string
+	}{
+		{nil, []string{
+			"05e7f1234bc7b5dec7e0345d35ae44bc6633cf0b239e2602ba71d931765b3743",
+			"1fcbe0c7e91dd71dec67c504c8f834a6c2d3b4f44a26aabd84023ac79884ee5e",
+			"202eb1e98c98c43ba1f44d1365793b12316a93103c2eca9e3a36009b6a35c027",
+			"26cd0a010334e166bf4f5f67800ce14b54b4ab56fbe73a61ecab2d1a600b14bd",
+			"106e860ea0092c2efb59cc2e73fba7ce16cc00c9bbfb2cd7f271952468425a33",
+			"271f978cd331e337e15ab65a9f7d9988dbbda0e0947a45a488ac6061c508a08a",
+		}},
+		{[]byte("extra"), []string{
+			"2d49fe607ca90009ba3bf6201ebaa0fccd32490ca9dc4fe6bb857a2c887798c8",
+			"2b19acb3077a3e1de4b177713b59bc6d0e1ce0f440f9f29c45f25d656cb9bccf",
+			"221bb785a199a6f780d9f0b921c272bb33e4be77209abb4946c4fc4aee889cd2",
+			"22907f2c3986c97e3d167d1b47246d0cb4740282f58669e29d5ab54b2054b3c0",
+			"1219d21ee1843148de8688e73415d19a40ff5ea6af72cc66a2da4dc2d4c4d398",
+			"1d6633ecbbb1755abad3b4bd4bd4cd79e359aee6fe7cac445d1259a88688d09b",
+		}},
+	}
+
+	r := katRing()
+	message := []byte("foobarbaz")
+	for _, test := range tests {
+		sig, err := r.DeterministicSignature(r.PrivKeys[1], message, 1, test.extra)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, expected := range test.ctlist {
+			actual := fmt.Sprintf("%064x", sig.Ctlist[i].Int())
+			if actual != expected {
+				t.Errorf("ctlist[%v]: expected %v but got %v", i, expected, actual)
+			}
+		}
+		if !r.VerifySignature(message, *sig) {
+			t.Error("Signature not verified")
+		}
+	}
+}
+
+func TestDeterministicSignatureRepeat(t *testing.T) {
+	r := generateRing(3)
+	message := []byte("foobarbaz")
+
+	a, err := r.DeterministicSignature(r.PrivKeys[0], message, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := r.DeterministicSignature(r.PrivKeys[0], message, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range a.Ctlist {
+		if !a.Ctlist[i].Equal(b.Ctlist[i]) {
+			t.Fatal("Signing twice gave different signatures")
+		}
+	}
+
+	c, err := r.DeterministicSignature(r.PrivKeys[0], []byte("otherbaz"), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Ctlist[1].Equal(c.Ctlist[1]) {
+		t.Fatal("Different messages gave the same randomness")
+	}
+}
+
+// naiveVerifySignature verifies a signature with a separate bn256 scalar
+// multiplication for every term, as a baseline for the benchmarks
+func naiveVerifySignature(r *Ring, message []byte, sigma RingSignature) bool {
+	N := CurvePoint{}.Order()
+	hashp := messagePoint(message)
+	hashAcc := sha256.Sum256(append(hashp.Marshal()[:32], sigma.Tau.Marshal()...))
+
+	csum := big.NewInt(0)
+	for j := range r.PubKeys {
+		cj := sigma.Ctlist[2*j].Int()
+		tj := sigma.Ctlist[2*j+1].Int()
+
+		gt := newBN256Point(new(bn256.G1).ScalarBaseMult(tj))
+		gt = gt.Add(newBN256Point(new(bn256.G1).ScalarMult(r.PubKeys[j].g1(), cj)))
+		H := newBN256Point(new(bn256.G1).ScalarMult(hashp.g1(), tj))
+		H = H.Add(newBN256Point(new(bn256.G1).ScalarMult(sigma.Tau.g1(), cj)))
+
+		hashAcc = sha256.Sum256(append(hashAcc[:], append(gt.Marshal(), H.Marshal()...)...))
+		csum.Add(csum, cj)
+	}
+
+	hashout := new(big.Int).SetBytes(hashAcc[:])
+	hashout.Mod(hashout, N)
+	csum.Mod(csum, N)
+	return csum.Cmp(hashout) == 0
+}
+
+func BenchmarkVerifySignatureMSM(b *testing.B) {
+	for _, n := range []int{4, 16, 64} {
+		r := generateRing(n)
+		message := []byte("foobarbaz")
+		sig, err := r.Signature(rand.Reader, r.PrivKeys[0], message, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("n=%d/naive", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if !naiveVerifySignature(&r, message, *sig) {
+					b.Fatal("Signature not verified")
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("n=%d/msm", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if !r.VerifySignature(message, *sig) {
+					b.Fatal("Signature not verified")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkScalarBaseMult(b *testing.B) {
+	x, err := CurvePoint{}.RandomN(rand.Reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+	BN256.ScalarBaseMult(x)
+
+	b.Run("naive", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			new(bn256.G1).ScalarBaseMult(x)
+		}
+	})
+	b.Run("table", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			CurvePoint{}.ScalarBaseMult(x)
+		}
+	})
+}
+
+func BenchmarkMultiScalarMult(b *testing.B) {
+	for _, n := range []int{2, 16, 256} {
+		points, scalars := randomPointsAndScalars(b, n)
+
+		b.Run(fmt.Sprintf("n=%d/naive", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				naiveMultiScalarMult(points, scalars)
+			}
+		})
+		b.Run(fmt.Sprintf("n=%d/msm", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				MultiScalarMult(BN256, points, scalars)
+			}
+		})
+	}
+}
+
+// A ringFixture is a signature recorded in testdata, to check changes to
+// signing and verification don't change their output
+type ringFixture struct {
+	Ring      *Ring          `json:"ring"`
+	Message   []byte         `json:"message"`
+	Signer    int            `json:"signer"`
+	Signature *RingSignature `json:"signature"`
+}
+
+func TestRingSignatureFixtures(t *testing.T) {
+	path := filepath.Join("testdata", "ring-signatures.json")
+
+	if *update {
+		var fixtures []ringFixture
+		random := newHmacDRBG([]byte("orbital-fixtures"))
+		for _, n := range []int{1, 2, 5, 16} {
+			r := &Ring{}
+			if err := r.Generate(random, n); err != nil {
+				t.Fatal(err)
+			}
+			message := append([]byte("fixture message "), byte(n))
+			sig, err := r.DeterministicSignature(r.PrivKeys[n/2], message, n/2, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fixtures = append(fixtures, ringFixture{r, message, n / 2, sig})
+		}
+
+		data, err := json.MarshalIndent(fixtures, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var fixtures []ringFixture
+	if err := readJSONFile(path, &fixtures); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range fixtures {
+		if !f.Ring.VerifySignature(f.Message, *f.Signature) {
+			t.Errorf("Recorded signature not verified for ring of %v", len(f.Ring.PubKeys))
+		}
+
+		sig, err := f.Ring.DeterministicSignature(f.Ring.PrivKeys[f.Signer], f.Message, f.Signer, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := json.Marshal(f.Signature)
+		actual, _ := json.Marshal(sig)
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("Signature differs from recording for ring of %v:\n%s", len(f.Ring.PubKeys), actual)
+		}
+	}
+}
+
+func BenchmarkRingSignatureAllocs(b *testing.B) {
+	r := generateRing(64)
+	message := []byte("foobarbaz")
+	sig, err := r.Signature(rand.Reader, r.PrivKeys[0], message, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("sign", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := r.Signature(rand.Reader, r.PrivKeys[0], message, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("verify", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if !r.VerifySignature(message, *sig) {
+				b.Fatal("Signature not verified")
+			}
+		}
+	})
+}
+
+func BenchmarkSigningContext(b *testing.B) {
+	r := generateRing(32)
+	message := []byte("foobarbaz")
+
+	b.Run("separate", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j, pk := range r.PrivKeys {
+				if _, err := r.Signature(rand.Reader, pk, message, j); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("context", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ctx := NewSigningContext(&r, message)
+			ctx.Workers = 1
+			if _, err := ctx.SignAll(rand.Reader); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := NewSigningContext(&r, message).SignAll(rand.Reader); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
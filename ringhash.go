@@ -0,0 +1,158 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"crypto/sha256"
+	"hash"
+	"math/big"
+	"sync"
+)
+
+// A ringHasher computes the hash chain of a ring signature:
+//
+//   h_0 ← SHA256(H(m).x | τ)
+//   h_j ← SHA256(h_{j-1} | a_j | b_j)
+//
+// The hasher, the buffer the points are encoded into and the integers used
+// to convert them to affine coordinates are reused for every step, the
+// output is the same as hashing Marshal of each point.
+//
+type ringHasher struct {
+	group Group
+	h     hash.Hash
+	sum   []byte
+	buf   [64]byte
+	inv   big.Int
+	zInv  big.Int
+	zInv2 big.Int
+	q     big.Int
+	t     big.Int
+	u     big.Int
+}
+
+var ringHasherPool = sync.Pool{
+	New: func() interface{} {
+		return &ringHasher{
+			h:   sha256.New(),
+			sum: make([]byte, 0, sha256.Size),
+		}
+	},
+}
+
+// newRingHasher returns a hasher from the pool, started with h_0, for
+// points in the group of τ
+func newRingHasher(hashp *CurvePoint, tau *CurvePoint) *ringHasher {
+	rh := ringHasherPool.Get().(*ringHasher)
+	rh.group = tau.Group()
+	rh.h.Reset()
+	rh.writePoint(hashp, 32)
+	rh.writePoint(tau, 64)
+	rh.sum = rh.h.Sum(rh.sum[:0])
+	return rh
+}
+
+// newRingHasherX is newRingHasher for when the encoded x coordinate of
+// H(m) is already known
+func newRingHasherX(hashpX []byte, tau *CurvePoint) *ringHasher {
+	rh := ringHasherPool.Get().(*ringHasher)
+	rh.group = tau.Group()
+	rh.h.Reset()
+	rh.h.Write(hashpX)
+	rh.writePoint(tau, 64)
+	rh.sum = rh.h.Sum(rh.sum[:0])
+	return rh
+}
+
+// release returns the hasher to the pool, it must not be used after
+func (rh *ringHasher) release() {
+	ringHasherPool.Put(rh)
+}
+
+// add advances the chain by the commitments of one ring member, the
+// inverses of both z coordinates are found with a single inversion
+func (rh *ringHasher) add(a *CurvePoint, b *CurvePoint) {
+	rh.h.Reset()
+	rh.h.Write(rh.sum)
+
+	_, _, za := a.p.jacobian()
+	_, _, zb := b.p.jacobian()
+	if za.Sign() == 0 || zb.Sign() == 0 || isOne(za) || isOne(zb) {
+		rh.writePoint(a, 64)
+		rh.writePoint(b, 64)
+	} else {
+		// 1/z_a ← z_b/(z_a·z_b), 1/z_b ← z_a/(z_a·z_b)
+		P := rh.group.Prime()
+		rh.mod(&rh.inv, rh.t.Mul(za, zb))
+		rh.inv.ModInverse(&rh.inv, P)
+		rh.writeAffine(a, rh.mod(&rh.zInv, rh.t.Mul(&rh.inv, zb)), 64)
+		rh.writeAffine(b, rh.mod(&rh.zInv, rh.t.Mul(&rh.inv, za)), 64)
+	}
+
+	rh.sum = rh.h.Sum(rh.sum[:0])
+}
+
+// scalar returns the current hash as an integer modulo the group order
+func (rh *ringHasher) scalar() *big.Int {
+	x := new(big.Int).SetBytes(rh.sum)
+	return x.Mod(x, rh.group.Order())
+}
+
+// writePoint hashes the first length bytes of the affine encoding of p,
+// converting from Jacobian coordinates without modifying p. The point at
+// infinity is encoded as zeros.
+func (rh *ringHasher) writePoint(p *CurvePoint, length int) {
+	x, y, z := p.p.jacobian()
+
+	if z.Sign() == 0 {
+		for i := range rh.buf {
+			rh.buf[i] = 0
+		}
+		rh.h.Write(rh.buf[:length])
+		return
+	}
+
+	if isOne(z) {
+		copy(rh.buf[:32], paddedBigBytes(rh.mod(&rh.t, x), 32))
+		copy(rh.buf[32:], paddedBigBytes(rh.mod(&rh.t, y), 32))
+		rh.h.Write(rh.buf[:length])
+		return
+	}
+
+	rh.mod(&rh.zInv, z)
+	rh.zInv.ModInverse(&rh.zInv, rh.group.Prime())
+	rh.writeAffine(p, &rh.zInv, length)
+}
+
+// writeAffine hashes the encoding of p given the inverse of its z coordinate:
+//
+//   x ← x/z², y ← y/z³
+//
+func (rh *ringHasher) writeAffine(p *CurvePoint, zInv *big.Int, length int) {
+	x, y, _ := p.p.jacobian()
+
+	rh.mod(&rh.zInv2, rh.t.Mul(zInv, zInv))
+	copy(rh.buf[:32], paddedBigBytes(rh.mod(&rh.u, rh.t.Mul(x, &rh.zInv2)), 32))
+	rh.mod(&rh.u, rh.t.Mul(y, zInv))
+	copy(rh.buf[32:], paddedBigBytes(rh.mod(&rh.u, rh.t.Mul(&rh.u, &rh.zInv2)), 32))
+	rh.h.Write(rh.buf[:length])
+}
+
+// mod sets z to x modulo the prime of the field and returns z, the quotient
+// is kept so dividing does not allocate
+func (rh *ringHasher) mod(z *big.Int, x *big.Int) *big.Int {
+	P := rh.group.Prime()
+	rh.q.QuoRem(x, P, z)
+	if z.Sign() < 0 {
+		z.Add(z, P)
+	}
+	return z
+}
+
+// isOne reports whether x is exactly one, as z is for points in affine form
+func isOne(x *big.Int) bool {
+	words := x.Bits()
+	return x.Sign() > 0 && len(words) == 1 && words[0] == 1
+}
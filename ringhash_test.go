@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/clearmatics/bn256"
+)
+
+// marshalHashChain computes the hash chain by hashing the output of Marshal
+func marshalHashChain(hashp CurvePoint, tau CurvePoint, points []CurvePoint) [sha256.Size]byte {
+	hashAcc := sha256.Sum256(append(hashp.Marshal()[:32], tau.Marshal()...))
+	for j := 0; j+1 < len(points); j += 2 {
+		hashAcc = sha256.Sum256(append(hashAcc[:], append(points[j].Marshal(), points[j+1].Marshal()...)...))
+	}
+	return hashAcc
+}
+
+// jacobianCopies returns copies of the points which are not in affine
+// form, as Marshal converts the points it is called on
+func jacobianCopies(points []CurvePoint, copies []CurvePoint) {
+	for i, p := range points {
+		if copies[i].p == nil {
+			copies[i] = newBN256Point(new(bn256.G1))
+		}
+		copies[i].g1().Neg(p.g1())
+		copies[i].g1().Neg(copies[i].g1())
+	}
+}
+
+func TestRingHasher(t *testing.T) {
+	points, _ := randomPointsAndScalars(t, 10)
+
+	// A point in affine form, and one with a negative coordinate
+	points[3].Marshal()
+	points[4] = newBN256Point(new(bn256.G1).Neg(points[4].g1()))
+
+	copies := make([]CurvePoint, len(points))
+	jacobianCopies(points, copies)
+
+	hasher := newRingHasher(&copies[0], &copies[1])
+	defer hasher.release()
+	for j := 2; j+1 < len(copies); j += 2 {
+		hasher.add(&copies[j], &copies[j+1])
+	}
+
+	expected := marshalHashChain(points[0], points[1], points[2:])
+	if false == bytes.Equal(hasher.sum, expected[:]) {
+		t.Fatalf("Hash chain differs from Marshal: %x %x", hasher.sum, expected)
+	}
+
+	// The hasher leaves the points it hashes unchanged
+	for i := range copies {
+		_, _, z := copies[i].p.jacobian()
+		if i != 3 && z.Cmp(bigOne) == 0 {
+			t.Fatalf("Point %v was converted to affine form", i)
+		}
+	}
+}
+
+func TestRingHasherInfinity(t *testing.T) {
+	g := CurvePoint{}.ScalarBaseMult(bigOne)
+	inf := BN256.Infinity()
+
+	hasher := newRingHasher(&g, &g)
+	defer hasher.release()
+	hasher.add(&inf, &g)
+
+	zeros := make([]byte, 64)
+	h0 := sha256.Sum256(append(g.Marshal()[:32], g.Marshal()...))
+	expected := sha256.Sum256(append(append(h0[:], zeros...), g.Marshal()...))
+	if false == bytes.Equal(hasher.sum, expected[:]) {
+		t.Fatal("Point at infinity should be hashed as zeros")
+	}
+}
+
+// BenchmarkHashChain compares the hash chain of a ring of 64 members
+// computed with Marshal and with the reusable hasher, the points are copied
+// back to Jacobian form every iteration for both
+func BenchmarkHashChain(b *testing.B) {
+	points, _ := randomPointsAndScalars(b, 2+2*64)
+	copies := make([]CurvePoint, len(points))
+
+	b.Run("marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			jacobianCopies(points, copies)
+			marshalHashChain(copies[0], copies[1], copies[2:])
+		}
+	})
+	b.Run("hasher", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			jacobianCopies(points, copies)
+			hasher := newRingHasher(&copies[0], &copies[1])
+			for j := 2; j+1 < len(copies); j += 2 {
+				hasher.add(&copies[j], &copies[j+1])
+			}
+			hasher.release()
+		}
+	})
+}
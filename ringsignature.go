@@ -6,35 +6,33 @@ package main
 
 import (
 	"encoding/json"
-	"math/big"
+	"fmt"
 )
 
-// A RingSignature is represented as a curve point and the signature data itself
+// A RingSignature is represented as a curve point and the signature data
+// itself, scalars in the group of the point
 type RingSignature struct {
 	Tau    CurvePoint `json:"tau"`
-	Ctlist []*big.Int `json:"ctlist"`
+	Ctlist []Scalar   `json:"ctlist"`
 }
 
 // MarshalJSON converts a RingSignature to a JSON representation
 func (rs *RingSignature) MarshalJSON() ([]byte, error) {
-	// XXX: go has no easy way of doing this without iterating
-	ctlist := make([]*hexBig, len(rs.Ctlist))
-	for i, v := range rs.Ctlist {
-		ctlist[i] = (*hexBig)(v)
-	}
-
 	return json.Marshal(&struct {
+		Scheme string     `json:"scheme"`
 		Tau    CurvePoint `json:"tau"`
-		Ctlist []*hexBig  `json:"ctlist"`
+		Ctlist []Scalar   `json:"ctlist"`
 	}{
+		Scheme: SchemeCtlist,
 		Tau:    rs.Tau,
-		Ctlist: ctlist,
+		Ctlist: rs.Ctlist,
 	})
 }
 
 // UnmarshalJSON converts a JSON representation to a RingSignature struct
 func (rs *RingSignature) UnmarshalJSON(data []byte) error {
 	var aux struct {
+		Scheme string     `json:"scheme"`
 		Tau    CurvePoint `json:"tau"`
 		Ctlist []*hexBig  `json:"ctlist"`
 	}
@@ -43,9 +41,14 @@ func (rs *RingSignature) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	ctlist := make([]*big.Int, len(aux.Ctlist))
-	for i, v := range aux.Ctlist {
-		ctlist[i] = (*big.Int)(v)
+	// Signatures from before the scheme was recorded have no identifier
+	if aux.Scheme != "" && aux.Scheme != SchemeCtlist {
+		return fmt.Errorf("Unexpected signature scheme: %v", aux.Scheme)
+	}
+
+	ctlist, err := scalarsFromJSON(aux.Tau.Group(), aux.Ctlist)
+	if err != nil {
+		return fmt.Errorf("Invalid signature: %v", err)
 	}
 	rs.Ctlist = ctlist
 	rs.Tau = aux.Tau
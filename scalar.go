@@ -0,0 +1,199 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// A Scalar is an integer modulo the order of a group, such as a private key
+// or one of the values of a signature. Scalars are immutable and every
+// operation returns a new Scalar reduced modulo the order, so a reduction
+// can't be forgotten. The zero value is zero, it takes the group of the
+// scalar it is combined with and is otherwise in BN256.
+//
+type Scalar struct {
+	g Group
+	v *big.Int
+}
+
+// NewScalar returns x modulo the order of g
+func NewScalar(g Group, x *big.Int) Scalar {
+	return Scalar{g, new(big.Int).Mod(x, g.Order())}
+}
+
+// ParseScalar returns x as a scalar of g, unlike NewScalar it is an error
+// for x to be negative or not below the order
+func ParseScalar(g Group, x *big.Int) (Scalar, error) {
+	if x == nil || x.Sign() < 0 || x.Cmp(g.Order()) >= 0 {
+		return Scalar{}, fmt.Errorf("Scalar out of range for %v: %v", g.Name(), x)
+	}
+	return Scalar{g, new(big.Int).Set(x)}, nil
+}
+
+// DecodeScalar parses the canonical encoding of a scalar of g, see Bytes
+func DecodeScalar(g Group, b []byte) (Scalar, error) {
+	if len(b) != scalarSize(g) {
+		return Scalar{}, fmt.Errorf("Scalar encoding must be %v bytes, got %v", scalarSize(g), len(b))
+	}
+	return ParseScalar(g, new(big.Int).SetBytes(b))
+}
+
+// RandomScalar returns a uniformly random non-zero scalar of g
+func RandomScalar(g Group, random io.Reader) (Scalar, error) {
+	x, err := randomScalar(g, random)
+	if err != nil {
+		return Scalar{}, err
+	}
+	return Scalar{g, x}, nil
+}
+
+// scalarSize is the number of bytes in the encoding of a scalar of g
+func scalarSize(g Group) int {
+	return (g.Order().BitLen() + 7) / 8
+}
+
+// Group returns the group the scalar belongs to
+func (s Scalar) Group() Group {
+	if s.g == nil {
+		return BN256
+	}
+	return s.g
+}
+
+// value returns the value of s, which must not be modified
+func (s Scalar) value() *big.Int {
+	if s.v == nil {
+		return bigZero
+	}
+	return s.v
+}
+
+// with returns the group of an operation on s and t, it panics if they are
+// in different groups
+func (s Scalar) with(t Scalar) Group {
+	switch {
+	case s.g == nil:
+		return t.Group()
+	case t.g == nil || t.g == s.g:
+		return s.g
+	}
+	panic("Scalars of different groups")
+}
+
+// Int returns the value of the scalar, between 0 and the order
+func (s Scalar) Int() *big.Int {
+	return new(big.Int).Set(s.value())
+}
+
+// Bytes returns the canonical encoding of the scalar, a big-endian integer
+// with as many bytes as the order
+func (s Scalar) Bytes() []byte {
+	return paddedBigBytes(s.value(), scalarSize(s.Group()))
+}
+
+// IsZero returns true if the scalar is zero
+func (s Scalar) IsZero() bool {
+	return s.value().Sign() == 0
+}
+
+// Equal returns true if both scalars are in the same group and have the
+// same value, comparing their encodings in constant time
+func (s Scalar) Equal(t Scalar) bool {
+	if s.Group() != t.Group() {
+		return false
+	}
+	return subtle.ConstantTimeCompare(s.Bytes(), t.Bytes()) == 1
+}
+
+// Add returns s + t
+func (s Scalar) Add(t Scalar) Scalar {
+	return NewScalar(s.with(t), new(big.Int).Add(s.value(), t.value()))
+}
+
+// Sub returns s - t
+func (s Scalar) Sub(t Scalar) Scalar {
+	return NewScalar(s.with(t), new(big.Int).Sub(s.value(), t.value()))
+}
+
+// Mul returns s · t
+func (s Scalar) Mul(t Scalar) Scalar {
+	return NewScalar(s.with(t), new(big.Int).Mul(s.value(), t.value()))
+}
+
+// Neg returns -s
+func (s Scalar) Neg() Scalar {
+	return NewScalar(s.Group(), new(big.Int).Neg(s.value()))
+}
+
+// Inv returns the multiplicative inverse 1/s, the inverse of zero is zero
+func (s Scalar) Inv() Scalar {
+	g := s.Group()
+	if s.IsZero() {
+		return Scalar{g, new(big.Int)}
+	}
+	return Scalar{g, new(big.Int).ModInverse(s.value(), g.Order())}
+}
+
+func (s Scalar) String() string {
+	return fmt.Sprintf("0x%x", s.value())
+}
+
+// MarshalJSON encodes the scalar as a hexadecimal string, as hexBig
+func (s *Scalar) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes a hexadecimal or decimal scalar of BN256, it is an
+// error for it not to be below the order. Scalars of other groups must be
+// decoded by their containing type with scalarsFromJSON.
+func (s *Scalar) UnmarshalJSON(data []byte) error {
+	var v hexBig
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parsed, err := ParseScalar(BN256, (*big.Int)(&v))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// scalarsFromJSON converts integers decoded from JSON into scalars of g,
+// every value must be in range
+func scalarsFromJSON(g Group, values []*hexBig) ([]Scalar, error) {
+	out := make([]Scalar, len(values))
+	for i, v := range values {
+		s, err := ParseScalar(g, (*big.Int)(v))
+		if err != nil {
+			return nil, err
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+// scalarInts returns the values of the scalars
+func scalarInts(scalars []Scalar) []*big.Int {
+	out := make([]*big.Int, len(scalars))
+	for i, s := range scalars {
+		out[i] = s.Int()
+	}
+	return out
+}
+
+// newScalars converts integers into scalars of g, reducing each of them
+func newScalars(g Group, values []*big.Int) []Scalar {
+	out := make([]Scalar, len(values))
+	for i, v := range values {
+		out[i] = NewScalar(g, v)
+	}
+	return out
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestScalarArithmetic(t *testing.T) {
+	for _, g := range Curves {
+		a, err := RandomScalar(g, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := RandomScalar(g, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		N := g.Order()
+
+		sum := new(big.Int).Add(a.Int(), b.Int())
+		if a.Add(b).Int().Cmp(sum.Mod(sum, N)) != 0 {
+			t.Fatalf("%v: a + b is not reduced", g.Name())
+		}
+		if !a.Add(b).Sub(b).Equal(a) {
+			t.Fatalf("%v: a + b - b != a", g.Name())
+		}
+		if !a.Add(a.Neg()).IsZero() {
+			t.Fatalf("%v: a + -a != 0", g.Name())
+		}
+		if !a.Mul(b).Mul(b.Inv()).Equal(a) {
+			t.Fatalf("%v: a · b / b != a", g.Name())
+		}
+		if !(Scalar{}).Inv().IsZero() {
+			t.Fatalf("%v: the inverse of zero is not zero", g.Name())
+		}
+
+		// Operations return new scalars
+		before := a.Int()
+		a.Add(b)
+		a.Neg()
+		if a.Int().Cmp(before) != 0 {
+			t.Fatalf("%v: scalar modified by an operation", g.Name())
+		}
+		a.Int().SetInt64(1)
+		if a.Int().Cmp(before) != 0 {
+			t.Fatalf("%v: scalar modified through Int", g.Name())
+		}
+
+		if NewScalar(g, new(big.Int).Add(N, bigOne)).Int().Cmp(bigOne) != 0 {
+			t.Fatalf("%v: NewScalar does not reduce", g.Name())
+		}
+	}
+}
+
+func TestScalarEncoding(t *testing.T) {
+	for _, g := range Curves {
+		a, err := RandomScalar(g, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encoded := a.Bytes()
+		if len(encoded) != 32 {
+			t.Fatalf("%v: encoding is %v bytes", g.Name(), len(encoded))
+		}
+		decoded, err := DecodeScalar(g, encoded)
+		if err != nil || !decoded.Equal(a) {
+			t.Fatalf("%v: DecodeScalar does not invert Bytes", g.Name())
+		}
+
+		if _, err := DecodeScalar(g, encoded[1:]); err == nil {
+			t.Fatalf("%v: decoded a short encoding", g.Name())
+		}
+		if _, err := DecodeScalar(g, paddedBigBytes(g.Order(), 32)); err == nil {
+			t.Fatalf("%v: decoded the order", g.Name())
+		}
+		if _, err := ParseScalar(g, big.NewInt(-1)); err == nil {
+			t.Fatalf("%v: parsed a negative scalar", g.Name())
+		}
+	}
+
+	// The same value in different groups
+	if NewScalar(BN256, bigOne).Equal(NewScalar(Secp256k1, bigOne)) {
+		t.Fatal("Scalars of different groups are equal")
+	}
+}
+
+func TestScalarMixedGroups(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Adding scalars of different groups should panic")
+		}
+	}()
+	NewScalar(BN256, bigOne).Add(NewScalar(Secp256k1, bigOne))
+}
+
+func TestRingRejectsOutOfRangeScalars(t *testing.T) {
+	r := generateRing(2)
+	data, err := json.Marshal(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := r.PrivKeys[0].String()
+	order := "0x" + CurvePoint{}.Order().Text(16)
+	var loaded Ring
+	if err := json.Unmarshal([]byte(strings.Replace(string(data), key, order, 1)), &loaded); err == nil {
+		t.Fatal("Loaded a private key equal to the order")
+	}
+
+	sig, err := r.Signature(rand.Reader, r.PrivKeys[0], []byte("foobarbaz"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = json.Marshal(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := sig.Ctlist[1].String()
+	var loadedSig RingSignature
+	if err := json.Unmarshal([]byte(strings.Replace(string(data), value, order, 1)), &loadedSig); err == nil {
+		t.Fatal("Loaded a signature value equal to the order")
+	}
+}
+
+func TestPrivateStealthAddressJSON(t *testing.T) {
+	_, As, err := newKeyPair(Secp256k1, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Bp, _, err := newKeyPair(Secp256k1, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := NewStealthSessionV2(As, Bp, nil, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The private key is a decimal number, as it was before it was a Scalar
+	private := session.MyAddresses[0].Private.Int().String()
+	if false == strings.Contains(string(data), `"private":`+private) {
+		t.Fatalf("Private key not encoded as a number: %s", data)
+	}
+
+	var loaded StealthSession
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	address := loaded.MyAddresses[0]
+	if address.Private.Group() != Secp256k1 || !address.Private.Equal(session.MyAddresses[0].Private) {
+		t.Fatal("Private key differs after a JSON round trip")
+	}
+
+	// Keys must be below the order of the group of the address
+	order := Secp256k1.Order().String()
+	if err := json.Unmarshal([]byte(strings.Replace(string(data), private, order, 1)), &loaded); err == nil {
+		t.Fatal("Loaded a stealth private key equal to the order")
+	}
+}
+
+func TestScalarJSON(t *testing.T) {
+	a, err := RandomScalar(BN256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(&a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Scalar
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(a) {
+		t.Fatalf("Scalar differs after a JSON round trip: %s", data)
+	}
+
+	// Plain scalars are range checked in BN256
+	for _, value := range []string{`"0x` + BN256.Order().Text(16) + `"`, `"-0x1"`, `"foo"`} {
+		if err := json.Unmarshal([]byte(value), &loaded); err == nil {
+			t.Fatalf("Loaded %v as a scalar", value)
+		}
+	}
+}
+
+// checkJSONRoundTrip checks v encodes to the same JSON after it is decoded
+// into out
+func checkJSONRoundTrip(t *testing.T, name string, v interface{}, out interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("%v: %v", name, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		t.Fatalf("%v: %v: %s", name, err, data)
+	}
+	again, err := json.Marshal(out)
+	if err != nil {
+		t.Fatalf("%v: %v", name, err)
+	}
+	if false == bytes.Equal(data, again) {
+		t.Fatalf("%v differs after a JSON round trip:\n%s\n%s", name, data, again)
+	}
+}
+
+func TestScalarFieldsJSON(t *testing.T) {
+	message := []byte("foobarbaz")
+	for _, g := range Curves {
+		r := &Ring{Curve: g}
+		if err := r.Generate(rand.Reader, 2); err != nil {
+			t.Fatal(err)
+		}
+		checkJSONRoundTrip(t, g.Name()+" Ring", r, &Ring{})
+
+		sig, err := r.Signature(rand.Reader, r.PrivKeys[0], message, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkJSONRoundTrip(t, g.Name()+" RingSignature", sig, &RingSignature{})
+
+		session, commitment, err := NewBlindSignerSession(rand.Reader, r.PrivKeys[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkJSONRoundTrip(t, g.Name()+" BlindSignerSession", session, &BlindSignerSession{})
+
+		request, _, err := NewBlindRequest(rand.Reader, commitment, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkJSONRoundTrip(t, g.Name()+" BlindRequest", request, &BlindRequest{})
+
+		stealth, err := NewStealthSessionV2(r.PrivKeys[0].Int(), &r.PubKeys[1], nil, 0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkJSONRoundTrip(t, g.Name()+" PrivateStealthAddress", &stealth.MyAddresses[0], &PrivateStealthAddress{})
+	}
+
+	_, opening, err := NewPedersenCommitment(rand.Reader, big.NewInt(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkJSONRoundTrip(t, "PedersenOpening", opening, &PedersenOpening{})
+
+	proof, err := NewRangeProof(rand.Reader, big.NewInt(42), opening.Blinding, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkJSONRoundTrip(t, "RangeProof", proof, &RangeProof{})
+}
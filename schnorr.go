@@ -0,0 +1,141 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// schnorrLabel domain separates Schnorr challenges from other hashes
+var schnorrLabel = []byte("orbital-schnorr")
+
+// A SchnorrSignature is represented by the challenge and the response
+type SchnorrSignature struct {
+	C *big.Int `json:"c"`
+	S *big.Int `json:"s"`
+}
+
+// MarshalJSON converts a SchnorrSignature to a JSON representation
+func (sig *SchnorrSignature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		C *hexBig `json:"c"`
+		S *hexBig `json:"s"`
+	}{
+		C: (*hexBig)(sig.C),
+		S: (*hexBig)(sig.S),
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a SchnorrSignature struct
+func (sig *SchnorrSignature) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		C *hexBig `json:"c"`
+		S *hexBig `json:"s"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.C == nil || aux.S == nil {
+		return errors.New("Invalid signature, no c or s specified")
+	}
+
+	sig.C = (*big.Int)(aux.C)
+	sig.S = (*big.Int)(aux.S)
+	return nil
+}
+
+// hashToScalar hashes a domain separator and a list of length prefixed
+// values into an integer modulo the group order
+func hashToScalar(domain []byte, parts ...[]byte) *big.Int {
+	return hashToGroupScalar(BN256, domain, parts...)
+}
+
+// hashToGroupScalar is hashToScalar modulo the order of the group g
+func hashToGroupScalar(g Group, domain []byte, parts ...[]byte) *big.Int {
+	data := appendLengthPrefixed(nil, domain)
+	for _, part := range parts {
+		data = appendLengthPrefixed(data, part)
+	}
+
+	h := sha256.Sum256(data)
+	x := new(big.Int).SetBytes(h[:])
+	return x.Mod(x, g.Order())
+}
+
+// schnorrChallenge computes c ← H(R, y, m), the label domain separates the
+// challenges of different uses of the signature
+func schnorrChallenge(label []byte, R CurvePoint, pub *CurvePoint, message []byte) *big.Int {
+	return hashToGroupScalar(pub.Group(), label, R.Marshal(), pub.Marshal(), message)
+}
+
+// SchnorrSign signs a message with the secret key x:
+//
+//   k ← random
+//   c ← H(g^k, g^x, m)
+//   s ← k - c·x
+//
+func SchnorrSign(random io.Reader, priv *big.Int, message []byte) (*SchnorrSignature, error) {
+	if false == isValidSecretKey(priv) {
+		return nil, errors.New("Invalid secret key")
+	}
+	return schnorrSign(random, NewScalar(BN256, priv), schnorrLabel, message)
+}
+
+// schnorrSign signs a message with the secret key x of any group, with
+// challenges domain separated by the label
+func schnorrSign(random io.Reader, x Scalar, label []byte, message []byte) (*SchnorrSignature, error) {
+	g := x.Group()
+	if x.IsZero() {
+		return nil, errors.New("Invalid secret key")
+	}
+	N := g.Order()
+	priv := x.Int()
+
+	k, err := randomScalar(g, random)
+	if err != nil {
+		return nil, err
+	}
+
+	pub := g.ScalarBaseMult(priv)
+	c := schnorrChallenge(label, g.ScalarBaseMult(k), &pub, message)
+
+	s := new(big.Int).Mul(c, priv)
+	s.Sub(k, s)
+	s.Mod(s, N)
+
+	return &SchnorrSignature{c, s}, nil
+}
+
+// SchnorrVerify verifies a signature of a message by the public key y:
+//
+//   c = H(g^s · y^c, y, m)
+//
+func SchnorrVerify(pub *CurvePoint, message []byte, sig *SchnorrSignature) bool {
+	return schnorrVerify(pub, schnorrLabel, message, sig)
+}
+
+// schnorrVerify verifies a signature made by schnorrSign with the label, in
+// the group of the public key
+func schnorrVerify(pub *CurvePoint, label []byte, message []byte, sig *SchnorrSignature) bool {
+	if pub == nil || sig == nil || sig.C == nil || sig.S == nil {
+		return false
+	}
+	N := pub.Order()
+	if sig.C.Sign() < 0 || sig.C.Cmp(N) >= 0 || sig.S.Sign() < 0 || sig.S.Cmp(N) >= 0 {
+		return false
+	}
+	if false == isValidPublicKey(pub) {
+		return false
+	}
+
+	R := pub.ParameterPointAdd(sig.S, sig.C)
+	return schnorrChallenge(label, R, pub, message).Cmp(sig.C) == 0
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestSchnorrSignature(t *testing.T) {
+	pub, priv, err := generateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := SchnorrSign(rand.Reader, priv, testBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !SchnorrVerify(pub, testBytes, sig) {
+		t.Fatal("Valid signature not verified")
+	}
+
+	if SchnorrVerify(pub, []byte("badmessage"), sig) {
+		t.Fatal("Signature verified for wrong message")
+	}
+
+	other, _, _ := generateKeyPair(rand.Reader)
+	if SchnorrVerify(other, testBytes, sig) {
+		t.Fatal("Signature verified for wrong public key")
+	}
+
+	bad := &SchnorrSignature{sig.C, new(big.Int).Add(sig.S, bigOne)}
+	if SchnorrVerify(pub, testBytes, bad) {
+		t.Fatal("Altered signature verified")
+	}
+
+	unreduced := &SchnorrSignature{sig.C, new(big.Int).Add(sig.S, CurvePoint{}.Order())}
+	if SchnorrVerify(pub, testBytes, unreduced) {
+		t.Fatal("Unreduced signature verified")
+	}
+}
+
+func TestSchnorrSignatureJSON(t *testing.T) {
+	pub, priv, _ := generateKeyPair(rand.Reader)
+	sig, err := SchnorrSign(rand.Reader, priv, testBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded SchnorrSignature
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !SchnorrVerify(pub, testBytes, &decoded) {
+		t.Fatal("Signature not verified after JSON round trip")
+	}
+
+	if err := json.Unmarshal([]byte(`{"c":"0x1"}`), &decoded); err == nil {
+		t.Fatal("Accepted signature without s")
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// shamirSharePrefix identifies the encoding of a share, and is part of its checksum
+const shamirSharePrefix = "osh1"
+
+// shamirMaxShares is the number of shares which fit in the one byte index
+const shamirMaxShares = 255
+
+// A ShamirShare is a point on the polynomial a secret was split with,
+// Threshold of them are needed to recover the secret. Shares are modulo the
+// order of BN256, which their encoding doesn't record, so only BN256 keys
+// can be split.
+type ShamirShare struct {
+	Threshold int
+	Index     int
+	Value     *big.Int
+}
+
+// SecretShares are the shares of a secret and, optionally, the Feldman
+// commitments to the polynomial which shareholders can verify them with.
+type SecretShares struct {
+	Threshold   int           `json:"threshold"`
+	Commitments []CurvePoint  `json:"commitments,omitempty"`
+	Shares      []ShamirShare `json:"shares"`
+}
+
+// Encode returns the share as a hex string with a checksum, so mistakes
+// copying it are detected:
+//
+//   payload ← threshold | index | value
+//   "osh1" | hex(payload | SHA256("osh1" | payload)[:4])
+//
+func (s *ShamirShare) Encode() string {
+	payload := []byte{byte(s.Threshold), byte(s.Index)}
+	payload = append(payload, paddedBigBytes(s.Value, 32)...)
+	return shamirSharePrefix + hex.EncodeToString(append(payload, shamirChecksum(payload)...))
+}
+
+// DecodeShamirShare parses a share from the output of Encode, verifying its checksum
+func DecodeShamirShare(encoded string) (*ShamirShare, error) {
+	if false == strings.HasPrefix(encoded, shamirSharePrefix) {
+		return nil, errors.New("Invalid share, unknown prefix")
+	}
+
+	data, err := hex.DecodeString(strings.TrimPrefix(encoded, shamirSharePrefix))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid share: %v", err)
+	}
+	if len(data) != 2+32+4 {
+		return nil, errors.New("Invalid share, wrong length")
+	}
+
+	payload := data[:2+32]
+	if false == bytes.Equal(data[2+32:], shamirChecksum(payload)) {
+		return nil, errors.New("Invalid share, checksum mismatch")
+	}
+
+	share := &ShamirShare{
+		Threshold: int(payload[0]),
+		Index:     int(payload[1]),
+		Value:     new(big.Int).SetBytes(payload[2:]),
+	}
+	if share.Threshold < 1 || share.Index < 1 || share.Value.Cmp(CurvePoint{}.Order()) >= 0 {
+		return nil, errors.New("Invalid share, out of range")
+	}
+
+	return share, nil
+}
+
+// shamirChecksum returns the first 4 bytes of the hash of a share payload
+func shamirChecksum(payload []byte) []byte {
+	h := sha256.Sum256(append([]byte(shamirSharePrefix), payload...))
+	return h[:4]
+}
+
+// MarshalJSON converts a ShamirShare to its encoded string
+func (s *ShamirShare) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Encode())
+}
+
+// UnmarshalJSON converts an encoded string to a ShamirShare struct
+func (s *ShamirShare) UnmarshalJSON(data []byte) error {
+	var encoded string
+	err := json.Unmarshal(data, &encoded)
+	if err != nil {
+		return err
+	}
+
+	share, err := DecodeShamirShare(encoded)
+	if err != nil {
+		return err
+	}
+
+	*s = *share
+	return nil
+}
+
+// ShamirSplit splits a secret into n shares, any t of which recover it. The
+// shares are points on a random polynomial of degree t-1 with the secret as
+// its constant term, the Feldman commitments are to its coefficients:
+//
+//   f(z) ← secret + a_1·z + ... + a_{t-1}·z^{t-1}
+//   share_i ← f(i)
+//   C_k ← g^a_k
+//
+func ShamirSplit(random io.Reader, secret *big.Int, t int, n int, feldman bool) (*SecretShares, error) {
+	if secret == nil || secret.Sign() < 0 || secret.Cmp(CurvePoint{}.Order()) >= 0 {
+		return nil, errors.New("Secret must be a scalar below the group order")
+	}
+	if t < 1 || n < t || n > shamirMaxShares {
+		return nil, fmt.Errorf("Invalid threshold %v of %v", t, n)
+	}
+
+	coefficients, err := randomScalars(random, t-1)
+	if err != nil {
+		return nil, err
+	}
+	coefficients = append([]*big.Int{secret}, coefficients...)
+
+	split := &SecretShares{Threshold: t}
+	for i := 1; i <= n; i++ {
+		split.Shares = append(split.Shares, ShamirShare{
+			Threshold: t,
+			Index:     i,
+			Value:     evaluatePolynomial(coefficients, big.NewInt(int64(i))),
+		})
+	}
+
+	if feldman {
+		for _, a := range coefficients {
+			split.Commitments = append(split.Commitments, CurvePoint{}.ScalarBaseMult(a))
+		}
+	}
+
+	return split, nil
+}
+
+// VerifyShamirShare checks a share against the Feldman commitments to the
+// polynomial it was evaluated from:
+//
+//   g^share_i = ∏ C_k^(i^k)
+//
+func VerifyShamirShare(share *ShamirShare, commitments []CurvePoint) bool {
+	N := CurvePoint{}.Order()
+
+	if len(commitments) != share.Threshold {
+		return false
+	}
+
+	index := big.NewInt(int64(share.Index))
+	power := big.NewInt(1)
+	expected := commitments[0]
+	for _, C := range commitments[1:] {
+		power.Mul(power, index)
+		power.Mod(power, N)
+		expected = expected.Add(C.ScalarMult(power))
+	}
+
+	actual := CurvePoint{}.ScalarBaseMult(share.Value)
+	return actual.Equals(&expected)
+}
+
+// ShamirRecover interpolates the secret from at least threshold shares
+func ShamirRecover(shares []ShamirShare) (*big.Int, error) {
+	N := CurvePoint{}.Order()
+
+	if len(shares) == 0 {
+		return nil, errors.New("No shares")
+	}
+
+	threshold := shares[0].Threshold
+	seen := make(map[int]bool)
+	var indices []int
+	for _, share := range shares {
+		if share.Threshold != threshold {
+			return nil, errors.New("Shares are from different splits")
+		}
+		if seen[share.Index] {
+			return nil, fmt.Errorf("Duplicate share: %v", share.Index)
+		}
+		seen[share.Index] = true
+		indices = append(indices, share.Index)
+	}
+	if len(shares) < threshold {
+		return nil, fmt.Errorf("Need %v shares, only have %v", threshold, len(shares))
+	}
+
+	secret := big.NewInt(0)
+	for _, share := range shares {
+		term := lagrangeCoefficient(share.Index, indices)
+		term.Mul(term, share.Value)
+		secret.Add(secret, term)
+	}
+
+	return secret.Mod(secret, N), nil
+}
+
+// evaluatePolynomial evaluates the polynomial with the given coefficients,
+// lowest degree first, at z modulo the group order
+func evaluatePolynomial(coefficients []*big.Int, z *big.Int) *big.Int {
+	N := CurvePoint{}.Order()
+
+	y := big.NewInt(0)
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y.Mul(y, z)
+		y.Add(y, coefficients[i])
+		y.Mod(y, N)
+	}
+
+	return y
+}
+
+// lagrangeCoefficient returns the coefficient of the share at index i when
+// interpolating the polynomial at zero from the shares at indices:
+//
+//   λ_i ← ∏_{j≠i} j / (j - i)
+//
+func lagrangeCoefficient(i int, indices []int) *big.Int {
+	N := CurvePoint{}.Order()
+
+	num := big.NewInt(1)
+	den := big.NewInt(1)
+	for _, j := range indices {
+		if j == i {
+			continue
+		}
+		num.Mul(num, big.NewInt(int64(j)))
+		num.Mod(num, N)
+		den.Mul(den, big.NewInt(int64(j-i)))
+		den.Mod(den, N)
+	}
+
+	den.ModInverse(den, N)
+	return num.Mul(num, den).Mod(num, N)
+}
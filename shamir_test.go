@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestShamirRecover(t *testing.T) {
+	_, secret, err := generateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	split, err := ShamirSplit(rand.Reader, secret, 3, 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(split.Shares) != 5 || len(split.Commitments) != 0 {
+		t.Fatalf("Expected 5 shares and no commitments, got %v and %v", len(split.Shares), len(split.Commitments))
+	}
+
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 2, 3, 4}} {
+		var shares []ShamirShare
+		for _, i := range subset {
+			shares = append(shares, split.Shares[i])
+		}
+
+		recovered, err := ShamirRecover(shares)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recovered.Cmp(secret) != 0 {
+			t.Fatalf("Wrong secret recovered from shares %v", subset)
+		}
+	}
+
+	_, err = ShamirRecover(split.Shares[:2])
+	if err == nil {
+		t.Fatal("Should not recover from fewer shares than the threshold")
+	}
+
+	_, err = ShamirRecover([]ShamirShare{split.Shares[0], split.Shares[1], split.Shares[1]})
+	if err == nil {
+		t.Fatal("Should not recover from duplicate shares")
+	}
+}
+
+func TestShamirFeldman(t *testing.T) {
+	_, secret, err := generateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	split, err := ShamirSplit(rand.Reader, secret, 2, 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first commitment is the public key of the secret
+	public := derivePublicKey(secret)
+	if !split.Commitments[0].Equals(&public) {
+		t.Fatal("First commitment is not the public key")
+	}
+
+	for _, share := range split.Shares {
+		if !VerifyShamirShare(&share, split.Commitments) {
+			t.Fatalf("Share %v not verified", share.Index)
+		}
+	}
+
+	bad := split.Shares[0]
+	bad.Value = new(big.Int).Add(bad.Value, bigOne)
+	if VerifyShamirShare(&bad, split.Commitments) {
+		t.Fatal("Modified share should not verify")
+	}
+}
+
+func TestShamirShareEncoding(t *testing.T) {
+	_, secret, err := generateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	split, err := ShamirSplit(rand.Reader, secret, 2, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := split.Shares[1].Encode()
+	share, err := DecodeShamirShare(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if share.Index != 2 || share.Threshold != 2 || share.Value.Cmp(split.Shares[1].Value) != 0 {
+		t.Fatalf("Share changed after encoding: %v", encoded)
+	}
+
+	// A single mistyped character is caught by the checksum
+	typo := []byte(encoded)
+	if typo[10] == 'a' {
+		typo[10] = 'b'
+	} else {
+		typo[10] = 'a'
+	}
+	_, err = DecodeShamirShare(string(typo))
+	if err == nil {
+		t.Fatal("Should reject a share with a bad checksum")
+	}
+
+	data, err := json.Marshal(split)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded SecretShares
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Shares[0].Value.Cmp(split.Shares[0].Value) != 0 {
+		t.Fatalf("Shares changed after JSON round trip: %s", data)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+// precomputeThreshold is the number of signatures from which SignAll builds
+// the tables of multiples, below it they take longer to build than they save
+const precomputeThreshold = 16
+
+// A SigningContext holds everything about a ring and a message which does
+// not depend on the signer, so it is computed once however many signatures
+// are made: the message point H(m), its encoded x coordinate which starts
+// every hash chain, the commitment to the public keys of the ring which
+// deterministic signatures are seeded with and, after Precompute, tables
+// of the multiples of H(m) and of every public key.
+//
+// A context may be used by several goroutines at once, but Precompute must
+// be called before it is shared.
+//
+type SigningContext struct {
+	Ring    *Ring
+	Message []byte
+
+	// Workers is the number of signatures SignAll computes in parallel,
+	// zero for one per CPU
+	Workers int
+
+	group       Group
+	hashp       *CurvePoint
+	hashpX      []byte
+	hashpTable  *fixedBaseTable
+	pubTables   []*fixedBaseTable
+	tableOnce   sync.Once
+	ringHash    [sha256.Size]byte
+	messageHash [sha256.Size]byte
+}
+
+// NewSigningContext precomputes the signer independent values for signing
+// message with the ring
+func NewSigningContext(r *Ring, message []byte) *SigningContext {
+	g := r.group()
+	hashp := groupMessagePoint(g, message)
+
+	return &SigningContext{
+		Ring:        r,
+		Message:     message,
+		group:       g,
+		hashp:       hashp,
+		hashpX:      hashp.Marshal()[:32],
+		ringHash:    r.PublicKeysHashed(),
+		messageHash: sha256.Sum256(message),
+	}
+}
+
+// Precompute builds the tables of multiples of H(m) and of the public keys,
+// Workers at a time. Each table costs about as much as five scalar
+// multiplications to build, after which multiplying the point takes a
+// quarter of the time.
+func (ctx *SigningContext) Precompute() {
+	ctx.tableOnce.Do(func() {
+		pubKeys := ctx.Ring.PubKeys
+		tables := make([]*fixedBaseTable, len(pubKeys)+1)
+		ctx.parallel(len(tables), func(i int) {
+			if i == len(pubKeys) {
+				tables[i] = newFixedBaseTable(*ctx.hashp)
+			} else {
+				tables[i] = newFixedBaseTable(pubKeys[i])
+			}
+		})
+		ctx.pubTables = tables[:len(pubKeys)]
+		ctx.hashpTable = tables[len(pubKeys)]
+	})
+}
+
+// parallel calls fn for every index below count, Workers at a time
+func (ctx *SigningContext) parallel(count int, fn func(i int)) {
+	workers := ctx.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// RingHash returns the commitment to the public keys of the ring
+func (ctx *SigningContext) RingHash() [sha256.Size]byte {
+	return ctx.ringHash
+}
+
+// Sign generates a signature by the member of the ring at index signer
+func (ctx *SigningContext) Sign(random io.Reader, pk Scalar, signer int) (*RingSignature, error) {
+	return ctx.signature(pk, signer, func() (*big.Int, error) {
+		return randomScalar(ctx.group, random)
+	})
+}
+
+// DeterministicSign generates a signature with the randomness drawn from
+// an HMAC-DRBG, as Ring.DeterministicSignature
+func (ctx *SigningContext) DeterministicSign(pk Scalar, signer int, extra []byte) (*RingSignature, error) {
+	N := ctx.group.Order()
+
+	if _, err := ctx.privateKey(pk); err != nil {
+		return nil, err
+	}
+
+	seed := pk.Bytes()
+	seed = append(seed, ctx.messageHash[:]...)
+	seed = append(seed, ctx.ringHash[:]...)
+	seed = append(seed, indexBytes(signer)...)
+	seed = append(seed, extra...)
+
+	drbg := newHmacDRBG(seed)
+	return ctx.signature(pk, signer, func() (*big.Int, error) {
+		return drbg.scalarBelow(N), nil
+	})
+}
+
+// SignAll generates a signature with every private key of the ring, Workers
+// at a time. The randomness for all of the signatures is read before any
+// are computed, in the same order as signing them one after another, so
+// the output for a given source of randomness doesn't depend on Workers.
+func (ctx *SigningContext) SignAll(random io.Reader) ([]RingSignature, error) {
+	keys := ctx.Ring.PrivKeys
+	perSignature := 2*len(ctx.Ring.PubKeys) - 1
+
+	scalars := make([][]*big.Int, len(keys))
+	for i := range keys {
+		scalars[i] = make([]*big.Int, perSignature)
+		for k := range scalars[i] {
+			var err error
+			scalars[i][k], err = randomScalar(ctx.group, random)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(keys) >= precomputeThreshold {
+		ctx.Precompute()
+	}
+
+	signatures := make([]RingSignature, len(keys))
+	errs := make([]error, len(keys))
+	ctx.parallel(len(keys), func(i int) {
+		next := scalars[i]
+		sig, err := ctx.signature(keys[i], i, func() (*big.Int, error) {
+			x := next[0]
+			next = next[1:]
+			return x, nil
+		})
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		signatures[i] = *sig
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return signatures, nil
+}
+
+// Verify verifies a signature of the message by the ring
+func (ctx *SigningContext) Verify(sigma RingSignature) bool {
+	N := ctx.group.Order()
+
+	n := len(ctx.Ring.PubKeys)
+	if len(sigma.Ctlist) != 2*n {
+		return false
+	}
+	if sigma.Tau.p == nil || sigma.Tau.Group() != ctx.group {
+		return false
+	}
+
+	tau := sigma.Tau
+	hasher := newRingHasherX(ctx.hashpX, &tau)
+	defer hasher.release()
+
+	csum := big.NewInt(0)
+	for j := 0; j < n; j++ {
+		cj := new(big.Int).Mod(sigma.Ctlist[2*j].value(), N)
+		tj := new(big.Int).Mod(sigma.Ctlist[2*j+1].value(), N)
+
+		a := ctx.parameterPointAdd(j, tj, cj)
+		b := ctx.hashPointAdd(tau, tj, cj)
+		hasher.add(&a, &b)
+
+		csum.Add(csum, cj)
+	}
+
+	csum.Mod(csum, N)
+	return csum.Cmp(hasher.scalar()) == 0
+}
+
+// privateKey returns the value of the private key pk, which must be in the
+// group of the ring
+func (ctx *SigningContext) privateKey(pk Scalar) (*big.Int, error) {
+	if pk.Group() != ctx.group {
+		return nil, fmt.Errorf("Private key is not on the curve of the ring: %v", ctx.group.Name())
+	}
+	return pk.Int(), nil
+}
+
+// hashpMul returns H(m)·x, with the table of multiples once it is built
+func (ctx *SigningContext) hashpMul(x *big.Int) CurvePoint {
+	if ctx.hashpTable != nil {
+		return ctx.hashpTable.mul(x)
+	}
+	return ctx.hashp.ScalarMult(x)
+}
+
+// parameterPointAdd returns g·tj + P_j·cj, with the table of the public key
+// of member j once it is built
+func (ctx *SigningContext) parameterPointAdd(j int, tj *big.Int, cj *big.Int) CurvePoint {
+	if ctx.pubTables != nil {
+		a := ctx.group.ScalarBaseMult(tj)
+		return a.Add(ctx.pubTables[j].mul(cj))
+	}
+	return ctx.Ring.PubKeys[j].ParameterPointAdd(tj, cj)
+}
+
+// hashPointAdd returns H(m)·tj + tau·cj, with the table of H(m) once it is built
+func (ctx *SigningContext) hashPointAdd(tau CurvePoint, tj *big.Int, cj *big.Int) CurvePoint {
+	if ctx.hashpTable != nil {
+		b := ctx.hashpTable.mul(tj)
+		return b.Add(tau.ScalarMult(cj))
+	}
+	return ctx.hashp.HashPointAdd(tau, tj, cj)
+}
+
+// signature generates a signature with the scalars drawn from nextScalar
+func (ctx *SigningContext) signature(pk Scalar, signer int, nextScalar func() (*big.Int, error)) (*RingSignature, error) {
+	N := ctx.group.Order()
+	pubKeys := ctx.Ring.PubKeys
+	x, err := ctx.privateKey(pk)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(pubKeys)
+	if signer < 0 || signer >= n {
+		return nil, errors.New("Signer is not a member of the ring")
+	}
+
+	// Calculate Tau
+	tau := ctx.hashpMul(x)
+
+	// hashout = H(hash.X, tau)
+	hasher := newRingHasherX(ctx.hashpX, &tau)
+	defer hasher.release()
+
+	ctlist := make([]*big.Int, 2*n)
+	var a, b CurvePoint
+	var ri *big.Int
+
+	csum := big.NewInt(0)
+
+	for j := 0; j < n; j++ {
+		if j == signer {
+			var err error
+			ri, err = nextScalar()
+			if err != nil {
+				return nil, err
+			}
+			a = ctx.group.ScalarBaseMult(ri)
+			b = ctx.hashpMul(ri)
+		} else {
+			cj, err := nextScalar()
+			if err != nil {
+				return nil, err
+			}
+			tj, err := nextScalar()
+			if err != nil {
+				return nil, err
+			}
+
+			a = ctx.parameterPointAdd(j, tj, cj)
+			b = ctx.hashPointAdd(tau, tj, cj)
+			ctlist[2*j] = cj
+			ctlist[2*j+1] = tj
+			csum.Add(csum, cj)
+		}
+
+		hasher.add(&a, &b)
+	}
+
+	hashb := hasher.scalar()
+
+	csum.Mod(csum, N)
+	c := new(big.Int).Sub(hashb, csum)
+	c.Mod(c, N)
+
+	cx := new(big.Int).Mul(c, x)
+	cx.Mod(cx, N)
+	ti := new(big.Int).Sub(ri, cx)
+	ti.Mod(ti, N)
+	ctlist[2*signer] = c
+	ctlist[2*signer+1] = ti
+
+	return &RingSignature{tau, newScalars(ctx.group, ctlist)}, nil
+}
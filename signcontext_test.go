@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/rand"
+	"reflect"
+	"testing"
+)
+
+// signaturesHex returns the signatures as hex strings so they can be compared
+func signaturesHex(sigs []RingSignature) []string {
+	var out []string
+	for _, sig := range sigs {
+		out = append(out, sig.Tau.String())
+		for _, x := range sig.Ctlist {
+			out = append(out, x.Int().Text(16))
+		}
+	}
+	return out
+}
+
+func TestSigningContextSignAll(t *testing.T) {
+	r := generateRing(4)
+	message := []byte("foobarbaz")
+
+	// Signing one after another reads the randomness in the same order
+	random := newHmacDRBG([]byte("signing context"))
+	var expected []RingSignature
+	for i, pk := range r.PrivKeys {
+		sig, err := r.Signature(random, pk, message, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, *sig)
+	}
+
+	for _, workers := range []int{1, 3} {
+		ctx := NewSigningContext(&r, message)
+		ctx.Workers = workers
+		if workers > 1 {
+			ctx.Precompute()
+		}
+
+		sigs, err := ctx.SignAll(newHmacDRBG([]byte("signing context")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if false == reflect.DeepEqual(signaturesHex(sigs), signaturesHex(expected)) {
+			t.Fatalf("Signatures with %v workers differ from signing one at a time", workers)
+		}
+
+		for _, sig := range sigs {
+			if !ctx.Verify(sig) {
+				t.Fatalf("Signature with %v workers not verified", workers)
+			}
+		}
+	}
+}
+
+func TestSigningContextVerify(t *testing.T) {
+	r := generateRing(3)
+	message := []byte("foobarbaz")
+
+	ctx := NewSigningContext(&r, message)
+	sig, err := ctx.Sign(rand.Reader, r.PrivKeys[1], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ctx.Verify(*sig) {
+		t.Fatal("Signature not verified")
+	}
+
+	other := NewSigningContext(&r, []byte("badmessage"))
+	if other.Verify(*sig) {
+		t.Fatal("Signature verified for a different message")
+	}
+
+	short := RingSignature{sig.Tau, sig.Ctlist[:4]}
+	if ctx.Verify(short) {
+		t.Fatal("Signature with too few scalars verified")
+	}
+
+	if _, err := ctx.Sign(rand.Reader, r.PrivKeys[1], 3); err == nil {
+		t.Fatal("Should not sign for an index outside the ring")
+	}
+}
+
+func TestSigningContextDeterministic(t *testing.T) {
+	r := generateRing(3)
+	message := []byte("foobarbaz")
+
+	ctx := NewSigningContext(&r, message)
+	ctx.Precompute()
+	sig, err := ctx.DeterministicSign(r.PrivKeys[2], 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The tables give the same signature as signing without them
+	expected, err := r.DeterministicSignature(r.PrivKeys[2], message, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if false == reflect.DeepEqual(signaturesHex([]RingSignature{*sig}), signaturesHex([]RingSignature{*expected})) {
+		t.Fatal("Signature differs with precomputed tables")
+	}
+
+	if ctx.RingHash() != r.PublicKeysHashed() {
+		t.Fatal("Ring commitment differs")
+	}
+}
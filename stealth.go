@@ -6,10 +6,27 @@ package main
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
 )
 
+// Versions of the stealth key derivation
+const (
+	// StealthV1 hashes the shared secret concatenated with the minimal
+	// big-endian encoding of the nonce using a single SHA256
+	StealthV1 = 1
+
+	// StealthV2 uses HKDF-SHA256 with a fixed-width nonce, bound to a
+	// StealthContext
+	StealthV2 = 2
+)
+
+// stealthV2Label is the HKDF salt and domain separator for StealthV2
+var stealthV2Label = []byte("orbital-stealth-v2")
+
 // StealthAddress represents the stealth public key of another party
 type StealthAddress struct {
 	Public CurvePoint `json:"public"`
@@ -20,13 +37,54 @@ type StealthAddress struct {
 type PrivateStealthAddress struct {
 	Public  CurvePoint `json:"public"`
 	Nonce   *big.Int   `json:"nonce"`
-	Private *big.Int   `json:"private"`
+	Private Scalar     `json:"private"`
+}
+
+// privateStealthAddressJSON is the JSON encoding of a PrivateStealthAddress,
+// the private key is a decimal number as it has always been
+type privateStealthAddressJSON struct {
+	Public  *CurvePoint `json:"public"`
+	Nonce   *big.Int    `json:"nonce"`
+	Private *big.Int    `json:"private"`
+}
+
+// MarshalJSON converts the stealth address into JSON
+func (a *PrivateStealthAddress) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&privateStealthAddressJSON{&a.Public, a.Nonce, a.Private.Int()})
+}
+
+// UnmarshalJSON parses a stealth address, the private key must be a scalar
+// of the group of the public key
+func (a *PrivateStealthAddress) UnmarshalJSON(data []byte) error {
+	aux := privateStealthAddressJSON{Public: &a.Public}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	private, err := ParseScalar(a.Public.Group(), aux.Private)
+	if err != nil {
+		return fmt.Errorf("Invalid stealth private key: %v", err)
+	}
+	a.Nonce = aux.Nonce
+	a.Private = private
+	return nil
+}
+
+// StealthContext binds stealth addresses derived with StealthV2 to where
+// they are going to be used, the same context must be used by both parties.
+//
+type StealthContext struct {
+	Contract     []byte   `json:"contract"`
+	Denomination *big.Int `json:"denomination"`
+	Purpose      string   `json:"purpose"`
 }
 
 // StealthSession is used to communicate between two parties using
 // ephemeral key pairs for each message.
 //
 type StealthSession struct {
+	Version        int                     `json:"version"`
+	Context        *StealthContext         `json:"context,omitempty"`
 	MyPublic       CurvePoint              `json:"myPublic"`
 	TheirPublic    CurvePoint              `json:"theirPublic"`
 	SharedSecret   []byte                  `json:"sharedSecret"`
@@ -37,10 +95,17 @@ type StealthSession struct {
 // generateKeyPair generates a random secret key, then derives the
 // public key from it
 //
-func generateKeyPair() (*CurvePoint, *big.Int, error) {
-	priv := CurvePoint{}.RandomN()
-	// TODO: verify secret key?
-	pub := derivePublicKey(priv)
+func generateKeyPair(random io.Reader) (*CurvePoint, *big.Int, error) {
+	return newKeyPair(BN256, random)
+}
+
+// newKeyPair generates a random key pair in the group g
+func newKeyPair(g Group, random io.Reader) (*CurvePoint, *big.Int, error) {
+	priv, err := randomScalar(g, random)
+	if err != nil {
+		return nil, nil, err
+	}
+	pub := g.ScalarBaseMult(priv)
 	return &pub, priv, nil
 }
 
@@ -52,17 +117,7 @@ var bigTwo = new(big.Int).SetInt64(int64(2))
 // a valid curve point, where 0 < S < G
 //
 func isValidSecretKey(secret *big.Int) bool {
-	// secret < 1
-	if secret.Cmp(bigOne) < 0 {
-		return false
-	}
-
-	// secret >= G
-	if secret.Cmp(CurvePoint{}.Order()) >= 0 {
-		return false
-	}
-
-	return true
+	return isValidScalar(BN256, secret)
 }
 
 // StealthPubDerive derives another parties Stealth Public Key (ssp) from
@@ -78,16 +133,27 @@ func isValidSecretKey(secret *big.Int) bool {
 //   secret = arbitrary number known by both parties
 //
 func StealthPubDerive(mpk *CurvePoint, secret []byte) *CurvePoint {
-	if !mpk.IsOnCurve() {
-		return nil
-	}
-
 	// X ← H(secret)
 	_hashout := sha256.Sum256(secret)
 	X := new(big.Int).SetBytes(_hashout[:])
 
+	return stealthPubDeriveScalar(mpk, X)
+}
+
+// stealthPubDeriveScalar derives a Stealth Public Key from a Master
+// Public Key and an already hashed shared secret X:
+//
+//   spk ← mpk + g^X
+//
+// The stealth key is in the group of the master key.
+//
+func stealthPubDeriveScalar(mpk *CurvePoint, X *big.Int) *CurvePoint {
+	if false == isValidPublicKey(mpk) {
+		return nil
+	}
+
 	// Y ← g^X
-	Y := derivePublicKey(X)
+	Y := mpk.ScalarBaseMult(X)
 
 	// spk ← mpk + Y
 	spk := mpk.Add(Y)
@@ -108,25 +174,23 @@ func StealthPubDerive(mpk *CurvePoint, secret []byte) *CurvePoint {
 //   secret = arbitrary number known by both parties
 //
 func StealthPrivDerive(msk *big.Int, secret []byte) *big.Int {
-	if false == isValidSecretKey(msk) {
-		return nil
-	}
-
 	// X ← H(secret)
 	_hashout := sha256.Sum256(secret)
 	X := new(big.Int).SetBytes(_hashout[:])
 
-	// ssk ← msk + X
-	Y := new(big.Int).Add(msk, X)
-
-	// XXX: can (msk + X) exceed group.N?
-	ssk := new(big.Int).Mod(Y, CurvePoint{}.Order())
-	if !derivePublicKey(ssk).IsOnCurve() {
-		// TODO: return error?
+	if false == isValidScalar(BN256, msk) {
 		return nil
 	}
+	return stealthPrivDeriveScalar(NewScalar(BN256, msk), NewScalar(BN256, X)).Int()
+}
 
-	return ssk
+// stealthPrivDeriveScalar derives a Stealth Secret Key from a Master
+// Secret Key and an already hashed shared secret X, in the group of msk:
+//
+//   ssk ← msk + X
+//
+func stealthPrivDeriveScalar(msk Scalar, X Scalar) Scalar {
+	return msk.Add(X)
 }
 
 // derivePublicKey derives from SecretKey using ScalarBaseMult:
@@ -153,41 +217,141 @@ func deriveSharedSecret(myPriv *big.Int, theirPub *CurvePoint) []byte {
 	return theirPub.ScalarMult(myPriv).Marshal()[:32]
 }
 
+// encode serialises the context for use as HKDF info, variable length
+// fields are length prefixed and integers are fixed width so that no
+// two contexts share an encoding.
+//
+func (ctx *StealthContext) encode() []byte {
+	if ctx == nil {
+		ctx = &StealthContext{}
+	}
+
+	var out []byte
+	out = appendLengthPrefixed(out, ctx.Contract)
+	out = append(out, paddedBigBytes(ctx.Denomination, 32)...)
+	out = appendLengthPrefixed(out, []byte(ctx.Purpose))
+	return out
+}
+
+// appendLengthPrefixed appends data to out, prefixed by its length as a
+// 32bit big-endian integer
+func appendLengthPrefixed(out []byte, data []byte) []byte {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	out = append(out, length[:]...)
+	return append(out, data...)
+}
+
+// deriveStealthScalar derives the scalar X which offsets both master keys
+// of a stealth address, for the given derivation version:
+//
+//   v1:  X ← H(secret ‖ nonce)
+//   v2:  X ← HKDF(salt=label, IKM=secret, info=label ‖ ctx ‖ nonce₃₂) mod N
+//
+// Where N is the order of the group g.
+//
+func deriveStealthScalar(g Group, version int, sharedSecret []byte, nonce *big.Int, ctx *StealthContext) (*big.Int, error) {
+	switch version {
+	case StealthV1:
+		secret := append(append([]byte{}, sharedSecret...), nonce.Bytes()...)
+		_hashout := sha256.Sum256(secret)
+		return new(big.Int).SetBytes(_hashout[:]), nil
+
+	case StealthV2:
+		if nonce.Sign() < 0 || nonce.BitLen() > 256 {
+			return nil, fmt.Errorf("Nonce out of range: %v", nonce)
+		}
+
+		info := append(append([]byte{}, stealthV2Label...), ctx.encode()...)
+		info = append(info, paddedBigBytes(nonce, 32)...)
+
+		// 48 bytes are reduced modulo N to keep the bias negligible
+		okm, err := hkdf(stealthV2Label, sharedSecret, info, 48)
+		if err != nil {
+			return nil, err
+		}
+		X := new(big.Int).SetBytes(okm)
+		return X.Mod(X, g.Order()), nil
+	}
+
+	return nil, fmt.Errorf("Unknown stealth derivation version: %v", version)
+}
+
 // NewStealthSession derives all information necessary to communicate between
-// two parties using a series of one-time key pairs.
+// two parties using a series of one-time key pairs, with the original (v1)
+// key derivation.
 //
 func NewStealthSession(mySecret *big.Int, theirPublic *CurvePoint, nonceOffset int, addressCount int) (*StealthSession, error) {
+	return NewVersionedStealthSession(StealthV1, nil, mySecret, theirPublic, nonceOffset, addressCount)
+}
+
+// NewStealthSessionV2 derives a stealth session using the HKDF based (v2) key
+// derivation, all addresses are bound to the provided context.
+//
+func NewStealthSessionV2(mySecret *big.Int, theirPublic *CurvePoint, ctx *StealthContext, nonceOffset int, addressCount int) (*StealthSession, error) {
+	return NewVersionedStealthSession(StealthV2, ctx, mySecret, theirPublic, nonceOffset, addressCount)
+}
+
+// NewVersionedStealthSession derives a stealth session with the given key
+// derivation version, the context is ignored by StealthV1. The keys are in
+// the group of their public key.
+//
+func NewVersionedStealthSession(version int, ctx *StealthContext, mySecret *big.Int, theirPublic *CurvePoint, nonceOffset int, addressCount int) (*StealthSession, error) {
 	var theirAddresses []StealthAddress
 	var myAddresses []PrivateStealthAddress
 
-	if false == isValidSecretKey(mySecret) {
-		return nil, fmt.Errorf("Invalid secret key: %v", mySecret)
+	switch version {
+	case StealthV1:
+		ctx = nil
+	case StealthV2:
+		if ctx == nil {
+			ctx = &StealthContext{}
+		}
+		if d := ctx.Denomination; d != nil && (d.Sign() < 0 || d.BitLen() > 256) {
+			return nil, fmt.Errorf("Denomination out of range: %v", d)
+		}
+	default:
+		return nil, fmt.Errorf("Unknown stealth derivation version: %v", version)
 	}
 
 	if nil == theirPublic {
 		return nil, fmt.Errorf("Null public key provided")
 	}
+	if false == isValidPublicKey(theirPublic) {
+		return nil, fmt.Errorf("Invalid public key provided")
+	}
 
-	sharedSecret := deriveSharedSecret(mySecret, theirPublic)
+	g := theirPublic.Group()
+	msk, err := ParseScalar(g, mySecret)
+	if err != nil || msk.IsZero() {
+		return nil, fmt.Errorf("Invalid secret key: %v", mySecret)
+	}
+
+	sharedSecret := deriveSharedSecret(msk.value(), theirPublic)
 	for i := 0; i < addressCount; i++ {
 		nonce := new(big.Int).SetInt64(int64(nonceOffset + i))
-		secret := append(sharedSecret, nonce.Bytes()...)
+		X, err := deriveStealthScalar(g, version, sharedSecret, nonce, ctx)
+		if err != nil {
+			return nil, err
+		}
 
-		theirStealthPub := StealthPubDerive(theirPublic, secret)
+		theirStealthPub := stealthPubDeriveScalar(theirPublic, X)
 		if theirStealthPub == nil {
 			return nil, fmt.Errorf("Could not derive stealth public key %v", i)
 		}
 		theirSA := StealthAddress{*theirStealthPub, nonce}
 		theirAddresses = append(theirAddresses, theirSA)
 
-		myStealthPriv := StealthPrivDerive(mySecret, secret)
-		myStealthPub := derivePublicKey(myStealthPriv)
+		myStealthPriv := stealthPrivDeriveScalar(msk, NewScalar(g, X))
+		myStealthPub := g.ScalarBaseMult(myStealthPriv.value())
 		mySA := PrivateStealthAddress{myStealthPub, nonce, myStealthPriv}
 		myAddresses = append(myAddresses, mySA)
 	}
 
 	session := StealthSession{
-		MyPublic:       derivePublicKey(mySecret),
+		Version:        version,
+		Context:        ctx,
+		MyPublic:       g.ScalarBaseMult(msk.value()),
 		TheirPublic:    *theirPublic,
 		SharedSecret:   sharedSecret,
 		TheirAddresses: theirAddresses,
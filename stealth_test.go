@@ -6,6 +6,7 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
 	"math/big"
 	"testing"
 )
@@ -21,11 +22,11 @@ func TestValidSecret(t *testing.T) {
 }
 
 func generatePairOfTestKeys(t *testing.T) (*CurvePoint, *big.Int, *CurvePoint, *big.Int) {
-	Ap, As, err := generateKeyPair()
+	Ap, As, err := generateKeyPair(rand.Reader)
 	if err != nil {
 		t.Fatal(err)
 	}
-	Bp, Bs, err := generateKeyPair()
+	Bp, Bs, err := generateKeyPair(rand.Reader)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -168,3 +169,94 @@ func TestStealthInvalidPublic(t *testing.T) {
 		}
 	}
 }
+
+func TestStealthAddressSessionV2(t *testing.T) {
+	Ap, As, Bp, Bs := generatePairOfTestKeys(t)
+	ctx := &StealthContext{
+		Contract:     []byte{0xde, 0xad, 0xbe, 0xef},
+		Denomination: big.NewInt(1000),
+		Purpose:      "deposit",
+	}
+
+	sessA, err := NewStealthSessionV2(As, Bp, ctx, 0, 2)
+	if err != nil {
+		t.Fatal("sessA invalid", err)
+	}
+	sessB, err := NewStealthSessionV2(Bs, Ap, ctx, 0, 2)
+	if err != nil {
+		t.Fatal("sessB invalid", err)
+	}
+	if sessA.Version != StealthV2 {
+		t.Fatalf("Expected version %v but got %v", StealthV2, sessA.Version)
+	}
+
+	for i := 0; i < 2; i++ {
+		if !sessA.MyAddresses[i].Public.Equals(&sessB.TheirAddresses[i].Public) {
+			t.Fatal("Public Key Mismatch, A.MyA[i].P != B.TheirA[i].P", i)
+		}
+		if !sessB.MyAddresses[i].Public.Equals(&sessA.TheirAddresses[i].Public) {
+			t.Fatal("Public Key Mismatch, B.MyA[i].P != A.TheirA[i].P", i)
+		}
+	}
+
+	// v1 and v2 must derive different addresses from the same keys
+	sessV1, err := NewStealthSession(As, Bp, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessV1.TheirAddresses[0].Public.Equals(&sessA.TheirAddresses[0].Public) {
+		t.Fatal("v1 and v2 derived the same stealth address")
+	}
+}
+
+func TestStealthV2ContextSeparation(t *testing.T) {
+	_, As, Bp, _ := generatePairOfTestKeys(t)
+	contexts := []*StealthContext{
+		{Purpose: "deposit"},
+		{Purpose: "withdraw"},
+		{Denomination: big.NewInt(1)},
+		{Contract: []byte{1}},
+		// Length prefixing prevents fields from bleeding into each other
+		{Contract: []byte("a"), Purpose: "bc"},
+		{Contract: []byte("ab"), Purpose: "c"},
+	}
+
+	var addresses []CurvePoint
+	for _, ctx := range contexts {
+		sess, err := NewStealthSessionV2(As, Bp, ctx, 0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, other := range addresses {
+			if other.Equals(&sess.TheirAddresses[0].Public) {
+				t.Fatal("Different contexts derived the same stealth address", ctx)
+			}
+		}
+		addresses = append(addresses, sess.TheirAddresses[0].Public)
+	}
+}
+
+func TestStealthV2NonceEncoding(t *testing.T) {
+	secret := []byte("shared secret")
+
+	// With v1 the nonce 0 encodes to nothing, so it cannot be told apart
+	// from the nonce 1 appended to a secret without its final 0x01 byte
+	a, _ := deriveStealthScalar(BN256, StealthV1, append(secret, 1), bigZero, nil)
+	b, _ := deriveStealthScalar(BN256, StealthV1, secret, bigOne, nil)
+	if a.Cmp(b) != 0 {
+		t.Fatal("Expected ambiguous v1 nonce encoding")
+	}
+
+	a, _ = deriveStealthScalar(BN256, StealthV2, append(secret, 1), bigZero, nil)
+	b, _ = deriveStealthScalar(BN256, StealthV2, secret, bigOne, nil)
+	if a.Cmp(b) == 0 {
+		t.Fatal("v2 nonce encoding is ambiguous")
+	}
+
+	if _, err := deriveStealthScalar(BN256, StealthV2, secret, big.NewInt(-1), nil); err == nil {
+		t.Fatal("Negative nonce accepted")
+	}
+	if _, err := deriveStealthScalar(BN256, 3, secret, bigZero, nil); err == nil {
+		t.Fatal("Unknown version accepted")
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"path/filepath"
+)
+
+// A StealthContact is a recipient of stealth payments, Nonce is the next
+// unused nonce for that recipient so each has its own sequence.
+type StealthContact struct {
+	Name   string     `json:"name"`
+	Public CurvePoint `json:"public"`
+	Nonce  int        `json:"nonce"`
+}
+
+// A StealthNotice is sent encrypted to a recipient, it contains everything
+// they need to derive the secret key of the stealth address they were paid.
+type StealthNotice struct {
+	Version      int             `json:"version"`
+	Context      *StealthContext `json:"context,omitempty"`
+	SenderPublic CurvePoint      `json:"senderPublic"`
+	Nonce        *big.Int        `json:"nonce"`
+	Address      CurvePoint      `json:"address"`
+}
+
+// A StealthPayment is the stealth address derived for a single recipient
+// and the notice encrypted to their master public key.
+type StealthPayment struct {
+	Name        string            `json:"name"`
+	TheirPublic CurvePoint        `json:"theirPublic"`
+	Address     StealthAddress    `json:"address"`
+	Notice      *EncryptedMessage `json:"notice"`
+}
+
+// A StealthBatch is the manifest of stealth payments to many recipients
+type StealthBatch struct {
+	Version  int              `json:"version"`
+	Context  *StealthContext  `json:"context,omitempty"`
+	MyPublic CurvePoint       `json:"myPublic"`
+	Payments []StealthPayment `json:"payments"`
+}
+
+// NewStealthBatch derives a stealth address for each contact, starting at
+// the contact's own nonce, and encrypts a notice of the payment to them.
+//
+func NewStealthBatch(random io.Reader, version int, ctx *StealthContext, mySecret *big.Int, contacts []StealthContact) (*StealthBatch, error) {
+	if false == isValidSecretKey(mySecret) {
+		return nil, fmt.Errorf("Invalid secret key: %v", mySecret)
+	}
+
+	switch version {
+	case StealthV1:
+		ctx = nil
+	case StealthV2:
+		if ctx == nil {
+			ctx = &StealthContext{}
+		}
+	default:
+		return nil, fmt.Errorf("Unknown stealth derivation version: %v", version)
+	}
+
+	batch := StealthBatch{
+		Version:  version,
+		Context:  ctx,
+		MyPublic: derivePublicKey(mySecret),
+	}
+
+	names := make(map[string]bool)
+	for _, contact := range contacts {
+		if names[contact.Name] {
+			return nil, fmt.Errorf("Duplicate contact: %v", contact.Name)
+		}
+		names[contact.Name] = true
+
+		if contact.Nonce < 0 {
+			return nil, fmt.Errorf("Invalid nonce for contact %v: %v", contact.Name, contact.Nonce)
+		}
+
+		if contact.Public.p == nil {
+			return nil, fmt.Errorf("No public key for contact %v", contact.Name)
+		}
+
+		if contact.Public.Group() != BN256 {
+			return nil, fmt.Errorf("Public key for contact %v is not on %v", contact.Name, DefaultCurve)
+		}
+
+		session, err := NewVersionedStealthSession(version, ctx, mySecret, &contact.Public, contact.Nonce, 1)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive stealth address for %v: %v", contact.Name, err)
+		}
+
+		address := session.TheirAddresses[0]
+		notice, err := json.Marshal(&StealthNotice{
+			Version:      session.Version,
+			Context:      session.Context,
+			SenderPublic: batch.MyPublic,
+			Nonce:        address.Nonce,
+			Address:      address.Public,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encrypted, err := ECIESEncrypt(random, &contact.Public, notice)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encrypt notice for %v: %v", contact.Name, err)
+		}
+
+		batch.Payments = append(batch.Payments, StealthPayment{
+			Name:        contact.Name,
+			TheirPublic: contact.Public,
+			Address:     address,
+			Notice:      encrypted,
+		})
+	}
+
+	return &batch, nil
+}
+
+// NoticeFiles returns the name of the file each payment's notice is written
+// to, the base of the contact's name. Names which would write outside the
+// directory, or two notices to the same file, are an error.
+func (b *StealthBatch) NoticeFiles() ([]string, error) {
+	files := make([]string, len(b.Payments))
+	seen := make(map[string]string, len(b.Payments))
+	for i, payment := range b.Payments {
+		base := filepath.Base(payment.Name)
+		if base == "." || base == ".." || base == string(filepath.Separator) {
+			return nil, fmt.Errorf("Contact %q can't be used as a file name", payment.Name)
+		}
+		if other, ok := seen[base]; ok {
+			return nil, fmt.Errorf("Contacts %q and %q would both write the notice %v.json", other, payment.Name, base)
+		}
+		seen[base] = payment.Name
+		files[i] = base + ".json"
+	}
+	return files, nil
+}
+
+// OpenStealthNotice decrypts a notice with the recipient's master secret
+// key and derives the secret key for the stealth address it refers to.
+//
+func OpenStealthNotice(mySecret *big.Int, msg *EncryptedMessage) (*StealthNotice, *PrivateStealthAddress, error) {
+	plaintext, err := ECIESDecrypt(mySecret, msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var notice StealthNotice
+	if err := json.Unmarshal(plaintext, &notice); err != nil {
+		return nil, nil, err
+	}
+
+	if false == isValidPublicKey(&notice.SenderPublic) {
+		return nil, nil, fmt.Errorf("Invalid sender public key in notice")
+	}
+
+	if notice.Nonce == nil || notice.Nonce.Sign() < 0 || notice.Nonce.BitLen() > 31 {
+		return nil, nil, fmt.Errorf("Invalid nonce in notice: %v", notice.Nonce)
+	}
+
+	session, err := NewVersionedStealthSession(notice.Version, notice.Context, mySecret, &notice.SenderPublic, int(notice.Nonce.Int64()), 1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	address := session.MyAddresses[0]
+	if !address.Public.Equals(&notice.Address) {
+		return nil, nil, fmt.Errorf("Notice address does not match derived stealth address")
+	}
+
+	return &notice, &address, nil
+}
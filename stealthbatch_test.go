@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func generateTestContacts(t *testing.T, n int) ([]StealthContact, []*big.Int) {
+	var contacts []StealthContact
+	var secrets []*big.Int
+	for i := 0; i < n; i++ {
+		pub, priv, err := generateKeyPair(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contacts = append(contacts, StealthContact{fmt.Sprintf("contact%v", i), *pub, i * 10})
+		secrets = append(secrets, priv)
+	}
+	return contacts, secrets
+}
+
+func TestStealthBatch(t *testing.T) {
+	_, mySecret, err := generateKeyPair(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contacts, secrets := generateTestContacts(t, 3)
+	ctx := &StealthContext{Denomination: big.NewInt(1000), Purpose: "payroll"}
+
+	batch, err := NewStealthBatch(rand.Reader, StealthV2, ctx, mySecret, contacts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := len(contacts)
+	actual := len(batch.Payments)
+	if actual != expected {
+		t.Fatalf("Expected %v but got %v", expected, actual)
+	}
+
+	for i, payment := range batch.Payments {
+		if payment.Address.Nonce.Int64() != int64(contacts[i].Nonce) {
+			t.Errorf("Expected nonce %v but got %v", contacts[i].Nonce, payment.Address.Nonce)
+		}
+
+		// Each recipient can recover the secret key for their address
+		notice, address, err := OpenStealthNotice(secrets[i], payment.Notice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !notice.SenderPublic.Equals(&batch.MyPublic) {
+			t.Error("Notice has the wrong sender")
+		}
+		if !address.Public.Equals(&payment.Address.Public) {
+			t.Error("Recovered stealth address doesn't match the batch")
+		}
+		pub := derivePublicKey(address.Private.Int())
+		if !pub.Equals(&payment.Address.Public) {
+			t.Error("Recovered stealth secret key doesn't match the address")
+		}
+
+		// But no-one else can read it
+		other := secrets[(i+1)%len(secrets)]
+		if _, _, err := OpenStealthNotice(other, payment.Notice); err == nil {
+			t.Error("Notice opened by another recipient")
+		}
+	}
+}
+
+func TestStealthBatchV1(t *testing.T) {
+	_, mySecret, _ := generateKeyPair(rand.Reader)
+	contacts, secrets := generateTestContacts(t, 2)
+
+	batch, err := NewStealthBatch(rand.Reader, StealthV1, nil, mySecret, contacts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Matches a session derived between the two parties directly
+	sess, err := NewStealthSession(secrets[1], &batch.MyPublic, contacts[1].Nonce, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sess.MyAddresses[0].Public.Equals(&batch.Payments[1].Address.Public) {
+		t.Fatal("Batch address doesn't match stealth session")
+	}
+}
+
+func TestStealthBatchInvalid(t *testing.T) {
+	_, mySecret, _ := generateKeyPair(rand.Reader)
+	contacts, _ := generateTestContacts(t, 2)
+
+	duplicate := append(contacts, contacts[0])
+	if _, err := NewStealthBatch(rand.Reader, StealthV2, nil, mySecret, duplicate); err == nil {
+		t.Fatal("Accepted duplicate contacts")
+	}
+
+	contacts[1].Nonce = -1
+	if _, err := NewStealthBatch(rand.Reader, StealthV2, nil, mySecret, contacts); err == nil {
+		t.Fatal("Accepted negative nonce")
+	}
+
+	if _, err := NewStealthBatch(rand.Reader, 3, nil, mySecret, nil); err == nil {
+		t.Fatal("Accepted unknown version")
+	}
+}
+
+func TestStealthBatchNoticeFiles(t *testing.T) {
+	_, mySecret, _ := generateKeyPair(rand.Reader)
+	contacts, _ := generateTestContacts(t, 3)
+	contacts[0].Name = "a/bob"
+	contacts[1].Name = "alice"
+
+	batch, err := NewStealthBatch(rand.Reader, StealthV2, nil, mySecret, contacts[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := batch.NoticeFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files[0] != "bob.json" || files[1] != "alice.json" {
+		t.Fatalf("Unexpected notice files: %v", files)
+	}
+
+	// Distinct contacts whose names have the same base
+	contacts[1].Name = "c/bob"
+	batch, err = NewStealthBatch(rand.Reader, StealthV2, nil, mySecret, contacts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := batch.NoticeFiles(); err == nil {
+		t.Fatal("Accepted two notices written to the same file")
+	}
+
+	for _, name := range []string{"", "..", "/"} {
+		contacts[1].Name = name
+		batch, err = NewStealthBatch(rand.Reader, StealthV2, nil, mySecret, contacts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := batch.NoticeFiles(); err == nil {
+			t.Fatalf("Accepted contact %q as a file name", name)
+		}
+	}
+}
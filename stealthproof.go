@@ -0,0 +1,114 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"errors"
+	"io"
+	"math/big"
+)
+
+// stealthProofLabel domain separates stealth derivation proofs
+var stealthProofLabel = []byte("orbital-stealth-proof-v1")
+
+// A StealthProof shows that a stealth address is offset from a master
+// public key by a known amount, without revealing either secret key:
+//
+//   spk = mpk + g^X
+//
+// The proof is a Schnorr signature by X, the discrete log of spk - mpk, and
+// only shows knowledge of X. Both parties of a stealth session can derive X
+// from their shared secret, so the sender of a payment can make the proof
+// too, and it doesn't show who controls the secret key of the address.
+//
+type StealthProof struct {
+	MasterPublic CurvePoint        `json:"masterPublic"`
+	Address      StealthAddress    `json:"address"`
+	Context      []byte            `json:"context"`
+	Proof        *SchnorrSignature `json:"proof"`
+}
+
+// stealthOffset returns spk - mpk, which is g^X for a valid derivation
+func stealthOffset(mpk *CurvePoint, spk *CurvePoint) CurvePoint {
+	return spk.Sub(*mpk)
+}
+
+// stealthProofMessage binds the proof to both keys, the nonce and an
+// arbitrary context such as a case reference
+func stealthProofMessage(mpk *CurvePoint, address *StealthAddress, context []byte) []byte {
+	var nonce []byte
+	if address.Nonce != nil {
+		nonce = address.Nonce.Bytes()
+	}
+
+	data := appendLengthPrefixed(nil, stealthProofLabel)
+	data = appendLengthPrefixed(data, mpk.Marshal())
+	data = appendLengthPrefixed(data, address.Public.Marshal())
+	data = appendLengthPrefixed(data, nonce)
+	return appendLengthPrefixed(data, context)
+}
+
+// NewStealthProof proves knowledge of the offset of the stealth address
+// from the master public key of msk, the context is included in the proof.
+//
+func NewStealthProof(random io.Reader, msk *big.Int, address *PrivateStealthAddress, context []byte) (*StealthProof, error) {
+	if false == isValidSecretKey(msk) {
+		return nil, errors.New("Invalid master secret key")
+	}
+
+	if address == nil || address.Private.IsZero() {
+		return nil, errors.New("No stealth secret key provided")
+	}
+
+	// X ← ssk - msk
+	N := CurvePoint{}.Order()
+	X := new(big.Int).Sub(address.Private.value(), msk)
+	X.Mod(X, N)
+
+	mpk := derivePublicKey(msk)
+	spk := derivePublicKey(address.Private.Int())
+	if !spk.Equals(&address.Public) {
+		return nil, errors.New("Stealth secret key does not match the address")
+	}
+
+	public := StealthAddress{spk, address.Nonce}
+	sig, err := SchnorrSign(random, X, stealthProofMessage(&mpk, &public, context))
+	if err != nil {
+		return nil, err
+	}
+
+	return &StealthProof{
+		MasterPublic: mpk,
+		Address:      public,
+		Context:      context,
+		Proof:        sig,
+	}, nil
+}
+
+// Verify checks the prover knew the offset of the stealth address from the
+// master public key
+func (p *StealthProof) Verify() bool {
+	if p.MasterPublic.p == nil || p.Address.Public.p == nil {
+		return false
+	}
+
+	// The proof is a Schnorr signature, which is only implemented for BN256
+	if p.MasterPublic.Group() != BN256 || p.Address.Public.Group() != BN256 {
+		return false
+	}
+
+	if false == isValidPublicKey(&p.MasterPublic) || false == isValidPublicKey(&p.Address.Public) {
+		return false
+	}
+
+	// X = 0 would make the offset the point at infinity
+	if p.MasterPublic.Equals(&p.Address.Public) {
+		return false
+	}
+
+	offset := stealthOffset(&p.MasterPublic, &p.Address.Public)
+	message := stealthProofMessage(&p.MasterPublic, &p.Address, p.Context)
+	return SchnorrVerify(&offset, message, p.Proof)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestStealthProof(t *testing.T) {
+	Ap, As, Bp, Bs := generatePairOfTestKeys(t)
+	sessB, err := NewStealthSessionV2(Bs, Ap, nil, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := []byte("case 1234")
+
+	proof, err := NewStealthProof(rand.Reader, Bs, &sessB.MyAddresses[0], context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proof.Verify() {
+		t.Fatal("Valid proof not verified")
+	}
+
+	// The proof refers to the address the other party derived for B
+	sessA, _ := NewStealthSessionV2(As, Bp, nil, 3, 1)
+	if !proof.Address.Public.Equals(&sessA.TheirAddresses[0].Public) || !proof.MasterPublic.Equals(Bp) {
+		t.Fatal("Proof is for the wrong keys")
+	}
+
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded StealthProof
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Verify() {
+		t.Fatal("Proof not verified after JSON round trip")
+	}
+
+	decoded.Context = []byte("case 1235")
+	if decoded.Verify() {
+		t.Fatal("Proof verified with a different context")
+	}
+}
+
+func TestStealthProofWrongKey(t *testing.T) {
+	Ap, _, _, Bs := generatePairOfTestKeys(t)
+	sessB, _ := NewStealthSessionV2(Bs, Ap, nil, 0, 1)
+
+	// The stealth secret key must match the address
+	address := sessB.MyAddresses[0]
+	address.Public = *Ap
+	if _, err := NewStealthProof(rand.Reader, Bs, &address, nil); err == nil {
+		t.Fatal("Proof generated for mismatched stealth address")
+	}
+
+	proof, err := NewStealthProof(rand.Reader, Bs, &sessB.MyAddresses[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proof.MasterPublic = *Ap
+	if proof.Verify() {
+		t.Fatal("Proof verified for the wrong master public key")
+	}
+
+	proof.MasterPublic = proof.Address.Public
+	if proof.Verify() {
+		t.Fatal("Proof verified for identical master and stealth keys")
+	}
+}
+
+func TestStealthProofNonce(t *testing.T) {
+	Ap, _, _, Bs := generatePairOfTestKeys(t)
+	sessB, _ := NewStealthSessionV2(Bs, Ap, nil, 0, 1)
+
+	proof, err := NewStealthProof(rand.Reader, Bs, &sessB.MyAddresses[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proof.Address.Nonce = big.NewInt(1)
+	if proof.Verify() {
+		t.Fatal("Proof verified with a different nonce")
+	}
+}
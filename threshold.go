@@ -0,0 +1,444 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+)
+
+// thresholdLabel domain separates the hashes of the threshold signing protocol
+var thresholdLabel = []byte("orbital-threshold")
+
+// A ThresholdShare is one participant's share of the secret key of a ring
+// member, any Threshold of the shares can sign for Public together.
+type ThresholdShare struct {
+	Threshold int        `json:"threshold"`
+	Index     int        `json:"index"`
+	Share     *big.Int   `json:"share"`
+	Public    CurvePoint `json:"public"`
+}
+
+// A ThresholdCommitment is published by a participant in the first round,
+// it contains their public share, their share of tau and commitments to
+// two nonces against both the generator and the message point.
+type ThresholdCommitment struct {
+	Index       int        `json:"index"`
+	Public      CurvePoint `json:"public"`
+	PublicShare CurvePoint `json:"publicShare"`
+	Tau         CurvePoint `json:"tau"`
+	D           CurvePoint `json:"d"`
+	E           CurvePoint `json:"e"`
+	DH          CurvePoint `json:"dh"`
+	EH          CurvePoint `json:"eh"`
+}
+
+// ThresholdNonces are the secret nonces behind a ThresholdCommitment, they
+// must be kept private and used for exactly one signature.
+type ThresholdNonces struct {
+	Index int      `json:"index"`
+	D     *big.Int `json:"d"`
+	E     *big.Int `json:"e"`
+}
+
+// A ThresholdResponse is a participant's share of the response of the signer
+type ThresholdResponse struct {
+	Index int      `json:"index"`
+	S     *big.Int `json:"s"`
+}
+
+// MarshalJSON converts a ThresholdShare to a JSON representation
+func (s *ThresholdShare) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Threshold int        `json:"threshold"`
+		Index     int        `json:"index"`
+		Share     *hexBig    `json:"share"`
+		Public    CurvePoint `json:"public"`
+	}{
+		Threshold: s.Threshold,
+		Index:     s.Index,
+		Share:     (*hexBig)(s.Share),
+		Public:    s.Public,
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a ThresholdShare struct
+func (s *ThresholdShare) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Threshold int        `json:"threshold"`
+		Index     int        `json:"index"`
+		Share     *hexBig    `json:"share"`
+		Public    CurvePoint `json:"public"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.Share == nil {
+		return errors.New("Invalid share, no share specified")
+	}
+
+	s.Threshold = aux.Threshold
+	s.Index = aux.Index
+	s.Share = (*big.Int)(aux.Share)
+	s.Public = aux.Public
+	return nil
+}
+
+// MarshalJSON converts ThresholdNonces to a JSON representation
+func (n *ThresholdNonces) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Index int     `json:"index"`
+		D     *hexBig `json:"d"`
+		E     *hexBig `json:"e"`
+	}{
+		Index: n.Index,
+		D:     (*hexBig)(n.D),
+		E:     (*hexBig)(n.E),
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a ThresholdNonces struct
+func (n *ThresholdNonces) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Index int     `json:"index"`
+		D     *hexBig `json:"d"`
+		E     *hexBig `json:"e"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.D == nil || aux.E == nil {
+		return errors.New("Invalid nonces, no d or e specified")
+	}
+
+	n.Index = aux.Index
+	n.D = (*big.Int)(aux.D)
+	n.E = (*big.Int)(aux.E)
+	return nil
+}
+
+// MarshalJSON converts a ThresholdResponse to a JSON representation
+func (r *ThresholdResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Index int     `json:"index"`
+		S     *hexBig `json:"s"`
+	}{
+		Index: r.Index,
+		S:     (*hexBig)(r.S),
+	})
+}
+
+// UnmarshalJSON converts a JSON representation to a ThresholdResponse struct
+func (r *ThresholdResponse) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Index int     `json:"index"`
+		S     *hexBig `json:"s"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if aux.S == nil {
+		return errors.New("Invalid response, no s specified")
+	}
+
+	r.Index = aux.Index
+	r.S = (*big.Int)(aux.S)
+	return nil
+}
+
+// SplitThresholdKey splits the secret key of a ring member into n Shamir
+// shares, any t of which can sign together.
+func SplitThresholdKey(random io.Reader, secret *big.Int, t int, n int) ([]ThresholdShare, error) {
+	if false == isValidSecretKey(secret) {
+		return nil, errors.New("Invalid secret key")
+	}
+
+	split, err := ShamirSplit(random, secret, t, n, false)
+	if err != nil {
+		return nil, err
+	}
+
+	public := derivePublicKey(secret)
+	shares := make([]ThresholdShare, n)
+	for i, share := range split.Shares {
+		shares[i] = ThresholdShare{
+			Threshold: t,
+			Index:     share.Index,
+			Share:     share.Value,
+			Public:    public,
+		}
+	}
+
+	return shares, nil
+}
+
+// ThresholdRound1 picks the participant's nonces for one signature of the
+// message and commits to them. The nonces must be kept secret until they
+// are used in ThresholdRound2, and never reused.
+func ThresholdRound1(random io.Reader, share *ThresholdShare, message []byte) (*ThresholdCommitment, *ThresholdNonces, error) {
+	if false == isValidSecretKey(share.Share) {
+		return nil, nil, errors.New("Invalid key share")
+	}
+
+	nonces, err := randomScalars(random, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashp := messagePoint(message)
+	commitment := &ThresholdCommitment{
+		Index:       share.Index,
+		Public:      share.Public,
+		PublicShare: CurvePoint{}.ScalarBaseMult(share.Share),
+		Tau:         hashp.ScalarMult(share.Share),
+		D:           CurvePoint{}.ScalarBaseMult(nonces[0]),
+		E:           CurvePoint{}.ScalarBaseMult(nonces[1]),
+		DH:          hashp.ScalarMult(nonces[0]),
+		EH:          hashp.ScalarMult(nonces[1]),
+	}
+
+	return commitment, &ThresholdNonces{share.Index, nonces[0], nonces[1]}, nil
+}
+
+// A thresholdSession is the state of a signature that all participants
+// derive independently from the ring, message and first round commitments.
+type thresholdSession struct {
+	commitments []ThresholdCommitment
+	indices     []int
+	lambdas     []*big.Int
+	binding     []*big.Int
+	signer      int
+	challenge   *big.Int
+	signature   *RingSignature
+}
+
+// newThresholdSession checks the commitments and computes the ring
+// signature up to the response of the signer. The binding factors tie each
+// participant's nonces to the whole session and the decoys are derived
+// from the transcript, so every participant computes the same challenge:
+//
+//   ρ_i ← H(transcript, i)
+//   a ← ∑ D_i + ρ_i·E_i
+//   b ← ∑ DH_i + ρ_i·EH_i
+//   τ ← ∑ λ_i·τ_i
+//
+func newThresholdSession(ring *Ring, message []byte, commitments []ThresholdCommitment) (*thresholdSession, error) {
+	N := CurvePoint{}.Order()
+
+	if len(commitments) == 0 {
+		return nil, errors.New("No commitments")
+	}
+
+	if !onBN256(*ring) {
+		return nil, errNotBN256("threshold")
+	}
+
+	sorted := make([]ThresholdCommitment, len(commitments))
+	copy(sorted, commitments)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Index < sorted[j].Index })
+
+	public := sorted[0].Public
+	signer := -1
+	for j, pub := range ring.PubKeys {
+		if pub.Equals(&public) {
+			signer = j
+		}
+	}
+	if signer < 0 {
+		return nil, errors.New("Public key being signed for is not in the ring")
+	}
+
+	session := thresholdSession{commitments: sorted, signer: signer}
+	transcript := appendLengthPrefixed(nil, message)
+	for _, pub := range ring.PubKeys {
+		transcript = append(transcript, pub.Marshal()...)
+	}
+	for i, c := range sorted {
+		if c.Index < 1 || (i > 0 && c.Index == sorted[i-1].Index) {
+			return nil, fmt.Errorf("Invalid or duplicate participant index: %v", c.Index)
+		}
+		if false == c.Public.Equals(&public) {
+			return nil, fmt.Errorf("Participant %v is signing for a different key", c.Index)
+		}
+
+		session.indices = append(session.indices, c.Index)
+		transcript = append(transcript, indexBytes(c.Index)...)
+		for _, point := range []CurvePoint{c.PublicShare, c.Tau, c.D, c.E, c.DH, c.EH} {
+			if point.IsInfinity() {
+				return nil, fmt.Errorf("Invalid commitment from participant %v", c.Index)
+			}
+			transcript = append(transcript, point.Marshal()...)
+		}
+	}
+
+	var publicSum, tau, a, b CurvePoint
+	for i, c := range sorted {
+		lambda := lagrangeCoefficient(c.Index, session.indices)
+		rho := hashToScalar(thresholdLabel, []byte("binding"), transcript, indexBytes(c.Index))
+		session.lambdas = append(session.lambdas, lambda)
+		session.binding = append(session.binding, rho)
+
+		A := c.E.ScalarMult(rho).Add(c.D)
+		B := c.EH.ScalarMult(rho).Add(c.DH)
+		if i == 0 {
+			publicSum = c.PublicShare.ScalarMult(lambda)
+			tau = c.Tau.ScalarMult(lambda)
+			a, b = A, B
+			continue
+		}
+		publicSum = publicSum.Add(c.PublicShare.ScalarMult(lambda))
+		tau = tau.Add(c.Tau.ScalarMult(lambda))
+		a = a.Add(A)
+		b = b.Add(B)
+	}
+
+	// The public shares of too few participants interpolate to a different key
+	if false == publicSum.Equals(&public) {
+		return nil, errors.New("Public shares do not match the public key, too few participants?")
+	}
+
+	hashp := messagePoint(message)
+	hasher := newRingHasher(hashp, &tau)
+	defer hasher.release()
+
+	n := len(ring.PubKeys)
+	ctlist := make([]Scalar, 2*n)
+	csum := big.NewInt(0)
+	for j := 0; j < n; j++ {
+		if j == signer {
+			hasher.add(&a, &b)
+			continue
+		}
+
+		cj := hashToScalar(thresholdLabel, []byte("c"), transcript, indexBytes(j))
+		tj := hashToScalar(thresholdLabel, []byte("t"), transcript, indexBytes(j))
+		aj := ring.PubKeys[j].ParameterPointAdd(tj, cj)
+		bj := hashp.HashPointAdd(tau, tj, cj)
+		hasher.add(&aj, &bj)
+
+		ctlist[2*j] = NewScalar(BN256, cj)
+		ctlist[2*j+1] = NewScalar(BN256, tj)
+		csum.Add(csum, cj)
+	}
+
+	c := hasher.scalar()
+	c.Sub(c, csum)
+	c.Mod(c, N)
+	ctlist[2*signer] = NewScalar(BN256, c)
+
+	session.challenge = c
+	session.signature = &RingSignature{tau, ctlist}
+	return &session, nil
+}
+
+// ThresholdRound2 computes the participant's share of the signer's
+// response once the commitments of all participants are known:
+//
+//   s_i ← d_i + ρ_i·e_i - c·λ_i·x_i
+//
+func ThresholdRound2(share *ThresholdShare, nonces *ThresholdNonces, ring *Ring, message []byte, commitments []ThresholdCommitment) (*ThresholdResponse, error) {
+	N := CurvePoint{}.Order()
+
+	if nonces.Index != share.Index {
+		return nil, errors.New("Nonces belong to a different participant")
+	}
+
+	session, err := newThresholdSession(ring, message, commitments)
+	if err != nil {
+		return nil, err
+	}
+	if len(session.commitments) < share.Threshold {
+		return nil, fmt.Errorf("Need %v participants, only have %v", share.Threshold, len(session.commitments))
+	}
+
+	mine := -1
+	for i, c := range session.commitments {
+		if c.Index == share.Index {
+			mine = i
+		}
+	}
+	if mine < 0 {
+		return nil, errors.New("Own commitment is missing")
+	}
+
+	own := session.commitments[mine]
+	D := CurvePoint{}.ScalarBaseMult(nonces.D)
+	E := CurvePoint{}.ScalarBaseMult(nonces.E)
+	if false == own.Public.Equals(&share.Public) || false == own.D.Equals(&D) || false == own.E.Equals(&E) {
+		return nil, errors.New("Own commitment does not match the nonces")
+	}
+
+	s := new(big.Int).Mul(session.binding[mine], nonces.E)
+	s.Add(s, nonces.D)
+	cx := new(big.Int).Mul(session.challenge, session.lambdas[mine])
+	cx.Mul(cx, share.Share)
+	s.Sub(s, cx)
+	s.Mod(s, N)
+
+	return &ThresholdResponse{share.Index, s}, nil
+}
+
+// ThresholdFinalize checks every participant's response and combines them
+// into a ring signature which verifies like any other:
+//
+//   g^s_i · PublicShare_i^(c·λ_i) = D_i · E_i^ρ_i
+//   H^s_i · τ_i^(c·λ_i) = DH_i · EH_i^ρ_i
+//   t ← ∑ s_i
+//
+func ThresholdFinalize(ring *Ring, message []byte, commitments []ThresholdCommitment, responses []ThresholdResponse) (*RingSignature, error) {
+	N := CurvePoint{}.Order()
+
+	session, err := newThresholdSession(ring, message, commitments)
+	if err != nil {
+		return nil, err
+	}
+
+	byIndex := make(map[int]*big.Int)
+	for _, r := range responses {
+		if r.S == nil || r.S.Sign() < 0 || r.S.Cmp(N) >= 0 {
+			return nil, fmt.Errorf("Invalid response from participant %v", r.Index)
+		}
+		byIndex[r.Index] = r.S
+	}
+
+	hashp := messagePoint(message)
+	t := big.NewInt(0)
+	for i, c := range session.commitments {
+		s, ok := byIndex[c.Index]
+		if !ok {
+			return nil, fmt.Errorf("No response from participant %v", c.Index)
+		}
+
+		cl := new(big.Int).Mul(session.challenge, session.lambdas[i])
+		cl.Mod(cl, N)
+		A := c.E.ScalarMult(session.binding[i]).Add(c.D)
+		B := c.EH.ScalarMult(session.binding[i]).Add(c.DH)
+		gs := c.PublicShare.ParameterPointAdd(s, cl)
+		hs := hashp.HashPointAdd(c.Tau, s, cl)
+		if false == gs.Equals(&A) || false == hs.Equals(&B) {
+			return nil, fmt.Errorf("Invalid response from participant %v", c.Index)
+		}
+
+		t.Add(t, s)
+	}
+
+	signature := session.signature
+	signature.Ctlist[2*session.signer+1] = NewScalar(BN256, t)
+	if false == ring.VerifySignature(message, *signature) {
+		return nil, errors.New("Combined signature does not verify")
+	}
+
+	return signature, nil
+}
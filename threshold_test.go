@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+)
+
+// runThreshold runs both rounds of the protocol for the given shares and
+// returns the commitments and responses
+func runThreshold(t *testing.T, r *Ring, message []byte, shares []ThresholdShare) ([]ThresholdCommitment, []ThresholdResponse) {
+	commitments := make([]ThresholdCommitment, len(shares))
+	nonces := make([]*ThresholdNonces, len(shares))
+	for i := range shares {
+		c, n, err := ThresholdRound1(rand.Reader, &shares[i], message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commitments[i] = *c
+		nonces[i] = n
+	}
+
+	responses := make([]ThresholdResponse, len(shares))
+	for i := range shares {
+		resp, err := ThresholdRound2(&shares[i], nonces[i], r, message, commitments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		responses[i] = *resp
+	}
+
+	return commitments, responses
+}
+
+func TestThresholdSignature(t *testing.T) {
+	r := generateRing(4)
+	message := []byte("foobarbaz")
+	signer := 2
+
+	shares, err := SplitThresholdKey(rand.Reader, r.PrivKeys[signer].Int(), 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, subset := range [][]int{{0, 1, 2}, {4, 1, 3}, {0, 1, 2, 3, 4}} {
+		var chosen []ThresholdShare
+		for _, i := range subset {
+			chosen = append(chosen, shares[i])
+		}
+
+		commitments, responses := runThreshold(t, &r, message, chosen)
+		sig, err := ThresholdFinalize(&r, message, commitments, responses)
+		if err != nil {
+			t.Fatalf("Failed with shares %v: %v", subset, err)
+		}
+		if !r.VerifySignature(message, *sig) {
+			t.Fatalf("Signature not verified with shares %v", subset)
+		}
+
+		// The key image links to signatures made with the whole key
+		whole, err := r.Signature(rand.Reader, r.PrivKeys[signer], message, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sig.Tau.Equals(&whole.Tau) {
+			t.Fatalf("Key image differs from the whole key with shares %v", subset)
+		}
+	}
+}
+
+func TestThresholdTooFewShares(t *testing.T) {
+	r := generateRing(3)
+	message := []byte("foobarbaz")
+
+	shares, err := SplitThresholdKey(rand.Reader, r.PrivKeys[0].Int(), 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, n, err := ThresholdRound1(rand.Reader, &shares[0], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, _, err := ThresholdRound1(rand.Reader, &shares[1], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ThresholdRound2(&shares[0], n, &r, message, []ThresholdCommitment{*c, *c2})
+	if err == nil {
+		t.Fatal("Should fail with fewer participants than the threshold")
+	}
+}
+
+func TestThresholdBadResponse(t *testing.T) {
+	r := generateRing(3)
+	message := []byte("foobarbaz")
+
+	shares, err := SplitThresholdKey(rand.Reader, r.PrivKeys[1].Int(), 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commitments, responses := runThreshold(t, &r, message, shares[:2])
+	responses[1].S.Add(responses[1].S, bigOne)
+	_, err = ThresholdFinalize(&r, message, commitments, responses)
+	if err == nil {
+		t.Fatal("Should reject a bad response")
+	}
+
+	// Responses are bound to the message they were committed for
+	commitments, responses = runThreshold(t, &r, message, shares[:2])
+	_, err = ThresholdFinalize(&r, []byte("badmessage"), commitments, responses)
+	if err == nil {
+		t.Fatal("Should reject responses for a different message")
+	}
+}
+
+func TestThresholdForeignNonces(t *testing.T) {
+	r := generateRing(3)
+	message := []byte("foobarbaz")
+
+	shares, err := SplitThresholdKey(rand.Reader, r.PrivKeys[1].Int(), 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c0, _, err := ThresholdRound1(rand.Reader, &shares[0], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1, _, err := ThresholdRound1(rand.Reader, &shares[1], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stale, err := ThresholdRound1(rand.Reader, &shares[0], message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ThresholdRound2(&shares[0], stale, &r, message, []ThresholdCommitment{*c0, *c1})
+	if err == nil {
+		t.Fatal("Should reject nonces which do not match the commitment")
+	}
+}
+
+func TestThresholdShareJSON(t *testing.T) {
+	r := generateRing(1)
+	shares, err := SplitThresholdKey(rand.Reader, r.PrivKeys[0].Int(), 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(&shares[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var share ThresholdShare
+	if err := json.Unmarshal(data, &share); err != nil {
+		t.Fatal(err)
+	}
+	if share.Index != 2 || share.Threshold != 2 || share.Share.Cmp(shares[1].Share) != 0 {
+		t.Fatalf("Share changed after JSON round trip: %s", data)
+	}
+}
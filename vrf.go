@@ -0,0 +1,154 @@
+// Copyright (c) 2017 Clearmatics Technologies Ltd
+
+// SPDX-License-Identifier: LGPL-3.0+
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// vrfLabel domain separates the hashes of VRF inputs onto the curve
+var vrfLabel = []byte("orbital-vrf")
+
+// vrfOutputLabel domain separates VRF outputs from other hashes of points
+var vrfOutputLabel = []byte("orbital-vrf-output")
+
+// vrfNonceLabel domain separates the seeds of VRF proof nonces
+var vrfNonceLabel = []byte("orbital-vrf-nonce")
+
+// A VRFProof is the output of a verifiable random function of an input
+// under a BN256 key, in the style of ECVRF, and the proof that it is the
+// only output the key could have given:
+//
+//   H ← H(pk, α)
+//   Γ ← H·x
+//   β ← H(Γ)
+//
+// The proof is a DLEQ proof that Γ and pk have the same discrete log. The
+// output can't be predicted without the secret key, but once published
+// anyone can check it, so it can be used to make decisions such as the
+// order of ring members which every participant can verify.
+//
+type VRFProof struct {
+	Public CurvePoint `json:"public"`
+	Input  []byte     `json:"input"`
+	Gamma  CurvePoint `json:"gamma"`
+	Output []byte     `json:"output"`
+	Proof  *DLEQProof `json:"proof"`
+}
+
+// vrfInputPoint hashes the input onto the curve together with the public
+// key, so the outputs of different keys are unrelated
+func vrfInputPoint(public *CurvePoint, input []byte) *CurvePoint {
+	data := appendLengthPrefixed(nil, vrfLabel)
+	data = appendLengthPrefixed(data, public.Marshal())
+	data = appendLengthPrefixed(data, input)
+	return NewCurvePointFromHash(sha256.Sum256(data))
+}
+
+// vrfOutput hashes Γ into the 32 byte output of the VRF
+func vrfOutput(gamma *CurvePoint) []byte {
+	data := appendLengthPrefixed(nil, vrfOutputLabel)
+	data = appendLengthPrefixed(data, gamma.Marshal())
+	h := sha256.Sum256(data)
+	return h[:]
+}
+
+// VRFProve evaluates the VRF of the input with the secret key x. The nonce
+// of the proof is derived from the key and the input, as in ECVRF, so
+// proving the same input twice gives the same proof.
+//
+func VRFProve(x Scalar, input []byte) (*VRFProof, error) {
+	if x.Group() != BN256 {
+		return nil, fmt.Errorf("VRFs are only supported on %v", DefaultCurve)
+	}
+	if x.IsZero() {
+		return nil, errors.New("Invalid secret key")
+	}
+
+	G := BN256.ScalarBaseMult(bigOne)
+	public := G.ScalarMult(x.Int())
+	H := vrfInputPoint(&public, input)
+	gamma := H.ScalarMult(x.Int())
+
+	seed := appendLengthPrefixed(nil, vrfNonceLabel)
+	seed = appendLengthPrefixed(seed, x.Bytes())
+	seed = appendLengthPrefixed(seed, H.Marshal())
+	proof, err := NewDLEQProof(newHmacDRBG(seed), x, &G, H, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VRFProof{
+		Public: public,
+		Input:  input,
+		Gamma:  gamma,
+		Output: vrfOutput(&gamma),
+		Proof:  proof,
+	}, nil
+}
+
+// Verify checks the output is the VRF of the input under the public key
+func (p *VRFProof) Verify() bool {
+	if p.Public.p == nil || p.Gamma.p == nil {
+		return false
+	}
+	if p.Public.Group() != BN256 || p.Gamma.Group() != BN256 {
+		return false
+	}
+	if false == bytes.Equal(p.Output, vrfOutput(&p.Gamma)) {
+		return false
+	}
+
+	G := BN256.ScalarBaseMult(bigOne)
+	H := vrfInputPoint(&p.Public, p.Input)
+	return p.Proof.Verify(&G, H, &p.Public, &p.Gamma, p.Input)
+}
+
+// Permutation returns an order of n items determined by the output, such
+// as the order of the members of a ring, so anyone who has verified the
+// proof can reproduce it. It is a Fisher-Yates shuffle with an HMAC-DRBG
+// seeded with the output:
+//
+//   for i ← n-1 down to 1
+//     repeat
+//       j ← the next 4 bytes of the DRBG as a big endian integer,
+//           shifted right to the bit length of i
+//     until j ≤ i
+//     swap items i and j
+//
+func (p *VRFProof) Permutation(n int) ([]int, error) {
+	if n < 0 {
+		return nil, errors.New("Number of items can't be negative")
+	}
+	if int64(n) > math.MaxInt32 {
+		return nil, fmt.Errorf("Can't order more than %v items", math.MaxInt32)
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+
+	random := newHmacDRBG(p.Output)
+	buf := make([]byte, 4)
+	for i := n - 1; i > 0; i-- {
+		shift := uint(32 - big.NewInt(int64(i)).BitLen())
+		for {
+			random.Read(buf)
+			j := int(binary.BigEndian.Uint32(buf) >> shift)
+			if j <= i {
+				order[i], order[j] = order[j], order[i]
+				break
+			}
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestVRF(t *testing.T) {
+	x, err := RandomScalar(BN256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []byte("round 1")
+
+	proof, err := VRFProve(x, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proof.Verify() {
+		t.Fatal("Valid VRF proof not verified")
+	}
+
+	// The output and the proof are determined by the key and the input
+	again, err := VRFProve(x, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Output, proof.Output) || again.Proof.S.Cmp(proof.Proof.S) != 0 {
+		t.Fatal("VRF proofs of the same input differ")
+	}
+
+	other, err := VRFProve(x, []byte("round 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(other.Output, proof.Output) {
+		t.Fatal("VRF outputs of different inputs are equal")
+	}
+
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded VRFProof
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Verify() {
+		t.Fatalf("VRF proof not verified after a JSON round trip: %s", data)
+	}
+
+	// Claiming a different output or input
+	loaded.Output = other.Output
+	if loaded.Verify() {
+		t.Fatal("VRF proof verified with a different output")
+	}
+	loaded.Output, loaded.Gamma = other.Output, other.Gamma
+	if loaded.Verify() {
+		t.Fatal("VRF proof verified with the gamma of a different input")
+	}
+	loaded = *proof
+	loaded.Input = []byte("round 2")
+	if loaded.Verify() {
+		t.Fatal("VRF proof verified for a different input")
+	}
+
+	// Only BN256 keys are supported
+	y, err := RandomScalar(Secp256k1, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VRFProve(y, input); err == nil {
+		t.Fatal("Evaluated a VRF with a secp256k1 key")
+	}
+}
+
+func TestVRFPermutation(t *testing.T) {
+	x, err := RandomScalar(BN256, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := VRFProve(x, []byte("ring assignment"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 16
+	order, err := proof.Permutation(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int]bool, n)
+	for _, i := range order {
+		if i < 0 || i >= n || seen[i] {
+			t.Fatalf("Not a permutation: %v", order)
+		}
+		seen[i] = true
+	}
+	if len(seen) != n {
+		t.Fatalf("Not a permutation: %v", order)
+	}
+
+	again, err := proof.Permutation(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range order {
+		if order[i] != again[i] {
+			t.Fatalf("Permutations of the same output differ: %v, %v", order, again)
+		}
+	}
+
+	if _, err := proof.Permutation(-1); err == nil {
+		t.Fatal("Permuted a negative number of items")
+	}
+}
+
+func TestVRFPermutationKnownAnswer(t *testing.T) {
+	output := make([]byte, 32)
+	for i := range output {
+		output[i] = byte(i)
+	}
+	proof := &VRFProof{Output: output}
+
+	for _, tc := range []struct {
+		n     int
+		order []int
+	}{
+		{0, []int{}},
+		{1, []int{0}},
+		{2, []int{1, 0}},
+		{8, []int{2, 6, 5, 0, 7, 4, 3, 1}},
+		{20, []int{18, 16, 2, 8, 10, 19, 15, 7, 1, 11, 12, 14, 9, 0, 5, 3, 4, 17, 13, 6}},
+	} {
+		order, err := proof.Permutation(tc.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(order) != fmt.Sprint(tc.order) {
+			t.Fatalf("Permutation of %v items is %v, expected %v", tc.n, order, tc.order)
+		}
+	}
+}